@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 
+	"github.com/dhax/go-base/auth/authorize"
 	"github.com/dhax/go-base/auth/jwt"
 	"github.com/dhax/go-base/models"
 )
@@ -70,47 +71,51 @@ func (rs *Resource) Router() chi.Router {
 	r.Group(func(r chi.Router) {
 		r.Use(jwt.Authenticator)
 
+		canRead := authorize.RequiresPermission(authorize.PermRead)
+		canWrite := authorize.RequiresPermission(authorize.PermWrite)
+		canManage := authorize.RequiresPermission(authorize.PermManage)
+
 		// Category routes
 		r.Route("/categories", func(r chi.Router) {
-			r.Get("/", rs.listCategories)
-			r.Post("/", rs.createCategory)
+			r.With(canRead).Get("/", rs.listCategories)
+			r.With(canManage).Post("/", rs.createCategory)
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", rs.getCategory)
-				r.Put("/", rs.updateCategory)
-				r.Delete("/", rs.deleteCategory)
+				r.With(canRead).Get("/", rs.getCategory)
+				r.With(canManage).Put("/", rs.updateCategory)
+				r.With(canManage).Delete("/", rs.deleteCategory)
 			})
 		})
 
 		// Activity Group routes
 		r.Route("/", func(r chi.Router) {
-			r.Get("/", rs.listActivityGroups)
-			r.Post("/", rs.createActivityGroup)
+			r.With(canRead).Get("/", rs.listActivityGroups)
+			r.With(canWrite).Post("/", rs.createActivityGroup)
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", rs.getActivityGroup)
-				r.Put("/", rs.updateActivityGroup)
-				r.Delete("/", rs.deleteActivityGroup)
-				
+				r.With(canRead).Get("/", rs.getActivityGroup)
+				r.With(canWrite).Put("/", rs.updateActivityGroup)
+				r.With(canManage).Delete("/", rs.deleteActivityGroup)
+
 				// Timeslot routes for an activity group
 				r.Route("/times", func(r chi.Router) {
-					r.Get("/", rs.listAgTimes)
-					r.Post("/", rs.createAgTime)
+					r.With(canRead).Get("/", rs.listAgTimes)
+					r.With(canWrite).Post("/", rs.createAgTime)
 					r.Route("/{timeId}", func(r chi.Router) {
-						r.Put("/", rs.updateAgTime)
-						r.Delete("/", rs.deleteAgTime)
+						r.With(canWrite).Put("/", rs.updateAgTime)
+						r.With(canWrite).Delete("/", rs.deleteAgTime)
 					})
 				})
-				
+
 				// Student enrollment routes
 				r.Route("/students", func(r chi.Router) {
-					r.Get("/", rs.listEnrolledStudents)
-					r.Post("/{studentId}", rs.enrollStudent)
-					r.Delete("/{studentId}", rs.unenrollStudent)
+					r.With(canRead).Get("/", rs.listEnrolledStudents)
+					r.With(canWrite).Post("/{studentId}", rs.enrollStudent)
+					r.With(canWrite).Delete("/{studentId}", rs.unenrollStudent)
 				})
 			})
 		})
 
 		// Student AG routes
-		r.Get("/student/{studentId}", rs.listStudentAgs)
+		r.With(canRead).Get("/student/{studentId}", rs.listStudentAgs)
 	})
 
 	return r
@@ -629,4 +634,4 @@ func (rs *Resource) listStudentAgs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.JSON(w, r, ags)
-}
\ No newline at end of file
+}
@@ -26,6 +26,7 @@ type AccountStore interface {
 	Get(id int) (*pwdless.Account, error)
 	Update(*pwdless.Account) error
 	Delete(*pwdless.Account) error
+	pwdless.SessionStore
 }
 
 // AccountResource implements account management handler.
@@ -49,6 +50,7 @@ func (rs *AccountResource) router() *chi.Mux {
 		r.Get("/", rs.get)
 		r.Put("/", rs.update)
 		r.Delete("/", rs.delete)
+		r.Mount("/sessions", pwdless.NewSessionResource(rs.Store, sessionAccountID).Router())
 	})
 	return r
 }
@@ -167,3 +169,8 @@ func (rs *AccountResource) delete(w http.ResponseWriter, r *http.Request) {
 	}
 	render.Respond(w, r, http.NoBody)
 }
+
+// sessionAccountID returns the ID of the account of the sessions requested.
+func sessionAccountID(r *http.Request) int {
+	return r.Context().Value(ctxAccount).(*pwdless.Account).ID
+}
@@ -22,11 +22,16 @@ type ctxKey int
 
 const (
 	ctxAccount ctxKey = iota
+	ctxUserID
 )
 
 // API provides admin application resources and handlers.
 type API struct {
-	Accounts *AccountResource
+	Accounts     *AccountResource
+	PersonalData *PersonalDataResource
+
+	// RFID reviews RFID device registrations, optional
+	RFID http.Handler
 }
 
 // NewAPI configures and returns admin application API.
@@ -34,8 +39,12 @@ func NewAPI(db *bun.DB) (*API, error) {
 	accountStore := database.NewAdmAccountStore(db)
 	accounts := NewAccountResource(accountStore)
 
+	personalDataStore := database.NewPersonalDataStore(db)
+	personalData := NewPersonalDataResource(personalDataStore)
+
 	api := &API{
-		Accounts: accounts,
+		Accounts:     accounts,
+		PersonalData: personalData,
 	}
 	return api, nil
 }
@@ -51,6 +60,10 @@ func (a *API) Router() *chi.Mux {
 	})
 
 	r.Mount("/accounts", a.Accounts.router())
+	r.Mount("/personal-data", a.PersonalData.router())
+	if a.RFID != nil {
+		r.Mount("/rfid", a.RFID)
+	}
 	return r
 }
 
@@ -34,6 +34,16 @@ func ErrInvalidRequest(err error) render.Renderer {
 	}
 }
 
+// ErrConflict returns status 409 Conflict including error message.
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     http.StatusText(http.StatusConflict),
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrRender returns status 422 Unprocessable Entity rendering response error.
 func ErrRender(err error) render.Renderer {
 	return &ErrResponse{
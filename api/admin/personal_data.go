@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/database"
+	"github.com/dhax/go-base/models"
+)
+
+// PersonalDataStore defines database operations for data subject requests.
+type PersonalDataStore interface {
+	GetStudentUserID(ctx context.Context, studentID int64) (int64, error)
+	Export(ctx context.Context, userID int64) (*models.PersonalData, error)
+	Erase(ctx context.Context, userID int64, dryRun bool) ([]database.ErasureResult, error)
+}
+
+// PersonalDataResource implements the export and erasure of personal data of
+// users and students on data subject requests.
+type PersonalDataResource struct {
+	Store PersonalDataStore
+}
+
+// NewPersonalDataResource creates and returns a personal data resource.
+func NewPersonalDataResource(store PersonalDataStore) *PersonalDataResource {
+	return &PersonalDataResource{
+		Store: store,
+	}
+}
+
+func (rs *PersonalDataResource) router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Route("/users/{userID}", func(r chi.Router) {
+		r.Use(rs.userCtx)
+		r.Get("/", rs.export)
+		r.Delete("/", rs.erase)
+	})
+	r.Route("/students/{studentID}", func(r chi.Router) {
+		r.Use(rs.studentCtx)
+		r.Get("/", rs.export)
+		r.Delete("/", rs.erase)
+	})
+	return r
+}
+
+func (rs *PersonalDataResource) userCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+		if err != nil {
+			render.Render(w, r, ErrBadRequest)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxUserID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// studentCtx resolves the user of a student, personal data is kept per user.
+func (rs *PersonalDataResource) studentCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "studentID"), 10, 64)
+		if err != nil {
+			render.Render(w, r, ErrBadRequest)
+			return
+		}
+		userID, err := rs.Store.GetStudentUserID(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			render.Render(w, r, ErrNotFound)
+			return
+		}
+		if err != nil {
+			log(r).Error(err)
+			render.Render(w, r, ErrInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxUserID, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type erasureResponse struct {
+	UserID int64                    `json:"user_id"`
+	DryRun bool                     `json:"dry_run"`
+	Erased []database.ErasureResult `json:"erased"`
+}
+
+// export returns the personal data of a user as JSON file download.
+func (rs *PersonalDataResource) export(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ctxUserID).(int64)
+	data, err := rs.Store.Export(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	if err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
+
+	log(r).WithFields(logrus.Fields{
+		"user_id":     userID,
+		"exported_by": jwt.ClaimsFromCtx(r.Context()).ID,
+	}).Info("personal data exported")
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="personal-data-%d.json"`, userID))
+	render.JSON(w, r, data)
+}
+
+// erase deletes the personal data of a user, keeping the daily statistics.
+// With ?dry_run=true the rows to delete are counted only.
+func (rs *PersonalDataResource) erase(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if s := r.URL.Query().Get("dry_run"); s != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("invalid dry_run: %s", s)))
+			return
+		}
+	}
+
+	userID := r.Context().Value(ctxUserID).(int64)
+	results, err := rs.Store.Erase(r.Context(), userID, dryRun)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	if errors.Is(err, database.ErrSpecialistErasure) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
+
+	if !dryRun {
+		log(r).WithFields(logrus.Fields{
+			"user_id":   userID,
+			"erased_by": jwt.ClaimsFromCtx(r.Context()).ID,
+		}).Info("personal data erased")
+	}
+
+	render.Respond(w, r, &erasureResponse{UserID: userID, DryRun: dryRun, Erased: results})
+}
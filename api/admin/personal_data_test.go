@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/database"
+	"github.com/dhax/go-base/models"
+)
+
+// MockPersonalDataStore is a mock implementation of the PersonalDataStore interface
+type MockPersonalDataStore struct {
+	mock.Mock
+}
+
+func (m *MockPersonalDataStore) GetStudentUserID(ctx context.Context, studentID int64) (int64, error) {
+	args := m.Called(ctx, studentID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPersonalDataStore) Export(ctx context.Context, userID int64) (*models.PersonalData, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PersonalData), args.Error(1)
+}
+
+func (m *MockPersonalDataStore) Erase(ctx context.Context, userID int64, dryRun bool) ([]database.ErasureResult, error) {
+	args := m.Called(ctx, userID, dryRun)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]database.ErasureResult), args.Error(1)
+}
+
+func servePersonalData(store *MockPersonalDataStore, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req = req.WithContext(jwt.ContextWithClaims(req.Context(), jwt.AppClaims{ID: 3, Roles: []string{roleAdmin}}))
+	w := httptest.NewRecorder()
+	NewPersonalDataResource(store).router().ServeHTTP(w, req)
+	return w
+}
+
+func TestExportPersonalData(t *testing.T) {
+	store := new(MockPersonalDataStore)
+	data := &models.PersonalData{
+		User:       &models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"},
+		Student:    &models.Student{ID: 7, CustomUserID: 42},
+		TagReads:   []models.TagRead{{ID: 1, TagID: "CARD01", ReaderID: "READER01"}},
+		AuthEvents: []models.AuthEvent{{ID: 1, Event: "lockout", Key: "login:email:john@example.com"}},
+	}
+	store.On("GetStudentUserID", mock.Anything, int64(7)).Return(int64(42), nil)
+	store.On("Export", mock.Anything, int64(42)).Return(data, nil)
+
+	w := servePersonalData(store, "GET", "/students/7")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="personal-data-42.json"`, w.Header().Get("Content-Disposition"))
+	var response models.PersonalData
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "John", response.User.FirstName)
+	assert.Equal(t, int64(7), response.Student.ID)
+	assert.Len(t, response.TagReads, 1)
+	assert.Equal(t, "login:email:john@example.com", response.AuthEvents[0].Key)
+
+	store.AssertExpectations(t)
+}
+
+func TestExportPersonalDataNotFound(t *testing.T) {
+	store := new(MockPersonalDataStore)
+	store.On("GetStudentUserID", mock.Anything, int64(7)).Return(int64(0), sql.ErrNoRows)
+	store.On("Export", mock.Anything, int64(42)).Return(nil, sql.ErrNoRows)
+
+	assert.Equal(t, http.StatusNotFound, servePersonalData(store, "GET", "/students/7").Code)
+	assert.Equal(t, http.StatusNotFound, servePersonalData(store, "GET", "/users/42").Code)
+	assert.Equal(t, http.StatusBadRequest, servePersonalData(store, "GET", "/users/abc").Code)
+
+	store.AssertExpectations(t)
+}
+
+func TestErasePersonalData(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		dryRun bool
+		err    error
+		status int
+	}{
+		{"erase", "/users/42", false, nil, http.StatusOK},
+		{"dry run", "/users/42?dry_run=true", true, nil, http.StatusOK},
+		{"unknown user", "/users/42", false, sql.ErrNoRows, http.StatusNotFound},
+		{"specialist", "/users/42", false, database.ErrSpecialistErasure, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := new(MockPersonalDataStore)
+			results := []database.ErasureResult{{Table: "tags", Rows: 12}, {Table: "custom_users", Rows: 1}}
+			if tt.err != nil {
+				results = nil
+			}
+			store.On("Erase", mock.Anything, int64(42), tt.dryRun).Return(results, tt.err)
+
+			w := servePersonalData(store, "DELETE", tt.target)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				var response erasureResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, int64(42), response.UserID)
+				assert.Equal(t, tt.dryRun, response.DryRun)
+				assert.Equal(t, results, response.Erased)
+			}
+
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestErasePersonalDataInvalidDryRun(t *testing.T) {
+	store := new(MockPersonalDataStore)
+
+	w := servePersonalData(store, "DELETE", "/users/42?dry_run=maybe")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	store.AssertNotCalled(t, "Erase", mock.Anything, mock.Anything, mock.Anything)
+}
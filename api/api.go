@@ -26,53 +26,82 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/render"
+	"github.com/spf13/viper"
 )
 
-// New configures application resources and routes.
-func New(enableCORS bool) (*chi.Mux, error) {
+// New configures application resources and routes. The returned start function
+// starts the background jobs, e.g. the device health monitor, and returns the
+// functions stopping them and closing open connections like event streams, to
+// be run on server shutdown.
+func New(enableCORS bool) (*chi.Mux, func() []func(), error) {
 	logger := logging.NewLogger()
 
 	db, err := database.DBConn()
 	if err != nil {
 		logger.WithField("module", "database").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	mailer, err := email.NewMailer()
 	if err != nil {
 		logger.WithField("module", "email").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	authStore := database.NewAuthStore(db)
-	authResource, err := pwdless.NewResource(authStore, mailer)
+
+	// Login tokens are kept in postgres by default to survive restarts and be shared between instances
+	var loginTokenStore pwdless.LoginTokenStore
+	switch viper.GetString("auth_login_token_store") {
+	case "memory":
+		loginTokenStore = pwdless.NewMemoryLoginTokenStore()
+	default:
+		loginTokenStore = database.NewLoginTokenStore(db)
+	}
+
+	// JWT signing keys are kept in postgres by default to be shared between instances
+	var keyStore jwt.KeyStore
+	switch viper.GetString("auth_jwt_key_store") {
+	case "memory":
+		keyStore = jwt.NewMemoryKeyStore()
+	default:
+		keyStore = database.NewJWTKeyStore(db)
+	}
+
+	authResource, err := pwdless.NewResource(authStore, loginTokenStore, keyStore, mailer)
 	if err != nil {
 		logger.WithField("module", "auth").Error(err)
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Rate limiting of login attempts, shared between instances when kept in postgres
+	if viper.GetString("auth_rate_limit_store") != "memory" {
+		authResource.Limiter = pwdless.NewRateLimiter(database.NewRateLimitStore(db))
 	}
+	authResource.Audit = authStore
 
 	adminAPI, err := admin.NewAPI(db)
 	if err != nil {
 		logger.WithField("module", "admin").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	appAPI, err := app.NewAPI(db)
 	if err != nil {
 		logger.WithField("module", "app").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	rfidAPI, err := rfid.NewAPI(db)
 	if err != nil {
 		logger.WithField("module", "rfid").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	roomAPI, err := room.NewAPI(db)
 	if err != nil {
 		logger.WithField("module", "room").Error(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Initialize stores
@@ -82,12 +111,26 @@ func New(enableCORS bool) (*chi.Mux, error) {
 
 	// Create API resources
 	userAPI := user.NewResource(userStore, authStore)
+	userAPI.UnknownTags = rfid.NewRFIDStore(db)
 	studentAPI := student.NewResource(studentStore, authStore)
 
 	// Connect RFID API with User, Student, and Timespan stores for tag tracking
 	rfidAPI.SetUserStore(userStore)
 	rfidAPI.SetStudentStore(studentStore)
 	rfidAPI.SetTimespanStore(timespanStore)
+	rfidAPI.SetMailer(mailer)
+	adminAPI.RFID = rfidAPI.AdminRouter()
+
+	// Movement data is pruned after its retention period
+	retentionStore := database.NewRetentionStore(db)
+
+	start := func() []func() {
+		return []func(){
+			rfidAPI.StartHealthMonitor(),
+			startPruneJob(retentionStore, database.NewRetentionPolicy(), viper.GetDuration("retention_prune_interval")),
+			rfidAPI.CloseEventStreams,
+		}
+	}
 
 	groupStore := database.NewGroupStore(db)
 	groupAPI := group.NewResource(groupStore, authStore)
@@ -103,41 +146,50 @@ func New(enableCORS bool) (*chi.Mux, error) {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
 	// r.Use(middleware.RealIP)
-	r.Use(middleware.Timeout(15 * time.Second))
 
 	r.Use(logging.NewStructuredLogger(logger))
-	r.Use(render.SetContentType(render.ContentTypeJSON))
 
 	// use CORS middleware if client is not served by this api, e.g. from other domain or CDN
 	if enableCORS {
 		r.Use(corsConfig().Handler)
 	}
 
-	r.Mount("/auth", authResource.Router())
-
-	// RFID endpoint doesn't require auth
-	r.Mount("/rfid", rfidAPI.Router())
+	// RFID event stream is long-lived and must not be cut by the request timeout
+	r.Mount("/rfid/events", rfidAPI.EventsRouter(authResource.TokenAuth.Verifier()))
 
 	r.Group(func(r chi.Router) {
-		r.Use(authResource.TokenAuth.Verifier())
-		r.Use(jwt.Authenticator)
-		r.Mount("/admin", adminAPI.Router())
-		r.Mount("/api", appAPI.Router())
-		r.Mount("/rooms", roomAPI.Router())
-		r.Mount("/users", userAPI.Router())
-		r.Mount("/students", studentAPI.Router())
-		r.Mount("/groups", groupAPI.Router())
-		r.Mount("/activities", activityAPI.Router())
-		r.Mount("/settings", settingsAPI.Router())
+		r.Use(middleware.Timeout(15 * time.Second))
+		r.Use(render.SetContentType(render.ContentTypeJSON))
+
+		r.Mount("/auth", authResource.Router())
+
+		// RFID endpoint doesn't require auth
+		r.Mount("/rfid", rfidAPI.Router())
+
+		r.Group(func(r chi.Router) {
+			r.Use(authResource.TokenAuth.Verifier())
+			r.Use(jwt.Authenticator)
+			r.Mount("/admin", adminAPI.Router())
+			r.Mount("/api", appAPI.Router())
+			r.Mount("/rooms", roomAPI.Router())
+			r.Mount("/users", userAPI.Router())
+			r.Mount("/students", studentAPI.Router())
+			r.Mount("/groups", groupAPI.Router())
+			r.Mount("/activities", activityAPI.Router())
+			r.Mount("/settings", settingsAPI.Router())
+		})
+
+		// public keys for services verifying access tokens on their own
+		r.Get("/.well-known/jwks.json", authResource.TokenAuth.JWKSHandler)
+
+		r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte("ok"))
+		})
+
+		r.Get("/*", SPAHandler("public"))
 	})
 
-	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		w.Write([]byte("ok"))
-	})
-
-	r.Get("/*", SPAHandler("public"))
-
-	return r, nil
+	return r, start, nil
 }
 
 func corsConfig() *cors.Cors {
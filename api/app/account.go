@@ -27,6 +27,7 @@ type AccountStore interface {
 	Delete(*pwdless.Account) error
 	UpdateToken(*jwt.Token) error
 	DeleteToken(*jwt.Token) error
+	pwdless.SessionStore
 }
 
 // AccountResource implements account management handler.
@@ -51,6 +52,7 @@ func (rs *AccountResource) router() *chi.Mux {
 		r.Put("/", rs.updateToken)
 		r.Delete("/", rs.deleteToken)
 	})
+	r.Mount("/sessions", pwdless.NewSessionResource(rs.Store, sessionAccountID).Router())
 	return r
 }
 
@@ -173,8 +175,14 @@ func (rs *AccountResource) deleteToken(w http.ResponseWriter, r *http.Request) {
 	acc := r.Context().Value(ctxAccount).(*pwdless.Account)
 	for _, t := range acc.Token {
 		if t.ID == id {
-			rs.Store.DeleteToken(&jwt.Token{ID: t.ID})
+			// revoke the whole session including its rotated refresh tokens
+			rs.Store.DeleteSession(acc.ID, t.ID)
 		}
 	}
 	render.Respond(w, r, http.NoBody)
 }
+
+// sessionAccountID returns the ID of the account of the sessions requested.
+func sessionAccountID(r *http.Request) int {
+	return r.Context().Value(ctxAccount).(*pwdless.Account).ID
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 
+	"github.com/dhax/go-base/auth/authorize"
 	"github.com/dhax/go-base/auth/jwt"
 	"github.com/dhax/go-base/models"
 )
@@ -54,29 +55,33 @@ func (rs *Resource) Router() chi.Router {
 	r.Group(func(r chi.Router) {
 		r.Use(jwt.Authenticator)
 
+		canRead := authorize.RequiresPermission(authorize.PermRead)
+		canWrite := authorize.RequiresPermission(authorize.PermWrite)
+		canManage := authorize.RequiresPermission(authorize.PermManage)
+
 		// Group routes
 		r.Route("/", func(r chi.Router) {
-			r.Get("/", rs.listGroups)
-			r.Post("/", rs.createGroup)
+			r.With(canRead).Get("/", rs.listGroups)
+			r.With(canManage).Post("/", rs.createGroup)
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", rs.getGroup)
-				r.Put("/", rs.updateGroup)
-				r.Delete("/", rs.deleteGroup)
-				r.Post("/supervisors", rs.updateGroupSupervisors)
+				r.With(canRead).Get("/", rs.getGroup)
+				r.With(canWrite).Put("/", rs.updateGroup)
+				r.With(canManage).Delete("/", rs.deleteGroup)
+				r.With(canManage).Post("/supervisors", rs.updateGroupSupervisors)
 			})
 		})
 
 		// Combined Group routes
 		r.Route("/combined", func(r chi.Router) {
-			r.Get("/", rs.listCombinedGroups)
-			r.Post("/", rs.createCombinedGroup)
+			r.With(canRead).Get("/", rs.listCombinedGroups)
+			r.With(canWrite).Post("/", rs.createCombinedGroup)
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", rs.getCombinedGroup)
+				r.With(canRead).Get("/", rs.getCombinedGroup)
 			})
 		})
 
 		// Special operations
-		r.Post("/merge-rooms", rs.mergeRooms)
+		r.With(canWrite).Post("/merge-rooms", rs.mergeRooms)
 	})
 
 	return r
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/database"
+	"github.com/dhax/go-base/logging"
+)
+
+// startPruneJob prunes the movement data expired under the retention policy
+// periodically, on one instance at a time. The returned function stops the job.
+func startPruneJob(store *database.RetentionStore, policy database.RetentionPolicy, interval time.Duration) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				pruneExpired(store, policy)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func pruneExpired(store *database.RetentionStore, policy database.RetentionPolicy) {
+	log := logging.Logger.WithField("chore", "pruneExpiredData")
+	ctx := context.Background()
+
+	// Several instances of the server prune once only
+	unlock, ok, err := store.TryLock(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !ok {
+		log.Debug("Pruning on another instance")
+		return
+	}
+	defer unlock()
+
+	results, err := store.Prune(ctx, policy, time.Now(), false)
+	for _, res := range results {
+		if res.Rows > 0 {
+			log.WithFields(logrus.Fields{
+				"table":  res.Table,
+				"cutoff": res.Cutoff,
+				"rows":   res.Rows,
+			}).Info("Expired data pruned")
+		}
+	}
+	if err != nil {
+		log.Error(err)
+	}
+}
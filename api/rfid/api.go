@@ -3,18 +3,26 @@ package rfid
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"github.com/uptrace/bun"
 
+	"github.com/dhax/go-base/auth/authorize"
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/email"
 	"github.com/dhax/go-base/logging"
 	"github.com/dhax/go-base/models"
 )
@@ -25,21 +33,45 @@ type API struct {
 	userStore     UserStore
 	studentStore  StudentStore
 	timespanStore TimespanStore
+	events        *EventBus
+
+	// limits for the read times supplied by readers
+	maxClockSkew time.Duration
+	maxReadAge   time.Duration
+
+	// handling of repeated reads, defaults for readers without own settings
+	debounce            *debouncer // nil disables debouncing
+	debounceDefault     time.Duration
+	antiPassbackDefault string
+
+	syncIntervalDefault time.Duration // sync interval of devices without own configuration
+
+	apiKeyGracePeriod time.Duration // validity of the previous key after a rotation
+	apiKeyQuery       bool          // accept API keys in the query string of GET requests
+
+	mailer              email.Mailer
+	alertRecipients     []string      // addresses of offline alerts, none disables alerts
+	healthCheckInterval time.Duration // interval of the device health monitor
+	offlineAfter        time.Duration // silence after which a device is offline
+	offlineAlertAfter   time.Duration // time offline after which a room's reader is alerted about
 }
 
 // UserStore defines operations needed from the user store
 type UserStore interface {
 	GetCustomUserByTagID(ctx context.Context, tagID string) (*models.CustomUser, error)
+	GetCustomUserByID(ctx context.Context, id int64) (*models.CustomUser, error)
+	UpdateTagID(ctx context.Context, userID int64, tagID string) error
 }
 
 // StudentStore defines operations needed from the student store
 type StudentStore interface {
 	GetStudentByCustomUserID(ctx context.Context, customUserID int64) (*models.Student, error)
-	UpdateStudentLocation(ctx context.Context, id int64, locations map[string]bool) error
+	TransitionStudentLocation(ctx context.Context, t *models.LocationTransition) error
 	ListStudents(ctx context.Context, filters map[string]interface{}) ([]models.Student, error)
 	CreateStudentVisit(ctx context.Context, studentID, roomID, timespanID int64) (*models.Visit, error)
 	GetStudentVisits(ctx context.Context, studentID int64, date *time.Time) ([]models.Visit, error)
 	GetRoomVisits(ctx context.Context, roomID int64, date *time.Time, active bool) ([]models.Visit, error)
+	GetAccessibleGroupIDs(ctx context.Context, accountID int64) ([]int64, error)
 }
 
 // TimespanStore defines operations needed from the timespan store
@@ -53,11 +85,33 @@ type TimespanStore interface {
 func NewAPI(db *bun.DB) (*API, error) {
 	store := NewRFIDStore(db)
 	api := &API{
-		store: store,
+		store:        store,
+		events:       NewEventBus(),
+		maxClockSkew: viper.GetDuration("rfid_max_clock_skew"),
+		maxReadAge:   viper.GetDuration("rfid_max_read_age"),
+
+		debounce:            newDebouncer(store, viper.GetDuration("rfid_tag_debounce_window")),
+		debounceDefault:     viper.GetDuration("rfid_debounce_window"),
+		antiPassbackDefault: viper.GetString("rfid_anti_passback"),
+
+		syncIntervalDefault: viper.GetDuration("rfid_sync_interval"),
+
+		apiKeyGracePeriod: viper.GetDuration("rfid_api_key_grace_period"),
+		apiKeyQuery:       viper.GetBool("rfid_api_key_query"),
+
+		alertRecipients:     splitList(viper.GetString("rfid_alert_email")),
+		healthCheckInterval: viper.GetDuration("rfid_health_check_interval"),
+		offlineAfter:        viper.GetDuration("rfid_offline_after"),
+		offlineAlertAfter:   viper.GetDuration("rfid_offline_alert_after"),
 	}
 	return api, nil
 }
 
+// SetMailer sets the mailer for device offline alerts
+func (a *API) SetMailer(mailer email.Mailer) {
+	a.mailer = mailer
+}
+
 // SetUserStore sets the user store for RFID API
 func (a *API) SetUserStore(userStore UserStore) {
 	a.userStore = userStore
@@ -73,6 +127,13 @@ func (a *API) SetTimespanStore(timespanStore TimespanStore) {
 	a.timespanStore = timespanStore
 }
 
+// CloseEventStreams ends all open event streams, e.g. on server shutdown
+func (a *API) CloseEventStreams() {
+	if a.events != nil {
+		a.events.Close()
+	}
+}
+
 // IsTestMode is a flag that can be set to disable authentication in tests
 var IsTestMode bool = false
 
@@ -90,8 +151,7 @@ func (a *API) apiKeyAuthMiddleware(next http.Handler) http.Handler {
 				DeviceID:    "test-device-id",
 				Name:        "Test Device",
 				Description: "Test device for unit tests",
-				Status:      "active",
-				APIKey:      "test_api_key",
+				Status:      DeviceStatusActive,
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			}
@@ -117,24 +177,14 @@ func (a *API) apiKeyAuthMiddleware(next http.Handler) http.Handler {
 			})
 		}
 
-		// Get API key from Authorization header
-		apiKey := r.Header.Get("Authorization")
-		if apiKey == "" {
-			// Also check query string for API key (for GET requests only)
-			if r.Method == "GET" {
-				apiKey = r.URL.Query().Get("api_key")
-			}
-		}
-
+		// Get API key from Authorization header, or the query string if enabled
+		apiKey := a.requestAPIKey(r)
 		if apiKey == "" {
 			log.Error("Missing API key")
 			render.Render(w, r, ErrUnauthorized(fmt.Errorf("API key required")))
 			return
 		}
 
-		// Strip "Bearer " prefix if present
-		apiKey = strings.TrimPrefix(apiKey, "Bearer ")
-
 		// Validate API key and get device
 		device, err := a.store.GetDeviceByAPIKey(ctx, apiKey)
 		if err != nil {
@@ -143,8 +193,22 @@ func (a *API) apiKeyAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Check if the key was revoked
+		if device.RevokedAt != nil {
+			log.WithField("device_id", device.DeviceID).Error("Revoked API key used")
+			render.Render(w, r, ErrUnauthorized(fmt.Errorf("API key revoked")))
+			return
+		}
+
+		// Registrations are allowed the status endpoint only until approved
+		if device.Status == DeviceStatusPending {
+			log.WithField("device_id", device.DeviceID).Warning("Pending device attempted access")
+			render.Render(w, r, ErrUnauthorized(fmt.Errorf("device is pending approval")))
+			return
+		}
+
 		// Check if device is active
-		if device.Status != "active" {
+		if device.Status != DeviceStatusActive {
 			log.WithField("device_id", device.DeviceID).Error("Inactive device attempted access")
 			render.Render(w, r, ErrUnauthorized(fmt.Errorf("device is not active")))
 			return
@@ -167,9 +231,10 @@ func (a *API) apiKeyAuthMiddleware(next http.Handler) http.Handler {
 			ipAddress = strings.Split(ipAddress, ":")[0]
 		}
 
-		// Update last IP, but don't fail the request if it doesn't work
+		// Update last IP and use, but don't fail the request if it doesn't work
 		err = a.store.UpdateDevice(ctx, device.DeviceID, map[string]interface{}{
-			"last_ip": ipAddress,
+			"last_ip":      ipAddress,
+			"last_used_at": time.Now(),
 		})
 		if err != nil {
 			log.WithError(err).Warning("Failed to update device last_ip")
@@ -195,7 +260,8 @@ func (a *API) Router() *chi.Mux {
 
 		// Endpoints for RFID Python Daemon
 		r.Post("/tag", a.handleTagRead)
-		r.Get("/tags", a.handleGetAllTags)
+		r.Get("/tags", a.handleListTags)
+		r.Get("/tags/hourly", a.handleHourlyReadCounts)
 
 		// Student tracking with RFID
 		r.Post("/track-student", a.handleStudentTracking)
@@ -212,6 +278,10 @@ func (a *API) Router() *chi.Mux {
 
 		// Endpoints for Tauri App
 		r.Post("/app/sync", a.handleTauriSync)
+		r.Post("/heartbeat", a.handleHeartbeat)
+		r.Get("/app/config", a.handleGetAppConfig)
+		r.Get("/app/commands", a.handlePollCommands)
+		r.Post("/app/commands/{id}/ack", a.handleAckCommand)
 
 		// Protected device management endpoints
 		r.Route("/devices", func(r chi.Router) {
@@ -219,12 +289,45 @@ func (a *API) Router() *chi.Mux {
 			r.Get("/{device_id}", a.handleGetDevice)
 			r.Put("/{device_id}", a.handleUpdateDevice)
 			r.Get("/{device_id}/sync-history", a.handleGetDeviceSyncHistory)
+			r.Post("/{device_id}/rotate-key", a.handleRotateOwnKey)
 		})
+
+		// Reader registry mapping readers to locations, maintained by admins
+		r.Get("/readers", a.handleListReaders)
+		r.Get("/readers/{reader_id}", a.handleGetReader)
 	})
 
 	return r
 }
 
+// EventsRouter provides the long-lived event stream route. It is kept apart
+// from Router so it can be mounted outside of the request timeout. Besides
+// devices with their API key, staff with read permission may subscribe with
+// the access token decoded by verifier.
+func (a *API) EventsRouter(verifier func(http.Handler) http.Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(verifier)
+	r.Use(a.eventsAuthMiddleware)
+	r.Get("/", a.handleEventStream)
+	return r
+}
+
+// eventsAuthMiddleware authenticates requests carrying a signed access token with
+// jwt.Authenticator and requires read permission, all others are authenticated
+// as device by their API key. Both are sent as bearer token, API keys are never
+// decoded as access token.
+func (a *API) eventsAuthMiddleware(next http.Handler) http.Handler {
+	staffAuth := jwt.Authenticator(authorize.RequiresPermission(authorize.PermRead)(next))
+	deviceAuth := a.apiKeyAuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := jwtauth.FromContext(r.Context()); err == nil {
+			staffAuth.ServeHTTP(w, r)
+			return
+		}
+		deviceAuth.ServeHTTP(w, r)
+	})
+}
+
 // handleTagRead processes RFID tag reads from the Raspberry Pi
 func (a *API) handleTagRead(w http.ResponseWriter, r *http.Request) {
 	data := &TagReadRequest{}
@@ -233,25 +336,66 @@ func (a *API) handleTagRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tag, err := a.store.SaveTag(r.Context(), data.TagID, data.ReaderID)
+	readAt, err := a.parseReadTime(data.ReadAt)
 	if err != nil {
-		render.Render(w, r, ErrInternalServer(err))
+		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
-	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, tag)
-}
+	ctx := r.Context()
+	log := logging.GetLogEntry(r)
+	if a.debounced(ctx, log, data.TagID, data.ReaderID, readAt) {
+		// A card held to the reader, the first read is processed only
+		render.JSON(w, r, &TagReadResponse{Decision: DecisionDebounced})
+		return
+	}
 
-// handleGetAllTags returns all stored RFID tags
-func (a *API) handleGetAllTags(w http.ResponseWriter, r *http.Request) {
-	tags, err := a.store.GetAllTags(r.Context())
+	tag, err := a.store.SaveTag(ctx, requestDeviceID(r), data.TagID, data.ReaderID, data.EventID, readAt)
+	if errors.Is(err, ErrDuplicateEvent) {
+		// A retried read, already processed
+		render.JSON(w, r, &TagReadResponse{Tag: tag, Duplicate: true})
+		return
+	}
 	if err != nil {
 		render.Render(w, r, ErrInternalServer(err))
 		return
 	}
 
-	render.JSON(w, r, tags)
+	// Reads of registered readers also track the student
+	decision := DecisionProcessed
+	if a.userStore != nil && a.studentStore != nil {
+		d, err := a.trackTagRead(ctx, log, data.TagID, data.ReaderID, readAt)
+		if err != nil {
+			// The event is not marked processed, a retry tracks the student again
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
+		if d != "" {
+			decision = d
+		}
+	}
+	a.markProcessed(ctx, log, tag)
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &TagReadResponse{Tag: tag, Decision: decision})
+}
+
+// markProcessed marks the reads as processed once they were handled without
+// error. Until then a retried event is processed again and the reads don't
+// debounce further reads of their tags.
+func (a *API) markProcessed(ctx context.Context, log logrus.FieldLogger, tags ...*Tag) {
+	reads := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag != nil {
+			reads = append(reads, *tag)
+		}
+	}
+	if len(reads) == 0 {
+		return
+	}
+	if err := a.store.MarkTagsProcessed(ctx, reads); err != nil {
+		log.WithError(err).Error("Failed to mark tag reads processed")
+	}
 }
 
 // handleTauriSync processes synchronization requests from the Tauri app
@@ -303,8 +447,8 @@ func (a *API) handleTauriSync(w http.ResponseWriter, r *http.Request) {
 		"app_version": data.AppVersion,
 	}).Info("Processing Tauri sync request")
 
-	// Save the tags from the Tauri app
-	err := a.store.SaveTauriTags(ctx, data.DeviceID, data.Data)
+	// Save the tags from the Tauri app, reads retried with the same event ID are stored once
+	duplicates, err := a.store.SaveTauriTags(ctx, data.DeviceID, data.Data)
 	if err != nil {
 		log.WithError(err).Error("Failed to save tags from Tauri app")
 		render.Render(w, r, ErrInternalServer(err))
@@ -319,62 +463,33 @@ func (a *API) handleTauriSync(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Record sync regardless of success of student processing
-	err = a.store.RecordDeviceSync(ctx, data.DeviceID, ipAddress, data.AppVersion, len(data.Data))
+	err = a.store.RecordDeviceSync(ctx, data.DeviceID, ipAddress, data.AppVersion, data.ConfigVersion, len(data.Data))
 	if err != nil {
 		log.WithError(err).Warning("Failed to record device sync")
 		// Continue processing anyway
 	}
 
-	// Process student tracking for all tags
+	// Replay the reads in the order they were read to track the students
+	var results []SyncTagResult
 	processedCount := 0
 	if a.userStore != nil && a.studentStore != nil {
-		for _, tag := range data.Data {
-			// Try to find the user by tag ID
-			user, err := a.userStore.GetCustomUserByTagID(ctx, tag.TagID)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"tag_id": tag.TagID,
-					"error":  err.Error(),
-				}).Debug("No user found for tag")
-				continue
-			}
-
-			// Get student information
-			student, err := a.studentStore.GetStudentByCustomUserID(ctx, user.ID)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"user_id": user.ID,
-					"error":   err.Error(),
-				}).Debug("User found but no student record")
-				continue
-			}
+		results, processedCount = a.replaySync(ctx, log, data.DeviceID, data.Data, duplicates)
+	}
 
-			// Update student location based on reader type
-			// This is a simplified version - in a real implementation, you would
-			// determine the location type based on the reader information
-			locationUpdates := make(map[string]bool)
-			locationUpdates["in_house"] = true // Default for Tauri app is in-house
+	// Reads whose replay failed stay unprocessed and are replayed when sent again
+	a.markProcessed(ctx, log, processedReads(data.Data, results)...)
 
-			err = a.studentStore.UpdateStudentLocation(ctx, student.ID, locationUpdates)
-			if err != nil {
-				log.WithError(err).Error("Failed to update student location")
-				continue
-			}
-
-			processedCount++
-			log.WithFields(logrus.Fields{
-				"tag_id":     tag.TagID,
-				"student_id": student.ID,
-				"user_name":  user.FirstName + " " + user.SecondName,
-			}).Info("Student location tracked via Tauri sync")
-		}
-	}
+	log.WithFields(logrus.Fields{
+		"device_id": data.DeviceID,
+		"processed": processedCount,
+	}).Info("Student locations tracked via Tauri sync")
 
 	// Return success response
 	response := &TauriSyncResponse{
 		Success: true,
 		Message: fmt.Sprintf("Successfully synced %d tags, processed %d student locations",
 			len(data.Data), processedCount),
+		Results: results,
 	}
 
 	render.JSON(w, r, response)
@@ -398,13 +513,8 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Check for API key in both query string and Authorization header
-	apiKey := r.URL.Query().Get("api_key")
-	if apiKey == "" {
-		apiKey = r.Header.Get("Authorization")
-		// Strip "Bearer " prefix if present
-		apiKey = strings.TrimPrefix(apiKey, "Bearer ")
-	}
+	// Check for API key in the Authorization header, or the query string if enabled
+	apiKey := a.requestAPIKey(r)
 
 	// Status requests can work without authentication, but if API key is provided, validate it
 	var device *TauriDevice
@@ -414,7 +524,10 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			log.WithError(err).Warning("Invalid API key in status request")
 			// Continue anyway, but don't include device-specific info
-		} else if device.Status != "active" {
+		} else if device.RevokedAt != nil {
+			log.WithField("device_id", device.DeviceID).Warning("Revoked API key in status request")
+			device = nil
+		} else if device.Status != DeviceStatusActive {
 			log.WithField("device_id", device.DeviceID).Warning("Inactive device requested status")
 			// Continue anyway, but don't include device-specific info
 		}
@@ -456,7 +569,7 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 
 		// WC students
 		filters = map[string]interface{}{
-			"wc": true,
+			"location": models.LocationWC,
 		}
 
 		studentsInWC, err := a.studentStore.ListStudents(ctx, filters)
@@ -466,7 +579,7 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 
 		// Schoolyard students
 		filters = map[string]interface{}{
-			"school_yard": true,
+			"location": models.LocationSchoolYard,
 		}
 
 		studentsInSchoolYard, err := a.studentStore.ListStudents(ctx, filters)
@@ -483,6 +596,11 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 		Version:   "1.0.0", // You might want to get this from your app configuration
 	}
 
+	// Devices poll the status to learn about the approval of their registration
+	if device != nil {
+		status.DeviceStatus = device.Status
+	}
+
 	// Record the status check if we have a valid device
 	if device != nil {
 		ipAddress := r.RemoteAddr
@@ -504,18 +622,32 @@ func (a *API) handleTauriStatus(w http.ResponseWriter, r *http.Request) {
 type StudentTrackingRequest struct {
 	TagID        string `json:"tag_id"`
 	ReaderID     string `json:"reader_id"`
-	LocationType string `json:"location_type"` // "entry", "wc", "schoolyard", or "exit"
+	LocationType string `json:"location_type"`      // "entry", "wc", "schoolyard", "bus", "pickup" or "exit", empty for registered readers
+	EventID      string `json:"event_id,omitempty"` // Optional client event ID making retries safe
+	ReadAt       string `json:"read_at,omitempty"`  // Optional RFC 3339 time of the read, defaults to now
+}
+
+// trackingLocations maps the location types of tracking readers to student locations
+var trackingLocations = map[string]models.LocationState{
+	"entry":      models.LocationInHouse,
+	"wc":         models.LocationWC,
+	"schoolyard": models.LocationSchoolYard,
+	"bus":        models.LocationBus,
+	"pickup":     models.LocationPickedUp,
+	"exit":       models.LocationAbsent,
 }
 
 // Bind preprocesses a StudentTrackingRequest
 func (req *StudentTrackingRequest) Bind(r *http.Request) error {
-	return nil
+	return validateEventID(req.EventID)
 }
 
 // StudentTrackingResponse is the response for student tracking
 type StudentTrackingResponse struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Decision  string `json:"decision,omitempty"` // how the read was handled
 	StudentID int64  `json:"student_id,omitempty"`
 	Name      string `json:"name,omitempty"`
 	Location  string `json:"location,omitempty"`
@@ -547,14 +679,52 @@ func (a *API) handleRoomEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Readers registered as room readers don't need to send the room
+	if data.RoomID == 0 {
+		roomID, err := a.readerRoom(ctx, data.ReaderID)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		data.RoomID = roomID
+	}
+
 	log.WithFields(logrus.Fields{
 		"tag_id":    data.TagID,
 		"room_id":   data.RoomID,
 		"reader_id": data.ReaderID,
 	}).Info("Processing room entry request")
 
+	readAt, err := a.parseReadTime(data.ReadAt)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// Repeated reads of a card held to the reader are not processed
+	if a.debounced(ctx, log, data.TagID, data.ReaderID, readAt) {
+		log.Debug("Debounced room entry read")
+		render.JSON(w, r, &OccupancyResponse{
+			Success:  true,
+			Message:  "Repeated read, debounced",
+			Decision: DecisionDebounced,
+			RoomID:   data.RoomID,
+		})
+		return
+	}
+
 	// First log the tag read
-	_, err := a.store.SaveTag(ctx, data.TagID, data.ReaderID)
+	tag, err := a.store.SaveTag(ctx, requestDeviceID(r), data.TagID, data.ReaderID, data.EventID, readAt)
+	if errors.Is(err, ErrDuplicateEvent) {
+		log.WithField("event_id", data.EventID).Info("Duplicate room entry event")
+		render.JSON(w, r, &OccupancyResponse{
+			Success:   true,
+			Message:   "Duplicate event, already processed",
+			Duplicate: true,
+			RoomID:    data.RoomID,
+		})
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to save tag read")
 		// Continue anyway, as this is just for logging
@@ -569,14 +739,16 @@ func (a *API) handleRoomEntry(w http.ResponseWriter, r *http.Request) {
 	// Find the user by tag ID
 	user, err := a.userStore.GetCustomUserByTagID(ctx, data.TagID)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"tag_id": data.TagID,
-			"error":  err.Error(),
-		}).Info("Tag scanned but no user found")
+		message, err := a.unresolvedTag(ctx, log, data.TagID, data.ReaderID, readAt, user, err)
+		if err != nil {
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
 
+		a.markProcessed(ctx, log, tag)
 		render.JSON(w, r, &OccupancyResponse{
 			Success: false,
-			Message: "No user found with this tag ID",
+			Message: message,
 		})
 		return
 	}
@@ -594,6 +766,7 @@ func (a *API) handleRoomEntry(w http.ResponseWriter, r *http.Request) {
 			"error":   err.Error(),
 		}).Info("User found but no student record")
 
+		a.markProcessed(ctx, log, tag)
 		render.JSON(w, r, &OccupancyResponse{
 			Success: false,
 			Message: "User found but no student record",
@@ -601,73 +774,40 @@ func (a *API) handleRoomEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create a timespan for the visit
-	var timespan *models.Timespan
-	if a.timespanStore != nil {
-		// Create timespan with start time now and no end time
-		timespan, err = a.timespanStore.CreateTimespan(ctx, time.Now(), nil)
-		if err != nil {
-			log.WithError(err).Error("Failed to create timespan for visit")
-			render.Render(w, r, ErrInternalServer(err))
-			return
-		}
-
-		// Create a proper visit record
-		visit, err := a.studentStore.CreateStudentVisit(ctx, student.ID, data.RoomID, timespan.ID)
-		if err != nil {
-			log.WithError(err).Error("Failed to create student visit")
-			// Don't return, we'll still update location
-		} else {
-			log.WithFields(logrus.Fields{
-				"visit_id":    visit.ID,
-				"student_id":  student.ID,
-				"room_id":     data.RoomID,
-				"timespan_id": timespan.ID,
-			}).Info("Created visit record")
-		}
-	}
-
-	// Record the room entry in the RFID system as well
-	err = a.store.RecordRoomEntry(ctx, student.ID, data.RoomID)
-	if err != nil {
-		log.WithError(err).Error("Failed to record room entry")
-		render.Render(w, r, ErrInternalServer(err))
+	// Reads recorded before the current location can't change it anymore
+	if staleRead(student, readAt) {
+		log.WithField("student_id", student.ID).Info("Stale room entry read")
+		a.markProcessed(ctx, log, tag)
+		render.JSON(w, r, &OccupancyResponse{
+			Success:   false,
+			Message:   staleReadMessage,
+			Decision:  DecisionStale,
+			StudentID: student.ID,
+			RoomID:    data.RoomID,
+		})
 		return
 	}
 
-	// Update student location to in_house
-	locationUpdates := map[string]bool{
-		"in_house":    true,
-		"wc":          false,
-		"school_yard": false,
-	}
-
-	err = a.studentStore.UpdateStudentLocation(ctx, student.ID, locationUpdates)
-	if err != nil {
-		log.WithError(err).Warning("Failed to update student location, but room entry was recorded")
+	// A second entry without exit is handled by the anti-passback policy
+	entering, decision := true, DecisionProcessed
+	if student.Location == models.LocationRoom && sameRoom(student.LocationRoomID, &data.RoomID) {
+		entering, decision = a.roomPassback(ctx, log, data.ReaderID, true)
 	}
-
-	// Get the updated room occupancy
-	occupancy, err := a.store.GetRoomOccupancy(ctx, data.RoomID)
-	studentCount := 0
-	if err == nil {
-		studentCount = occupancy.StudentCount
+	if decision == DecisionIgnored {
+		log.WithField("student_id", student.ID).Info("Repeated room entry ignored")
+		a.markProcessed(ctx, log, tag)
+		render.JSON(w, r, a.ignoredPassage(ctx, student, data.RoomID, "Student already in room, entry ignored"))
+		return
 	}
 
-	// Return success response
-	response := &OccupancyResponse{
-		Success:      true,
-		Message:      "Student entered room successfully",
-		StudentID:    student.ID,
-		RoomID:       data.RoomID,
-		StudentCount: studentCount,
+	response, err := a.roomPassage(ctx, log, user, student, data.RoomID, data.ReaderID, readAt, entering)
+	if err != nil {
+		// The event is not marked processed, a retry moves the student again
+		render.Render(w, r, ErrInternalServer(err))
+		return
 	}
-
-	log.WithFields(logrus.Fields{
-		"student_id":    student.ID,
-		"room_id":       data.RoomID,
-		"student_count": studentCount,
-	}).Info("Student entered room")
+	response.Decision = decision
+	a.markProcessed(ctx, log, tag)
 
 	render.JSON(w, r, response)
 }
@@ -698,14 +838,52 @@ func (a *API) handleRoomExit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Readers registered as room readers don't need to send the room
+	if data.RoomID == 0 {
+		roomID, err := a.readerRoom(ctx, data.ReaderID)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		data.RoomID = roomID
+	}
+
 	log.WithFields(logrus.Fields{
 		"tag_id":    data.TagID,
 		"room_id":   data.RoomID,
 		"reader_id": data.ReaderID,
 	}).Info("Processing room exit request")
 
+	readAt, err := a.parseReadTime(data.ReadAt)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// Repeated reads of a card held to the reader are not processed
+	if a.debounced(ctx, log, data.TagID, data.ReaderID, readAt) {
+		log.Debug("Debounced room exit read")
+		render.JSON(w, r, &OccupancyResponse{
+			Success:  true,
+			Message:  "Repeated read, debounced",
+			Decision: DecisionDebounced,
+			RoomID:   data.RoomID,
+		})
+		return
+	}
+
 	// First log the tag read
-	_, err := a.store.SaveTag(ctx, data.TagID, data.ReaderID)
+	tag, err := a.store.SaveTag(ctx, requestDeviceID(r), data.TagID, data.ReaderID, data.EventID, readAt)
+	if errors.Is(err, ErrDuplicateEvent) {
+		log.WithField("event_id", data.EventID).Info("Duplicate room exit event")
+		render.JSON(w, r, &OccupancyResponse{
+			Success:   true,
+			Message:   "Duplicate event, already processed",
+			Duplicate: true,
+			RoomID:    data.RoomID,
+		})
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to save tag read")
 		// Continue anyway, as this is just for logging
@@ -720,14 +898,16 @@ func (a *API) handleRoomExit(w http.ResponseWriter, r *http.Request) {
 	// Find the user by tag ID
 	user, err := a.userStore.GetCustomUserByTagID(ctx, data.TagID)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"tag_id": data.TagID,
-			"error":  err.Error(),
-		}).Info("Tag scanned but no user found")
+		message, err := a.unresolvedTag(ctx, log, data.TagID, data.ReaderID, readAt, user, err)
+		if err != nil {
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
 
+		a.markProcessed(ctx, log, tag)
 		render.JSON(w, r, &OccupancyResponse{
 			Success: false,
-			Message: "No user found with this tag ID",
+			Message: message,
 		})
 		return
 	}
@@ -745,6 +925,7 @@ func (a *API) handleRoomExit(w http.ResponseWriter, r *http.Request) {
 			"error":   err.Error(),
 		}).Info("User found but no student record")
 
+		a.markProcessed(ctx, log, tag)
 		render.JSON(w, r, &OccupancyResponse{
 			Success: false,
 			Message: "User found but no student record",
@@ -752,25 +933,200 @@ func (a *API) handleRoomExit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reads recorded before the current location can't change it anymore
+	if staleRead(student, readAt) {
+		log.WithField("student_id", student.ID).Info("Stale room exit read")
+		a.markProcessed(ctx, log, tag)
+		render.JSON(w, r, &OccupancyResponse{
+			Success:   false,
+			Message:   staleReadMessage,
+			Decision:  DecisionStale,
+			StudentID: student.ID,
+			RoomID:    data.RoomID,
+		})
+		return
+	}
+
+	// An exit without entry is handled by the anti-passback policy
+	entering, decision := false, DecisionProcessed
+	if student.Location != models.LocationRoom || !sameRoom(student.LocationRoomID, &data.RoomID) {
+		entering, decision = a.roomPassback(ctx, log, data.ReaderID, false)
+	}
+	if decision == DecisionIgnored {
+		log.WithField("student_id", student.ID).Info("Repeated room exit ignored")
+		a.markProcessed(ctx, log, tag)
+		render.JSON(w, r, a.ignoredPassage(ctx, student, data.RoomID, "Student not in room, exit ignored"))
+		return
+	}
+
+	response, err := a.roomPassage(ctx, log, user, student, data.RoomID, data.ReaderID, readAt, entering)
+	if err != nil {
+		// The event is not marked processed, a retry moves the student again
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+	response.Decision = decision
+	a.markProcessed(ctx, log, tag)
+
+	render.JSON(w, r, response)
+}
+
+// roomPassage moves the student into or out of the room at the given time,
+// publishes the matching location event and returns the updated occupancy
+func (a *API) roomPassage(ctx context.Context, log logrus.FieldLogger, user *models.CustomUser, student *models.Student, roomID int64, readerID string, at time.Time, entering bool) (*OccupancyResponse, error) {
+	event := LocationEvent{
+		Type:      EventRoomEntry,
+		StudentID: student.ID,
+		Name:      user.FirstName + " " + user.SecondName,
+		GroupID:   student.GroupID,
+		RoomID:    roomID,
+		Location:  string(models.LocationRoom),
+		ReaderID:  readerID,
+		Timestamp: at,
+	}
+	message := "Student entered room successfully"
+	if entering {
+		if err := a.enterRoom(ctx, log, student, roomID, readerID, at); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := a.exitRoom(ctx, log, student, roomID, readerID, at); err != nil {
+			return nil, err
+		}
+		event.Type, event.Location = EventRoomExit, string(models.LocationInHouse)
+		message = "Student exited room successfully"
+	}
+
+	// Get the updated room occupancy
+	studentCount, capacity, freeSeats := a.roomCounts(ctx, roomID)
+
+	log.WithFields(logrus.Fields{
+		"student_id":    student.ID,
+		"room_id":       roomID,
+		"student_count": studentCount,
+	}).Info(message)
+
+	event.StudentCount = studentCount
+	a.events.Publish(event)
+
+	return &OccupancyResponse{
+		Success:      true,
+		Message:      message,
+		StudentID:    student.ID,
+		RoomID:       roomID,
+		StudentCount: studentCount,
+		Capacity:     capacity,
+		FreeSeats:    freeSeats,
+	}, nil
+}
+
+// roomPassback applies the anti-passback policy of the reader to a repeated room
+// entry or exit. It returns whether the read enters the room and the decision.
+func (a *API) roomPassback(ctx context.Context, log logrus.FieldLogger, readerID string, entering bool) (bool, string) {
+	reader, err := a.lookupReader(ctx, readerID)
+	if err != nil {
+		log.WithError(err).WithField("reader_id", readerID).Error("Failed to look up reader")
+	}
+
+	switch a.antiPassback(reader) {
+	case AntiPassbackToggle:
+		return !entering, DecisionToggled
+	case AntiPassbackOff:
+		return entering, DecisionProcessed
+	}
+	return entering, DecisionIgnored
+}
+
+// ignoredPassage returns the response for a room entry or exit ignored by the
+// anti-passback policy
+func (a *API) ignoredPassage(ctx context.Context, student *models.Student, roomID int64, message string) *OccupancyResponse {
+	studentCount, capacity, freeSeats := a.roomCounts(ctx, roomID)
+	return &OccupancyResponse{
+		Success:      true,
+		Message:      message,
+		Decision:     DecisionIgnored,
+		StudentID:    student.ID,
+		RoomID:       roomID,
+		StudentCount: studentCount,
+		Capacity:     capacity,
+		FreeSeats:    freeSeats,
+	}
+}
+
+// enterRoom opens a visit of the student in the room at the given time and moves
+// the student there. The location of the student is updated in place.
+func (a *API) enterRoom(ctx context.Context, log logrus.FieldLogger, student *models.Student, roomID int64, readerID string, at time.Time) error {
+	// Create a timespan for the visit
+	if a.timespanStore != nil {
+		// Create timespan starting at the entry and no end time
+		timespan, err := a.timespanStore.CreateTimespan(ctx, at, nil)
+		if err != nil {
+			log.WithError(err).Error("Failed to create timespan for visit")
+			return err
+		}
+
+		// Create a proper visit record
+		visit, err := a.studentStore.CreateStudentVisit(ctx, student.ID, roomID, timespan.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to create student visit")
+			// Don't return, we'll still update location
+		} else {
+			log.WithFields(logrus.Fields{
+				"visit_id":    visit.ID,
+				"student_id":  student.ID,
+				"room_id":     roomID,
+				"timespan_id": timespan.ID,
+			}).Info("Created visit record")
+		}
+	}
+
+	// Record the room entry in the RFID system as well. This also closes any
+	// visit the student left open in another room without scanning out.
+	err := a.store.RecordRoomEntry(ctx, student.ID, roomID, at)
+	if err != nil {
+		log.WithError(err).Error("Failed to record room entry")
+		return err
+	}
+
+	// Update student location to the room
+	err = a.studentStore.TransitionStudentLocation(ctx, &models.LocationTransition{
+		StudentID:  student.ID,
+		ToLocation: models.LocationRoom,
+		RoomID:     &roomID,
+		Source:     readerID,
+		At:         at,
+	})
+	if err != nil {
+		log.WithError(err).Warning("Failed to update student location, but room entry was recorded")
+	} else {
+		student.Location, student.LocationRoomID, student.LocationSince = models.LocationRoom, &roomID, at
+	}
+
+	return nil
+}
+
+// exitRoom ends the visit of the student in the room at the given time and returns
+// the student to the building if tracked in that room
+func (a *API) exitRoom(ctx context.Context, log logrus.FieldLogger, student *models.Student, roomID int64, readerID string, at time.Time) error {
 	// Find active visit records for this student in this room and end them
 	if a.timespanStore != nil && a.studentStore != nil {
 		// Get room visits that are active (with a focus on those with null end times)
-		activeVisits, err := a.studentStore.GetRoomVisits(ctx, data.RoomID, nil, true)
+		activeVisits, err := a.studentStore.GetRoomVisits(ctx, roomID, nil, true)
 		if err != nil {
 			log.WithError(err).Warning("Failed to get active visits for this room")
 		} else {
 			// Look for this student's active visits
 			for _, visit := range activeVisits {
 				if visit.StudentID == student.ID && visit.Timespan != nil && visit.Timespan.EndTime == nil {
-					// Update the visit timespan to end now
-					err = a.timespanStore.UpdateTimespanEndTime(ctx, visit.TimespanID, time.Now())
+					// Update the visit timespan to end at the exit
+					err = a.timespanStore.UpdateTimespanEndTime(ctx, visit.TimespanID, at)
 					if err != nil {
 						log.WithError(err).Error("Failed to update timespan end time")
 					} else {
 						log.WithFields(logrus.Fields{
 							"visit_id":    visit.ID,
 							"student_id":  student.ID,
-							"room_id":     data.RoomID,
+							"room_id":     roomID,
 							"timespan_id": visit.TimespanID,
 						}).Info("Ended visit record")
 					}
@@ -780,36 +1136,38 @@ func (a *API) handleRoomExit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Record the room exit in the RFID system as well
-	err = a.store.RecordRoomExit(ctx, student.ID, data.RoomID)
+	err := a.store.RecordRoomExit(ctx, student.ID, roomID, at)
 	if err != nil {
 		log.WithError(err).Error("Failed to record room exit")
-		render.Render(w, r, ErrInternalServer(err))
-		return
-	}
-
-	// Get the updated room occupancy
-	occupancy, err := a.store.GetRoomOccupancy(ctx, data.RoomID)
-	studentCount := 0
-	if err == nil {
-		studentCount = occupancy.StudentCount
+		return err
 	}
 
-	// Return success response
-	response := &OccupancyResponse{
-		Success:      true,
-		Message:      "Student exited room successfully",
-		StudentID:    student.ID,
-		RoomID:       data.RoomID,
-		StudentCount: studentCount,
+	// Leaving the room the student is tracked in returns them to the building
+	if student.Location == models.LocationRoom && student.LocationRoomID != nil && *student.LocationRoomID == roomID {
+		err = a.studentStore.TransitionStudentLocation(ctx, &models.LocationTransition{
+			StudentID:  student.ID,
+			ToLocation: models.LocationInHouse,
+			Source:     readerID,
+			At:         at,
+		})
+		if err != nil {
+			log.WithError(err).Warning("Failed to update student location, but room exit was recorded")
+		} else {
+			student.Location, student.LocationRoomID, student.LocationSince = models.LocationInHouse, nil, at
+		}
 	}
 
-	log.WithFields(logrus.Fields{
-		"student_id":    student.ID,
-		"room_id":       data.RoomID,
-		"student_count": studentCount,
-	}).Info("Student exited room")
+	return nil
+}
 
-	render.JSON(w, r, response)
+// roomCounts returns the number of students, the capacity and the free seats of
+// the room, or zeros if the occupancy can not be determined
+func (a *API) roomCounts(ctx context.Context, roomID int64) (studentCount, capacity, freeSeats int) {
+	occupancy, err := a.store.GetRoomOccupancy(ctx, roomID)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return occupancy.StudentCount, occupancy.Capacity, occupancy.FreeSeats
 }
 
 // handleGetRoomOccupancy returns the current occupancy for a room
@@ -1084,7 +1442,7 @@ func (a *API) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
 	// Note: This is the only time the API key will be returned
 	response := &DeviceRegisterResponse{
 		Success:  true,
-		Message:  "Device registered successfully",
+		Message:  "Device registered, pending approval by an admin",
 		Device:   *device,
 		APIKey:   apiKey,
 		DeviceID: device.DeviceID,
@@ -1158,6 +1516,10 @@ func (a *API) handleUpdateDevice(w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("device ID is required")))
 		return
 	}
+	if deviceID != requestDeviceID(r) {
+		render.Render(w, r, ErrForbidden(fmt.Errorf("devices can only update themselves")))
+		return
+	}
 
 	// Parse the request
 	data := &DeviceUpdateRequest{}
@@ -1180,6 +1542,17 @@ func (a *API) handleUpdateDevice(w http.ResponseWriter, r *http.Request) {
 		updates["description"] = data.Description
 	}
 	if data.Status != "" {
+		// Registrations are approved or rejected by an admin only
+		current, err := a.store.GetDevice(ctx, deviceID)
+		if err != nil {
+			log.WithError(err).Error("Failed to get device")
+			render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+			return
+		}
+		if current.Status == DeviceStatusPending || current.Status == DeviceStatusRejected {
+			render.Render(w, r, ErrConflict(fmt.Errorf("device registration is not approved")))
+			return
+		}
 		updates["status"] = data.Status
 	}
 
@@ -1321,8 +1694,34 @@ func (a *API) handleStudentTracking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	readAt, err := a.parseReadTime(data.ReadAt)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	// Repeated reads of a card held to the reader are not processed
+	if a.debounced(ctx, log, data.TagID, data.ReaderID, readAt) {
+		log.Debug("Debounced student tracking read")
+		render.JSON(w, r, &StudentTrackingResponse{
+			Success:  true,
+			Message:  "Repeated read, debounced",
+			Decision: DecisionDebounced,
+		})
+		return
+	}
+
 	// First log the tag read
-	_, err := a.store.SaveTag(ctx, data.TagID, data.ReaderID)
+	tag, err := a.store.SaveTag(ctx, requestDeviceID(r), data.TagID, data.ReaderID, data.EventID, readAt)
+	if errors.Is(err, ErrDuplicateEvent) {
+		log.WithField("event_id", data.EventID).Info("Duplicate student tracking event")
+		render.JSON(w, r, &StudentTrackingResponse{
+			Success:   true,
+			Message:   "Duplicate event, already processed",
+			Duplicate: true,
+		})
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to save tag read")
 		// Continue anyway, as this is just for logging
@@ -1331,57 +1730,104 @@ func (a *API) handleStudentTracking(w http.ResponseWriter, r *http.Request) {
 	// Find the user by tag ID
 	user, err := a.userStore.GetCustomUserByTagID(ctx, data.TagID)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"tag_id":    data.TagID,
-			"reader_id": data.ReaderID,
-			"error":     err.Error(),
-		}).Info("Tag scanned but no user found")
+		message, err := a.unresolvedTag(ctx, log, data.TagID, data.ReaderID, readAt, user, err)
+		if err != nil {
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
 
+		a.markProcessed(ctx, log, tag)
 		render.JSON(w, r, &StudentTrackingResponse{
 			Success: false,
-			Message: "No user found with this tag ID",
+			Message: message,
 		})
 		return
 	}
 
-	// Determine the location update
-	locationUpdate := ""
-	locationUpdates := make(map[string]bool)
-
-	switch data.LocationType {
-	case "entry":
-		locationUpdate = "in-house"
-		locationUpdates["in_house"] = true
-		locationUpdates["wc"] = false
-		locationUpdates["school_yard"] = false
-	case "wc":
-		locationUpdate = "bathroom"
-		locationUpdates["in_house"] = true
-		locationUpdates["wc"] = true
-		locationUpdates["school_yard"] = false
-	case "schoolyard":
-		locationUpdate = "schoolyard"
-		locationUpdates["in_house"] = false
-		locationUpdates["wc"] = false
-		locationUpdates["school_yard"] = true
-	case "exit":
-		locationUpdate = "out"
-		locationUpdates["in_house"] = false
-		locationUpdates["wc"] = false
-		locationUpdates["school_yard"] = false
-	default:
-		locationUpdate = "unknown"
+	// Determine the location update, from the request or the reader registry
+	var reader *Reader
+	location, ok := trackingLocations[data.LocationType]
+	if data.LocationType == "" {
+		reader, err = a.lookupReader(ctx, data.ReaderID)
+		if err != nil {
+			log.WithError(err).Error("Failed to look up reader")
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
+		ok = reader != nil
+	}
+	if !ok {
+		message := "Unknown location type"
+		if data.LocationType == "" {
+			message = "Reader not registered, location type required"
+		}
+		a.markProcessed(ctx, log, tag)
+		render.JSON(w, r, &StudentTrackingResponse{
+			Success: false,
+			Message: message,
+		})
+		return
 	}
 
 	// Update student location if studentStore is configured
 	studentID := int64(0)
+	decision := DecisionProcessed
 	if a.studentStore != nil {
 		student, err := a.studentStore.GetStudentByCustomUserID(ctx, user.ID)
+		if err == nil && staleRead(student, readAt) {
+			// Reads recorded before the current location can't change it anymore
+			log.WithField("student_id", student.ID).Info("Stale student tracking read")
+			a.markProcessed(ctx, log, tag)
+			render.JSON(w, r, &StudentTrackingResponse{
+				Success:   false,
+				Message:   staleReadMessage,
+				Decision:  DecisionStale,
+				StudentID: user.ID,
+				Name:      user.FirstName + " " + user.SecondName,
+				Location:  string(student.Location),
+			})
+			return
+		}
 		if err == nil {
 			studentID = student.ID
-			err = a.studentStore.UpdateStudentLocation(ctx, student.ID, locationUpdates)
+			if reader != nil {
+				location, decision, err = a.trackReaderRead(ctx, log, reader, user, student, readAt)
+			} else {
+				err = a.studentStore.TransitionStudentLocation(ctx, &models.LocationTransition{
+					StudentID:  student.ID,
+					ToLocation: location,
+					Source:     data.ReaderID,
+					At:         readAt,
+				})
+			}
+			if errors.Is(err, models.ErrInvalidTransition) {
+				log.WithError(err).WithField("student_id", student.ID).Warning("Rejected student location change")
+				a.markProcessed(ctx, log, tag)
+				render.JSON(w, r, &StudentTrackingResponse{
+					Success:   false,
+					Message:   err.Error(),
+					StudentID: user.ID,
+					Name:      user.FirstName + " " + user.SecondName,
+					Location:  string(student.Location),
+				})
+				return
+			}
 			if err != nil {
+				// The event is not marked processed, a retry tracks the student again
 				log.WithError(err).Error("Failed to update student location")
+				render.Render(w, r, ErrInternalServer(err))
+				return
+			}
+			if reader == nil {
+				a.events.Publish(LocationEvent{
+					Type:      EventLocationChange,
+					StudentID: student.ID,
+					Name:      user.FirstName + " " + user.SecondName,
+					GroupID:   student.GroupID,
+					Location:  string(location),
+					ReaderID:  data.ReaderID,
+					Timestamp: readAt,
+				})
 			}
 		} else {
 			log.WithError(err).WithField("user_id", user.ID).Warning("Found user but not student record")
@@ -1396,14 +1842,139 @@ func (a *API) handleStudentTracking(w http.ResponseWriter, r *http.Request) {
 		"user_name":  user.FirstName + " " + user.SecondName,
 		"location":   data.LocationType,
 		"student_id": studentID,
+		"decision":   decision,
 	}).Info("Student location tracked")
 
+	a.markProcessed(ctx, log, tag)
+
 	// Return the tracking response
 	render.JSON(w, r, &StudentTrackingResponse{
 		Success:   true,
 		Message:   "Location tracking recorded",
+		Decision:  decision,
 		StudentID: user.ID,
 		Name:      user.FirstName + " " + user.SecondName,
-		Location:  locationUpdate,
+		Location:  string(location),
 	})
 }
+
+// eventStreamKeepAlive is the interval of comment lines sent to keep idle streams open
+const eventStreamKeepAlive = 30 * time.Second
+
+// accessibleGroupIDs returns the set of groups whose students the account may
+// see. Pedagogical specialists are restricted to the groups they supervise or
+// are granted through combined groups, for all other accounts a nil set is
+// returned, meaning no restriction.
+func (a *API) accessibleGroupIDs(ctx context.Context, claims jwt.AppClaims) (map[int64]bool, error) {
+	if slices.Contains(claims.Roles, authorize.RoleAdmin) || !slices.Contains(claims.Roles, authorize.RoleSpecialist) {
+		return nil, nil
+	}
+	if a.studentStore == nil {
+		return nil, fmt.Errorf("student store not configured")
+	}
+
+	groupIDs, err := a.studentStore.GetAccessibleGroupIDs(ctx, int64(claims.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	scope := make(map[int64]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		scope[id] = true
+	}
+	return scope, nil
+}
+
+// handleEventStream streams location events to the client as Server-Sent Events.
+// The stream can be narrowed down with the room_id and group_id query parameters.
+func (a *API) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logging.GetLogEntry(r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("streaming not supported")))
+		return
+	}
+
+	if a.events == nil {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("event bus not configured")))
+		return
+	}
+
+	filter := EventFilter{}
+	if roomIDStr := r.URL.Query().Get("room_id"); roomIDStr != "" {
+		roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("invalid room ID: %s", roomIDStr)))
+			return
+		}
+		filter.RoomID = roomID
+	}
+	if groupIDStr := r.URL.Query().Get("group_id"); groupIDStr != "" {
+		groupID, err := strconv.ParseInt(groupIDStr, 10, 64)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("invalid group ID: %s", groupIDStr)))
+			return
+		}
+		filter.GroupID = groupID
+	}
+
+	// Devices see their room only, pedagogical specialists their groups only
+	if device, ok := ctx.Value("device").(*TauriDevice); ok {
+		if device.RoomID == nil {
+			render.Render(w, r, ErrForbidden(fmt.Errorf("device is not bound to a room")))
+			return
+		}
+		if filter.RoomID != 0 && filter.RoomID != *device.RoomID {
+			render.Render(w, r, ErrForbidden(fmt.Errorf("device is bound to another room")))
+			return
+		}
+		filter.RoomID = *device.RoomID
+	} else if token, _, err := jwtauth.FromContext(ctx); token != nil && err == nil {
+		groupIDs, err := a.accessibleGroupIDs(ctx, jwt.ClaimsFromCtx(ctx))
+		if err != nil {
+			render.Render(w, r, ErrInternalServer(err))
+			return
+		}
+		filter.GroupIDs = groupIDs
+	}
+
+	events, unsubscribe := a.events.Subscribe(filter)
+	defer unsubscribe()
+
+	log.WithFields(logrus.Fields{
+		"room_id":  filter.RoomID,
+		"group_id": filter.GroupID,
+	}).Info("Event stream opened")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(e)
+			if err != nil {
+				log.WithError(err).Error("Failed to encode location event")
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, payload)
+			flusher.Flush()
+		}
+	}
+}
@@ -3,6 +3,7 @@ package rfid
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -24,29 +25,47 @@ type MockRFIDStore struct {
 	mock.Mock
 }
 
-func (m *MockRFIDStore) SaveTag(ctx context.Context, tagID, readerID string) (*Tag, error) {
-	args := m.Called(ctx, tagID, readerID)
+func (m *MockRFIDStore) SaveTag(ctx context.Context, deviceID, tagID, readerID, eventID string, readAt time.Time) (*Tag, error) {
+	args := m.Called(ctx, deviceID, tagID, readerID, eventID, readAt)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*Tag), args.Error(1)
 }
 
-func (m *MockRFIDStore) GetAllTags(ctx context.Context) ([]Tag, error) {
-	args := m.Called(ctx)
+func (m *MockRFIDStore) ListTags(ctx context.Context, f *TagFilter) ([]Tag, error) {
+	args := m.Called(ctx, f)
 	return args.Get(0).([]Tag), args.Error(1)
 }
 
+func (m *MockRFIDStore) GetHourlyReadCounts(ctx context.Context, f *TagFilter) ([]HourlyReadCount, error) {
+	args := m.Called(ctx, f)
+	return args.Get(0).([]HourlyReadCount), args.Error(1)
+}
+
 func (m *MockRFIDStore) GetTagStats(ctx context.Context) (int, error) {
 	args := m.Called(ctx)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockRFIDStore) SaveTauriTags(ctx context.Context, deviceID string, tags []SyncTag) error {
+func (m *MockRFIDStore) SaveTauriTags(ctx context.Context, deviceID string, tags []SyncTag) ([]int, error) {
 	args := m.Called(ctx, deviceID, tags)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockRFIDStore) MarkTagsProcessed(ctx context.Context, tags []Tag) error {
+	args := m.Called(ctx, tags)
 	return args.Error(0)
 }
 
+func (m *MockRFIDStore) NearestTagRead(ctx context.Context, tagID, readerID string, at time.Time, within time.Duration) (time.Time, error) {
+	args := m.Called(ctx, tagID, readerID, at, within)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
 func (m *MockRFIDStore) RegisterDevice(ctx context.Context, deviceID, name, description string) (*TauriDevice, string, error) {
 	args := m.Called(ctx, deviceID, name, description)
 	if args.Get(0) == nil {
@@ -71,6 +90,58 @@ func (m *MockRFIDStore) GetDeviceByAPIKey(ctx context.Context, apiKey string) (*
 	return args.Get(0).(*TauriDevice), args.Error(1)
 }
 
+func (m *MockRFIDStore) RotateDeviceKey(ctx context.Context, deviceID string, grace time.Duration, reinstate bool) (*TauriDevice, string, error) {
+	args := m.Called(ctx, deviceID, grace, reinstate)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*TauriDevice), args.String(1), args.Error(2)
+}
+
+func (m *MockRFIDStore) RevokeDeviceKey(ctx context.Context, deviceID string) error {
+	args := m.Called(ctx, deviceID)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) ReviewDevice(ctx context.Context, deviceID, status string, roomID *int64, reviewedBy int64) (*TauriDevice, error) {
+	args := m.Called(ctx, deviceID, status, roomID, reviewedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*TauriDevice), args.Error(1)
+}
+
+func (m *MockRFIDStore) RecordHeartbeat(ctx context.Context, device *TauriDevice) error {
+	args := m.Called(ctx, device)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) MarkDevicesOffline(ctx context.Context, silentSince time.Time) ([]TauriDevice, error) {
+	args := m.Called(ctx, silentSince)
+	return args.Get(0).([]TauriDevice), args.Error(1)
+}
+
+func (m *MockRFIDStore) ListDevicesToAlert(ctx context.Context, downSince time.Time) ([]TauriDevice, error) {
+	args := m.Called(ctx, downSince)
+	return args.Get(0).([]TauriDevice), args.Error(1)
+}
+
+func (m *MockRFIDStore) SetOfflineAlerted(ctx context.Context, deviceID string, at time.Time) error {
+	args := m.Called(ctx, deviceID, at)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) SetReaderAlerted(ctx context.Context, deviceID string, at time.Time) error {
+	args := m.Called(ctx, deviceID, at)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) LockHealthCheck(ctx context.Context) (func(), bool, error) {
+	args := m.Called(ctx)
+	unlock, _ := args.Get(0).(func())
+	return unlock, args.Bool(1), args.Error(2)
+}
+
 func (m *MockRFIDStore) UpdateDevice(ctx context.Context, deviceID string, updates map[string]interface{}) error {
 	args := m.Called(ctx, deviceID, updates)
 	return args.Error(0)
@@ -81,8 +152,8 @@ func (m *MockRFIDStore) ListDevices(ctx context.Context) ([]TauriDevice, error)
 	return args.Get(0).([]TauriDevice), args.Error(1)
 }
 
-func (m *MockRFIDStore) RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, tagsCount int) error {
-	args := m.Called(ctx, deviceID, ipAddress, appVersion, tagsCount)
+func (m *MockRFIDStore) RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, configVersion, tagsCount int) error {
+	args := m.Called(ctx, deviceID, ipAddress, appVersion, configVersion, tagsCount)
 	return args.Error(0)
 }
 
@@ -91,13 +162,49 @@ func (m *MockRFIDStore) GetDeviceSyncHistory(ctx context.Context, deviceID strin
 	return args.Get(0).([]DeviceSyncHistory), args.Error(1)
 }
 
-func (m *MockRFIDStore) RecordRoomEntry(ctx context.Context, studentID, roomID int64) error {
-	args := m.Called(ctx, studentID, roomID)
+func (m *MockRFIDStore) GetDeviceConfig(ctx context.Context, deviceID string) (*DeviceConfig, error) {
+	args := m.Called(ctx, deviceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DeviceConfig), args.Error(1)
+}
+
+func (m *MockRFIDStore) SaveDeviceConfig(ctx context.Context, cfg *DeviceConfig, roomID *int64) error {
+	args := m.Called(ctx, cfg, roomID)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) CreateDeviceCommand(ctx context.Context, cmd *DeviceCommand) error {
+	args := m.Called(ctx, cmd)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]DeviceCommand, error) {
+	args := m.Called(ctx, deviceID, limit)
+	return args.Get(0).([]DeviceCommand), args.Error(1)
+}
+
+func (m *MockRFIDStore) PollDeviceCommands(ctx context.Context, deviceID string, at time.Time) ([]DeviceCommand, error) {
+	args := m.Called(ctx, deviceID, at)
+	return args.Get(0).([]DeviceCommand), args.Error(1)
+}
+
+func (m *MockRFIDStore) AcknowledgeDeviceCommand(ctx context.Context, deviceID string, id int64, status, result string, at time.Time) (*DeviceCommand, error) {
+	args := m.Called(ctx, deviceID, id, status, result, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DeviceCommand), args.Error(1)
+}
+
+func (m *MockRFIDStore) RecordRoomEntry(ctx context.Context, studentID, roomID int64, at time.Time) error {
+	args := m.Called(ctx, studentID, roomID, at)
 	return args.Error(0)
 }
 
-func (m *MockRFIDStore) RecordRoomExit(ctx context.Context, studentID, roomID int64) error {
-	args := m.Called(ctx, studentID, roomID)
+func (m *MockRFIDStore) RecordRoomExit(ctx context.Context, studentID, roomID int64, at time.Time) error {
+	args := m.Called(ctx, studentID, roomID, at)
 	return args.Error(0)
 }
 
@@ -114,6 +221,52 @@ func (m *MockRFIDStore) GetCurrentRooms(ctx context.Context) ([]RoomOccupancyDat
 	return args.Get(0).([]RoomOccupancyData), args.Error(1)
 }
 
+func (m *MockRFIDStore) CreateReader(ctx context.Context, reader *Reader) error {
+	args := m.Called(ctx, reader)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) GetReader(ctx context.Context, readerID string) (*Reader, error) {
+	args := m.Called(ctx, readerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Reader), args.Error(1)
+}
+
+func (m *MockRFIDStore) ListReaders(ctx context.Context) ([]Reader, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]Reader), args.Error(1)
+}
+
+func (m *MockRFIDStore) UpdateReader(ctx context.Context, reader *Reader) error {
+	args := m.Called(ctx, reader)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) DeleteReader(ctx context.Context, readerID string) error {
+	args := m.Called(ctx, readerID)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) RecordUnknownTag(ctx context.Context, tagID, readerID string, readAt time.Time) error {
+	args := m.Called(ctx, tagID, readerID, readAt)
+	return args.Error(0)
+}
+
+func (m *MockRFIDStore) ListUnknownTags(ctx context.Context) ([]UnknownTag, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]UnknownTag), args.Error(1)
+}
+
+func (m *MockRFIDStore) DeleteUnknownTag(ctx context.Context, tagID string) error {
+	args := m.Called(ctx, tagID)
+	return args.Error(0)
+}
+
 // Mock UserStore
 type MockUserStore struct {
 	mock.Mock
@@ -127,6 +280,19 @@ func (m *MockUserStore) GetCustomUserByTagID(ctx context.Context, tagID string)
 	return args.Get(0).(*models.CustomUser), args.Error(1)
 }
 
+func (m *MockUserStore) GetCustomUserByID(ctx context.Context, id int64) (*models.CustomUser, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomUser), args.Error(1)
+}
+
+func (m *MockUserStore) UpdateTagID(ctx context.Context, userID int64, tagID string) error {
+	args := m.Called(ctx, userID, tagID)
+	return args.Error(0)
+}
+
 // Mock StudentStore
 type MockStudentStore struct {
 	mock.Mock
@@ -140,8 +306,8 @@ func (m *MockStudentStore) GetStudentByCustomUserID(ctx context.Context, customU
 	return args.Get(0).(*models.Student), args.Error(1)
 }
 
-func (m *MockStudentStore) UpdateStudentLocation(ctx context.Context, id int64, locations map[string]bool) error {
-	args := m.Called(ctx, id, locations)
+func (m *MockStudentStore) TransitionStudentLocation(ctx context.Context, t *models.LocationTransition) error {
+	args := m.Called(ctx, t)
 	return args.Error(0)
 }
 
@@ -168,6 +334,11 @@ func (m *MockStudentStore) GetRoomVisits(ctx context.Context, roomID int64, date
 	return args.Get(0).([]models.Visit), args.Error(1)
 }
 
+func (m *MockStudentStore) GetAccessibleGroupIDs(ctx context.Context, accountID int64) ([]int64, error) {
+	args := m.Called(ctx, accountID)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
 // Mock TimespanStore
 type MockTimespanStore struct {
 	mock.Mock
@@ -213,7 +384,8 @@ func TestHandleTagRead(t *testing.T) {
 		UpdatedAt: now,
 	}
 
-	mockStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
+	mockStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 
 	// Create request
 	payload := `{"tag_id":"ABCDEF123456","reader_id":"READER001"}`
@@ -237,6 +409,104 @@ func TestHandleTagRead(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestHandleTagReadDuplicateEvent(t *testing.T) {
+	mockStore := new(MockRFIDStore)
+	api := &API{store: mockStore}
+
+	storedTag := &Tag{
+		ID:       7,
+		TagID:    "ABCDEF123456",
+		ReaderID: "READER001",
+		EventID:  "evt-1",
+		ReadAt:   time.Now(),
+	}
+	mockStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "READER001", "evt-1", mock.Anything).Return(storedTag, ErrDuplicateEvent)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"READER001","event_id":"evt-1"}`
+	req := httptest.NewRequest("POST", "/tag", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleTagRead(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response TagReadResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Duplicate)
+	assert.Equal(t, int64(7), response.ID)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandleRoomEntryDuplicateEvent(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockStudentStore := new(MockStudentStore)
+	api := &API{store: mockRFIDStore, studentStore: mockStudentStore}
+
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "ROOM_READER", "evt-2", mock.Anything).Return(&Tag{ID: 8}, ErrDuplicateEvent)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"ROOM_READER","room_id":3,"event_id":"evt-2"}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response OccupancyResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.True(t, response.Duplicate)
+
+	// No visit is created for a retried entry
+	mockRFIDStore.AssertExpectations(t)
+	mockStudentStore.AssertNotCalled(t, "CreateStudentVisit", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleTagReadMarksReadProcessed(t *testing.T) {
+	mockStore := new(MockRFIDStore)
+	api := &API{store: mockStore}
+
+	storedTag := &Tag{ID: 9, TagID: "ABCDEF123456", ReaderID: "READER001", EventID: "evt-3"}
+	mockStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "READER001", "evt-3", mock.Anything).Return(storedTag, nil)
+	mockStore.On("MarkTagsProcessed", mock.Anything, []Tag{*storedTag}).Return(nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"READER001","event_id":"evt-3"}`
+	req := httptest.NewRequest("POST", "/tag", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleTagRead(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandleRoomEntryFailureLeavesEventUnprocessed(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	api := &API{store: mockRFIDStore, userStore: mockUserStore}
+
+	// The event was stored by an earlier attempt that failed, it is processed again
+	storedTag := &Tag{ID: 10, TagID: "CARD01", ReaderID: "ROOM_READER", EventID: "evt-4"}
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "CARD01", "ROOM_READER", "evt-4", mock.Anything).Return(storedTag, nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "CARD01").Return(nil, errors.New("connection refused"))
+
+	payload := `{"tag_id":"CARD01","reader_id":"ROOM_READER","room_id":5,"event_id":"evt-4"}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRFIDStore.AssertNotCalled(t, "MarkTagsProcessed", mock.Anything, mock.Anything)
+}
+
 func TestHandleStudentTracking(t *testing.T) {
 	// Setup
 	mockRFIDStore := new(MockRFIDStore)
@@ -277,13 +547,14 @@ func TestHandleStudentTracking(t *testing.T) {
 	}
 
 	// Set expectations
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(mockUser, nil)
 	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(mockStudent, nil)
 
 	// Expect the location update with correct parameters
-	mockStudentStore.On("UpdateStudentLocation", mock.Anything, int64(24), mock.MatchedBy(func(locations map[string]bool) bool {
-		return locations["in_house"] == true && locations["wc"] == false && locations["school_yard"] == false
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 24 && t.ToLocation == models.LocationInHouse && t.Source == "READER001"
 	})).Return(nil)
 
 	// Create request
@@ -303,7 +574,7 @@ func TestHandleStudentTracking(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, response.Success)
 	assert.Equal(t, "John Doe", response.Name)
-	assert.Equal(t, "in-house", response.Location)
+	assert.Equal(t, string(models.LocationInHouse), response.Location)
 
 	// Verify all expectations were met
 	mockRFIDStore.AssertExpectations(t)
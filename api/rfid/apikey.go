@@ -0,0 +1,136 @@
+package rfid
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/logging"
+)
+
+// apiKeyPrefixLen is the number of leading characters of an API key stored in
+// plaintext to look up the device
+const apiKeyPrefixLen = 8
+
+// hashAPIKey returns the hex encoded SHA-256 hash of an API key. Keys are random
+// and long enough that a fast hash is sufficient.
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyPrefix returns the lookup prefix of an API key
+func apiKeyPrefix(apiKey string) string {
+	if len(apiKey) < apiKeyPrefixLen {
+		return apiKey
+	}
+	return apiKey[:apiKeyPrefixLen]
+}
+
+// MatchesKey reports whether the API key is the key of the device, or its
+// previous key within the grace period at the given time
+func (d *TauriDevice) MatchesKey(apiKey string, at time.Time) bool {
+	hash := []byte(hashAPIKey(apiKey))
+	if subtle.ConstantTimeCompare(hash, []byte(d.APIKeyHash)) == 1 {
+		return true
+	}
+	return d.PreviousKeyHash != "" && d.PreviousKeyExpiresAt != nil && at.Before(*d.PreviousKeyExpiresAt) &&
+		subtle.ConstantTimeCompare(hash, []byte(d.PreviousKeyHash)) == 1
+}
+
+// requestAPIKey returns the API key of the request from the Authorization header,
+// or from the api_key query parameter of GET requests if enabled
+func (a *API) requestAPIKey(r *http.Request) string {
+	apiKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if apiKey == "" && a.apiKeyQuery && r.Method == http.MethodGet {
+		apiKey = r.URL.Query().Get("api_key")
+	}
+	return apiKey
+}
+
+// requestDeviceID returns the ID of the device authenticated for the request,
+// or an empty string if there is none
+func requestDeviceID(r *http.Request) string {
+	if device, ok := r.Context().Value("device").(*TauriDevice); ok {
+		return device.DeviceID
+	}
+	return ""
+}
+
+// handleRotateDeviceKey issues a new API key for a device on behalf of an admin.
+// The previous key remains valid for the configured grace period, so the device
+// can switch over. A revoked device is reinstated with the new key.
+func (a *API) handleRotateDeviceKey(w http.ResponseWriter, r *http.Request) {
+	a.rotateDeviceKey(w, r, chi.URLParam(r, "device_id"), true)
+}
+
+// handleRotateOwnKey issues a new API key for the requesting device. Devices
+// can rotate their own key only, a revocation is never lifted this way.
+func (a *API) handleRotateOwnKey(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "device_id")
+	if deviceID != requestDeviceID(r) {
+		render.Render(w, r, ErrForbidden(fmt.Errorf("devices can only rotate their own key")))
+		return
+	}
+	a.rotateDeviceKey(w, r, deviceID, false)
+}
+
+// rotateDeviceKey rotates the API key of a device and responds with the new key
+func (a *API) rotateDeviceKey(w http.ResponseWriter, r *http.Request, deviceID string, reinstate bool) {
+	device, apiKey, err := a.store.RotateDeviceKey(r.Context(), deviceID, a.apiKeyGracePeriod, reinstate)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+		return
+	}
+	if errors.Is(err, ErrDeviceRevoked) {
+		render.Render(w, r, ErrForbidden(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"device_id": deviceID,
+		"reinstate": reinstate,
+	}).Info("Device API key rotated")
+
+	// Note: This is the only time the new API key is returned
+	render.JSON(w, r, &KeyRotationResponse{
+		Success:              true,
+		DeviceID:             device.DeviceID,
+		APIKey:               apiKey,
+		PreviousKeyExpiresAt: device.PreviousKeyExpiresAt,
+	})
+}
+
+// handleRevokeDeviceKey revokes the API keys of a device, e.g. a stolen device.
+// The device is locked out until an admin rotates its key.
+func (a *API) handleRevokeDeviceKey(w http.ResponseWriter, r *http.Request) {
+	deviceID := chi.URLParam(r, "device_id")
+
+	err := a.store.RevokeDeviceKey(r.Context(), deviceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithField("device_id", deviceID).Warning("Device API key revoked")
+
+	render.NoContent(w, r)
+}
@@ -0,0 +1,257 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestDeviceMatchesKey(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	device := &TauriDevice{
+		APIKeyPrefix:         apiKeyPrefix("new-key-0123456789"),
+		APIKeyHash:           hashAPIKey("new-key-0123456789"),
+		PreviousKeyPrefix:    apiKeyPrefix("old-key-0123456789"),
+		PreviousKeyHash:      hashAPIKey("old-key-0123456789"),
+		PreviousKeyExpiresAt: &expiresAt,
+	}
+
+	assert.Equal(t, "new-key-", device.APIKeyPrefix)
+	assert.Len(t, device.APIKeyHash, 64)
+
+	assert.True(t, device.MatchesKey("new-key-0123456789", now))
+	assert.True(t, device.MatchesKey("old-key-0123456789", now))
+	assert.False(t, device.MatchesKey("old-key-0123456789", expiresAt))
+	assert.False(t, device.MatchesKey("new-key-wrong", now))
+	assert.True(t, device.MatchesKey("new-key-0123456789", expiresAt.Add(time.Hour)))
+}
+
+func TestAPIKeyAuthMiddleware(t *testing.T) {
+	IsTestMode = false
+	defer func() { IsTestMode = true }()
+
+	revokedAt := time.Now()
+	tests := []struct {
+		name   string
+		query  bool
+		method string
+		path   string
+		header string
+		device *TauriDevice
+		status int
+	}{
+		{"header", false, "POST", "/", "Bearer valid-key", &TauriDevice{DeviceID: "dev-1", Status: "active"}, http.StatusOK},
+		{"query disabled", false, "GET", "/?api_key=valid-key", "", nil, http.StatusUnauthorized},
+		{"query enabled", true, "GET", "/?api_key=valid-key", "", &TauriDevice{DeviceID: "dev-1", Status: "active"}, http.StatusOK},
+		{"query on POST", true, "POST", "/?api_key=valid-key", "", nil, http.StatusUnauthorized},
+		{"revoked", false, "GET", "/", "Bearer valid-key", &TauriDevice{DeviceID: "dev-1", Status: "active", RevokedAt: &revokedAt}, http.StatusUnauthorized},
+		{"unknown key", false, "GET", "/", "Bearer valid-key", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore, apiKeyQuery: tt.query}
+
+			if tt.device != nil {
+				mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "valid-key").Return(tt.device, nil)
+			} else {
+				mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "valid-key").Return(nil, sql.ErrNoRows).Maybe()
+			}
+			if tt.status == http.StatusOK {
+				mockRFIDStore.On("UpdateDevice", mock.Anything, "dev-1", mock.MatchedBy(func(updates map[string]interface{}) bool {
+					_, ok := updates["last_used_at"]
+					return ok
+				})).Return(nil)
+			}
+
+			handler := api.apiKeyAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockRFIDStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleRotateDeviceKey(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore, apiKeyGracePeriod: 24 * time.Hour}
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+	mockRFIDStore.On("RotateDeviceKey", mock.Anything, "dev-1", 24*time.Hour, true).
+		Return(&TauriDevice{DeviceID: "dev-1", PreviousKeyExpiresAt: &expiresAt}, "new-api-key", nil)
+	mockRFIDStore.On("RotateDeviceKey", mock.Anything, "missing", 24*time.Hour, true).Return(nil, "", sql.ErrNoRows)
+
+	router := chi.NewRouter()
+	router.Post("/devices/{device_id}/rotate-key", api.handleRotateDeviceKey)
+
+	req := httptest.NewRequest("POST", "/devices/dev-1/rotate-key", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response KeyRotationResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, "new-api-key", response.APIKey)
+	assert.NotNil(t, response.PreviousKeyExpiresAt)
+
+	req = httptest.NewRequest("POST", "/devices/missing/rotate-key", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRFIDStore.AssertExpectations(t)
+}
+
+func TestHandleRotateOwnKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		deviceID string
+		err      error
+		status   int
+	}{
+		{"own key", "dev-1", nil, http.StatusOK},
+		{"key of another device", "dev-2", nil, http.StatusForbidden},
+		{"revoked device", "dev-1", ErrDeviceRevoked, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore, apiKeyGracePeriod: 24 * time.Hour}
+
+			if tt.deviceID == "dev-1" {
+				if tt.err != nil {
+					mockRFIDStore.On("RotateDeviceKey", mock.Anything, "dev-1", 24*time.Hour, false).Return(nil, "", tt.err)
+				} else {
+					mockRFIDStore.On("RotateDeviceKey", mock.Anything, "dev-1", 24*time.Hour, false).
+						Return(&TauriDevice{DeviceID: "dev-1"}, "new-api-key", nil)
+				}
+			}
+
+			router := chi.NewRouter()
+			router.Post("/devices/{device_id}/rotate-key", api.handleRotateOwnKey)
+
+			req := httptest.NewRequest("POST", "/devices/"+tt.deviceID+"/rotate-key", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockRFIDStore.AssertExpectations(t)
+			if tt.deviceID != "dev-1" {
+				mockRFIDStore.AssertNotCalled(t, "RotateDeviceKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeviceJSONHidesKeyHashes(t *testing.T) {
+	device := TauriDevice{
+		DeviceID:        "dev-1",
+		APIKeyPrefix:    "abcdefgh",
+		APIKeyHash:      hashAPIKey("abcdefgh-secret"),
+		PreviousKeyHash: hashAPIKey("old-secret"),
+	}
+
+	body, err := json.Marshal(device)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"api_key_prefix":"abcdefgh"`)
+	assert.NotContains(t, string(body), device.APIKeyHash)
+	assert.NotContains(t, string(body), device.PreviousKeyHash)
+}
+
+func TestEventsAuthMiddleware(t *testing.T) {
+	IsTestMode = false
+	defer func() { IsTestMode = true }()
+
+	viper.Set("auth_jwt_alg", "HS256")
+	viper.Set("auth_jwt_secret", "events-test-secret")
+	viper.Set("auth_jwt_expiry", "15m")
+	viper.Set("auth_jwt_refresh_expiry", "1h")
+	tokenAuth, err := jwt.NewTokenAuth(jwt.NewMemoryKeyStore())
+	assert.NoError(t, err)
+	accessToken, err := tokenAuth.CreateJWT(jwt.AppClaims{ID: 3, Sub: "staff", Roles: []string{"staff"}})
+	assert.NoError(t, err)
+	userToken, err := tokenAuth.CreateJWT(jwt.AppClaims{ID: 4, Sub: "user", Roles: []string{"user"}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		device *TauriDevice
+		status int
+	}{
+		{"access token", "Bearer " + accessToken, nil, http.StatusOK},
+		{"access token without read permission", "Bearer " + userToken, nil, http.StatusForbidden},
+		{"device API key", "Bearer valid-key", &TauriDevice{DeviceID: "dev-1", Status: "active"}, http.StatusOK},
+		{"unknown key", "Bearer valid-key", nil, http.StatusUnauthorized},
+		{"no credentials", "", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			if tt.device != nil {
+				mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "valid-key").Return(tt.device, nil)
+				mockRFIDStore.On("UpdateDevice", mock.Anything, "dev-1", mock.Anything).Return(nil)
+			} else {
+				mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "valid-key").Return(nil, sql.ErrNoRows).Maybe()
+			}
+
+			var subscriber string
+			handler := tokenAuth.Verifier()(api.eventsAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if device, ok := r.Context().Value("device").(*TauriDevice); ok {
+					subscriber = device.DeviceID
+				} else {
+					subscriber = jwt.ClaimsFromCtx(r.Context()).Sub
+				}
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			switch {
+			case tt.status != http.StatusOK:
+				assert.Empty(t, subscriber)
+			case tt.device != nil:
+				assert.Equal(t, "dev-1", subscriber)
+			default:
+				assert.Equal(t, "staff", subscriber)
+			}
+			mockRFIDStore.AssertExpectations(t)
+		})
+	}
+}
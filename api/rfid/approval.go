@@ -0,0 +1,118 @@
+package rfid
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/logging"
+)
+
+// AdminRouter provides the routes to review device registrations, configure
+// devices, monitor device health, maintain the reader registry and assign
+// unknown tags to users. It relies on the JWT authentication and admin role of the admin
+// routes it is mounted below.
+func (a *API) AdminRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/devices", a.handleListDevicesForReview)
+	r.Post("/devices/{device_id}/approve", a.handleApproveDevice)
+	r.Post("/devices/{device_id}/reject", a.handleRejectDevice)
+	r.Post("/devices/{device_id}/rotate-key", a.handleRotateDeviceKey)
+	r.Post("/devices/{device_id}/revoke-key", a.handleRevokeDeviceKey)
+	r.Get("/devices/{device_id}/config", a.handleGetDeviceConfig)
+	r.Put("/devices/{device_id}/config", a.handlePutDeviceConfig)
+	r.Get("/devices/{device_id}/commands", a.handleListDeviceCommands)
+	r.Post("/devices/{device_id}/commands", a.handleCreateDeviceCommand)
+	r.Get("/health", a.handleHealthDashboard)
+	r.Route("/readers", func(r chi.Router) {
+		r.Get("/", a.handleListReaders)
+		r.Post("/", a.handleCreateReader)
+		r.Get("/{reader_id}", a.handleGetReader)
+		r.Put("/{reader_id}", a.handleUpdateReader)
+		r.Delete("/{reader_id}", a.handleDeleteReader)
+	})
+	r.Route("/unknown-tags", func(r chi.Router) {
+		r.Get("/", a.handleListUnknownTags)
+		r.Post("/{tag_id}/assign", a.handleAssignTag)
+		r.Delete("/{tag_id}", a.handleDeleteUnknownTag)
+	})
+	return r
+}
+
+// handleListDevicesForReview returns the devices with the status given as query
+// parameter, pending registrations by default
+func (a *API) handleListDevicesForReview(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = DeviceStatusPending
+	}
+
+	devices, err := a.store.ListDevices(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	result := make([]TauriDevice, 0, len(devices))
+	for _, d := range devices {
+		if d.Status == status {
+			result = append(result, d)
+		}
+	}
+
+	render.JSON(w, r, result)
+}
+
+// handleApproveDevice activates a pending device registration, optionally
+// binding the device to a room
+func (a *API) handleApproveDevice(w http.ResponseWriter, r *http.Request) {
+	data := &DeviceApprovalRequest{}
+	if r.ContentLength > 0 {
+		if err := render.Bind(r, data); err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
+	a.reviewDevice(w, r, DeviceStatusActive, data.RoomID)
+}
+
+// handleRejectDevice rejects a pending device registration, its API key is
+// refused from then on
+func (a *API) handleRejectDevice(w http.ResponseWriter, r *http.Request) {
+	a.reviewDevice(w, r, DeviceStatusRejected, nil)
+}
+
+// reviewDevice sets the status of a pending device to the review result
+func (a *API) reviewDevice(w http.ResponseWriter, r *http.Request, status string, roomID *int64) {
+	deviceID := chi.URLParam(r, "device_id")
+	reviewer := int64(jwt.ClaimsFromCtx(r.Context()).ID)
+
+	device, err := a.store.ReviewDevice(r.Context(), deviceID, status, roomID, reviewer)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+		return
+	}
+	if errors.Is(err, ErrDeviceNotPending) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"device_id":   deviceID,
+		"status":      status,
+		"reviewed_by": reviewer,
+	}).Info("Device registration reviewed")
+
+	render.JSON(w, r, device)
+}
@@ -0,0 +1,153 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestReviewDevice(t *testing.T) {
+	roomID := int64(5)
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status string
+		roomID *int64
+		err    error
+		code   int
+	}{
+		{"approve", "/devices/dev-1/approve", "", DeviceStatusActive, nil, nil, http.StatusOK},
+		{"approve with room", "/devices/dev-1/approve", `{"room_id":5}`, DeviceStatusActive, &roomID, nil, http.StatusOK},
+		{"reject", "/devices/dev-1/reject", "", DeviceStatusRejected, nil, nil, http.StatusOK},
+		{"reviewed already", "/devices/dev-1/reject", "", DeviceStatusRejected, nil, ErrDeviceNotPending, http.StatusConflict},
+		{"unknown device", "/devices/dev-1/approve", "", DeviceStatusActive, nil, sql.ErrNoRows, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			if tt.err != nil {
+				mockRFIDStore.On("ReviewDevice", mock.Anything, "dev-1", tt.status, tt.roomID, int64(3)).Return(nil, tt.err)
+			} else {
+				mockRFIDStore.On("ReviewDevice", mock.Anything, "dev-1", tt.status, tt.roomID, int64(3)).
+					Return(&TauriDevice{DeviceID: "dev-1", Status: tt.status, RoomID: tt.roomID}, nil)
+			}
+
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(jwt.ContextWithClaims(req.Context(), jwt.AppClaims{ID: 3, Roles: []string{"admin"}}))
+			w := httptest.NewRecorder()
+
+			api.AdminRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				var device TauriDevice
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &device))
+				assert.Equal(t, tt.status, device.Status)
+			}
+			mockRFIDStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListDevicesForReview(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	mockRFIDStore.On("ListDevices", mock.Anything).Return([]TauriDevice{
+		{DeviceID: "dev-1", Status: DeviceStatusActive},
+		{DeviceID: "dev-2", Status: DeviceStatusPending},
+		{DeviceID: "dev-3", Status: DeviceStatusRejected},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/devices", nil)
+	w := httptest.NewRecorder()
+	api.AdminRouter().ServeHTTP(w, req)
+
+	var devices []TauriDevice
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "dev-2", devices[0].DeviceID)
+}
+
+func TestAPIKeyAuthMiddlewarePendingDevice(t *testing.T) {
+	IsTestMode = false
+	defer func() { IsTestMode = true }()
+
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+	mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "pending-key").Return(&TauriDevice{DeviceID: "dev-1", Status: DeviceStatusPending}, nil)
+
+	handler := api.apiKeyAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("pending device must not pass")
+	}))
+
+	req := httptest.NewRequest("POST", "/tag", nil)
+	req.Header.Set("Authorization", "Bearer pending-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "pending approval")
+}
+
+func TestHandleUpdateDevicePendingStatus(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+	mockRFIDStore.On("GetDevice", mock.Anything, "dev-1").Return(&TauriDevice{DeviceID: "dev-1", Status: DeviceStatusPending}, nil)
+
+	router := chi.NewRouter()
+	router.Put("/devices/{device_id}", api.handleUpdateDevice)
+
+	req := httptest.NewRequest("PUT", "/devices/dev-1", strings.NewReader(`{"status":"active"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRFIDStore.AssertNotCalled(t, "UpdateDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleUpdateOtherDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"rename", `{"name":"Hijacked"}`},
+		{"re-enable", `{"status":"active"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			router := chi.NewRouter()
+			router.Put("/devices/{device_id}", api.handleUpdateDevice)
+
+			req := httptest.NewRequest("PUT", "/devices/dev-2", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			// The mock panics on any lookup or update of the other device
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+	}
+}
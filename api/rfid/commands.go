@@ -0,0 +1,152 @@
+package rfid
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/logging"
+)
+
+// handleCreateDeviceCommand queues a command for a device
+func (a *API) handleCreateDeviceCommand(w http.ResponseWriter, r *http.Request) {
+	data := &DeviceCommandRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	ctx := r.Context()
+	deviceID := chi.URLParam(r, "device_id")
+	if _, err := a.store.GetDevice(ctx, deviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+			return
+		}
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	createdBy := int64(jwt.ClaimsFromCtx(ctx).ID)
+	cmd := &DeviceCommand{
+		DeviceID:  deviceID,
+		Command:   data.Command,
+		ReaderID:  data.ReaderID,
+		Message:   data.Message,
+		CreatedBy: &createdBy,
+	}
+	if err := a.store.CreateDeviceCommand(ctx, cmd); err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"device_id":  deviceID,
+		"command":    cmd.Command,
+		"created_by": createdBy,
+	}).Info("Device command queued")
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, cmd)
+}
+
+// handleListDeviceCommands returns the latest commands of a device with their status
+func (a *API) handleListDeviceCommands(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("invalid limit: %s", s)))
+			return
+		}
+	}
+
+	commands, err := a.store.ListDeviceCommands(r.Context(), chi.URLParam(r, "device_id"), limit)
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, commands)
+}
+
+// handlePollCommands returns the commands queued for the requesting device. They
+// are delivered again on every poll until acknowledged.
+func (a *API) handlePollCommands(w http.ResponseWriter, r *http.Request) {
+	device, ok := r.Context().Value("device").(*TauriDevice)
+	if !ok {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("device authentication error")))
+		return
+	}
+
+	commands, err := a.store.PollDeviceCommands(r.Context(), device.DeviceID, time.Now())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+	if commands == nil {
+		commands = []DeviceCommand{}
+	}
+
+	render.JSON(w, r, commands)
+}
+
+// handleAckCommand records the result of a command executed by the requesting device
+func (a *API) handleAckCommand(w http.ResponseWriter, r *http.Request) {
+	device, ok := r.Context().Value("device").(*TauriDevice)
+	if !ok {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("device authentication error")))
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("invalid command ID")))
+		return
+	}
+
+	data := &CommandAckRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	status := CommandStatusAcknowledged
+	if !*data.Success {
+		status = CommandStatusFailed
+	}
+
+	cmd, err := a.store.AcknowledgeDeviceCommand(r.Context(), device.DeviceID, id, status, data.Result, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("command not found")))
+		return
+	}
+	if errors.Is(err, ErrCommandAcknowledged) {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	log := logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"device_id": device.DeviceID,
+		"command":   cmd.Command,
+	})
+	if status == CommandStatusFailed {
+		log.WithField("result", data.Result).Warning("Device command failed")
+	} else {
+		log.Info("Device command acknowledged")
+	}
+
+	render.JSON(w, r, cmd)
+}
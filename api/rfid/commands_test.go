@@ -0,0 +1,124 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestHandleCreateDeviceCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"show message", `{"command":"show_message","message":"Reader maintenance at 2pm"}`, http.StatusCreated},
+		{"reboot reader", `{"command":"reboot_reader","reader_id":"ROOM-101"}`, http.StatusCreated},
+		{"reboot without reader", `{"command":"reboot_reader"}`, http.StatusUnprocessableEntity},
+		{"message without text", `{"command":"show_message"}`, http.StatusUnprocessableEntity},
+		{"unknown command", `{"command":"self_destruct"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			mockRFIDStore.On("GetDevice", mock.Anything, "dev-1").Return(&TauriDevice{DeviceID: "dev-1"}, nil)
+			mockRFIDStore.On("CreateDeviceCommand", mock.Anything, mock.MatchedBy(func(cmd *DeviceCommand) bool {
+				return cmd.DeviceID == "dev-1" && *cmd.CreatedBy == 3
+			})).Return(nil).Run(func(args mock.Arguments) {
+				args.Get(1).(*DeviceCommand).Status = CommandStatusPending
+			})
+
+			req := httptest.NewRequest("POST", "/devices/dev-1/commands", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(jwt.ContextWithClaims(req.Context(), jwt.AppClaims{ID: 3, Roles: []string{"admin"}}))
+			w := httptest.NewRecorder()
+
+			api.AdminRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusCreated {
+				var cmd DeviceCommand
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cmd))
+				assert.Equal(t, CommandStatusPending, cmd.Status)
+			}
+		})
+	}
+}
+
+func TestHandlePollCommands(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	mockRFIDStore.On("PollDeviceCommands", mock.Anything, "dev-1", mock.Anything).Return([]DeviceCommand{
+		{ID: 1, DeviceID: "dev-1", Command: DeviceCommandResync, Status: CommandStatusDelivered},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/app/commands", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+	w := httptest.NewRecorder()
+
+	api.handlePollCommands(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var commands []DeviceCommand
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &commands))
+	assert.Len(t, commands, 1)
+	assert.Equal(t, DeviceCommandResync, commands[0].Command)
+	mockRFIDStore.AssertExpectations(t)
+}
+
+func TestHandleAckCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status string
+		err    error
+		code   int
+	}{
+		{"success", `{"success":true}`, CommandStatusAcknowledged, nil, http.StatusOK},
+		{"failure", `{"success":false,"result":"reader not connected"}`, CommandStatusFailed, nil, http.StatusOK},
+		{"acknowledged already", `{"success":true}`, CommandStatusAcknowledged, ErrCommandAcknowledged, http.StatusConflict},
+		{"command of another device", `{"success":true}`, CommandStatusAcknowledged, sql.ErrNoRows, http.StatusNotFound},
+		{"missing success", `{}`, "", nil, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			if tt.err != nil {
+				mockRFIDStore.On("AcknowledgeDeviceCommand", mock.Anything, "dev-1", int64(7), tt.status, mock.Anything, mock.Anything).Return(nil, tt.err)
+			} else if tt.status != "" {
+				mockRFIDStore.On("AcknowledgeDeviceCommand", mock.Anything, "dev-1", int64(7), tt.status, mock.Anything, mock.Anything).
+					Return(&DeviceCommand{ID: 7, DeviceID: "dev-1", Command: DeviceCommandRebootReader, Status: tt.status}, nil)
+			}
+
+			router := chi.NewRouter()
+			router.Post("/app/commands/{id}/ack", api.handleAckCommand)
+
+			req := httptest.NewRequest("POST", "/app/commands/7/ack", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			mockRFIDStore.AssertExpectations(t)
+		})
+	}
+}
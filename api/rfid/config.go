@@ -0,0 +1,158 @@
+package rfid
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/logging"
+)
+
+// handleGetAppConfig returns the configuration of the requesting device. Devices
+// poll it with If-None-Match and get 304 Not Modified while it is unchanged.
+func (a *API) handleGetAppConfig(w http.ResponseWriter, r *http.Request) {
+	device, ok := r.Context().Value("device").(*TauriDevice)
+	if !ok {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("device authentication error")))
+		return
+	}
+
+	cfg, err := a.deviceConfig(r.Context(), device)
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	etag := configETag(cfg)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	render.JSON(w, r, cfg)
+}
+
+// handleGetDeviceConfig returns the configuration of a device
+func (a *API) handleGetDeviceConfig(w http.ResponseWriter, r *http.Request) {
+	device, err := a.store.GetDevice(r.Context(), chi.URLParam(r, "device_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	cfg, err := a.deviceConfig(r.Context(), device)
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, cfg)
+}
+
+// handlePutDeviceConfig sets the configuration of a device, the device picks it
+// up on its next poll
+func (a *API) handlePutDeviceConfig(w http.ResponseWriter, r *http.Request) {
+	data := &DeviceConfigRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	updatedBy := int64(jwt.ClaimsFromCtx(r.Context()).ID)
+	cfg := &DeviceConfig{
+		DeviceID:            chi.URLParam(r, "device_id"),
+		ReaderMode:          data.ReaderMode,
+		DebounceSeconds:     data.DebounceSeconds,
+		SyncIntervalSeconds: data.SyncIntervalSeconds,
+		MinAppVersion:       data.MinAppVersion,
+		UpdatedBy:           &updatedBy,
+	}
+
+	err := a.store.SaveDeviceConfig(r.Context(), cfg, data.RoomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("device not found")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"device_id":  cfg.DeviceID,
+		"version":    cfg.Version,
+		"updated_by": updatedBy,
+	}).Info("Device configuration updated")
+
+	render.JSON(w, r, configResponse(cfg, data.RoomID))
+}
+
+// deviceConfig returns the configuration of a device, or the defaults for
+// devices without a configuration
+func (a *API) deviceConfig(ctx context.Context, device *TauriDevice) (*DeviceConfigResponse, error) {
+	cfg, err := a.store.GetDeviceConfig(ctx, device.DeviceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &DeviceConfigResponse{
+			DeviceID:            device.DeviceID,
+			RoomID:              device.RoomID,
+			ReaderMode:          ReaderModeSync,
+			DebounceSeconds:     int(a.debounceDefault / time.Second),
+			SyncIntervalSeconds: int(a.syncIntervalDefault / time.Second),
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return configResponse(cfg, device.RoomID), nil
+}
+
+// configResponse returns the configuration of a device bound to the room
+func configResponse(cfg *DeviceConfig, roomID *int64) *DeviceConfigResponse {
+	return &DeviceConfigResponse{
+		DeviceID:            cfg.DeviceID,
+		Version:             cfg.Version,
+		RoomID:              roomID,
+		ReaderMode:          cfg.ReaderMode,
+		DebounceSeconds:     cfg.DebounceSeconds,
+		SyncIntervalSeconds: cfg.SyncIntervalSeconds,
+		MinAppVersion:       cfg.MinAppVersion,
+	}
+}
+
+// configETag returns the entity tag of a configuration, changing with any of
+// its values
+func configETag(cfg *DeviceConfigResponse) string {
+	body, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches the entity tag
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
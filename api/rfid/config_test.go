@@ -0,0 +1,124 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestHandleGetAppConfig(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore, debounceDefault: 5 * time.Second, syncIntervalDefault: time.Minute}
+
+	roomID := int64(5)
+	device := &TauriDevice{DeviceID: "dev-1", RoomID: &roomID}
+	mockRFIDStore.On("GetDeviceConfig", mock.Anything, "dev-1").Return(nil, sql.ErrNoRows)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/app/config", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), "device", device))
+		w := httptest.NewRecorder()
+		api.handleGetAppConfig(w, req)
+		return w
+	}
+
+	w := get("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	var cfg DeviceConfigResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, 0, cfg.Version)
+	assert.Equal(t, ReaderModeSync, cfg.ReaderMode)
+	assert.Equal(t, &roomID, cfg.RoomID)
+	assert.Equal(t, 5, cfg.DebounceSeconds)
+	assert.Equal(t, 60, cfg.SyncIntervalSeconds)
+
+	// Unchanged configuration
+	w = get(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Changed configuration
+	mockRFIDStore.ExpectedCalls = nil
+	mockRFIDStore.On("GetDeviceConfig", mock.Anything, "dev-1").Return(&DeviceConfig{
+		DeviceID:            "dev-1",
+		ReaderMode:          ReaderModeRoom,
+		SyncIntervalSeconds: 30,
+		Version:             2,
+	}, nil)
+	w = get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestHandlePutDeviceConfig(t *testing.T) {
+	roomID := int64(5)
+	tests := []struct {
+		name string
+		body string
+		err  error
+		code int
+	}{
+		{"room mode", `{"room_id":5,"reader_mode":"room","debounce_seconds":3,"sync_interval_seconds":30,"min_app_version":"1.4.2"}`, nil, http.StatusOK},
+		{"room mode without room", `{"reader_mode":"room","sync_interval_seconds":30}`, nil, http.StatusUnprocessableEntity},
+		{"invalid reader mode", `{"reader_mode":"door","sync_interval_seconds":30}`, nil, http.StatusUnprocessableEntity},
+		{"missing sync interval", `{"reader_mode":"sync"}`, nil, http.StatusUnprocessableEntity},
+		{"invalid app version", `{"reader_mode":"sync","sync_interval_seconds":30,"min_app_version":"latest"}`, nil, http.StatusUnprocessableEntity},
+		{"unknown device", `{"room_id":5,"reader_mode":"room","sync_interval_seconds":30}`, sql.ErrNoRows, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			api := &API{store: mockRFIDStore}
+
+			mockRFIDStore.On("SaveDeviceConfig", mock.Anything, mock.MatchedBy(func(cfg *DeviceConfig) bool {
+				return cfg.DeviceID == "dev-1" && cfg.ReaderMode == ReaderModeRoom && *cfg.UpdatedBy == 3
+			}), &roomID).Return(tt.err).Run(func(args mock.Arguments) {
+				args.Get(1).(*DeviceConfig).Version = 2
+			})
+
+			req := httptest.NewRequest("PUT", "/devices/dev-1/config", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(jwt.ContextWithClaims(req.Context(), jwt.AppClaims{ID: 3, Roles: []string{"admin"}}))
+			w := httptest.NewRecorder()
+
+			api.AdminRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == http.StatusOK {
+				var cfg DeviceConfigResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+				assert.Equal(t, 2, cfg.Version)
+				assert.Equal(t, &roomID, cfg.RoomID)
+				assert.Equal(t, "1.4.2", cfg.MinAppVersion)
+			}
+			if tt.code == http.StatusUnprocessableEntity {
+				mockRFIDStore.AssertNotCalled(t, "SaveDeviceConfig", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"xyz", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+}
@@ -0,0 +1,108 @@
+package rfid
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Decisions report how the server handled a read
+const (
+	DecisionProcessed = "processed" // the read was applied
+	DecisionDebounced = "debounced" // the read repeats a read of the tag within the debounce window
+	DecisionIgnored   = "ignored"   // anti-passback ignored a repeated entry or exit
+	DecisionToggled   = "toggled"   // anti-passback handled a repeated entry as exit or vice versa
+	DecisionStale     = "stale"     // the read is older than the student's current location
+)
+
+// readHistory looks up the processed reads of a tag. Reads are kept in the
+// database, so repeated reads are debounced across all instances.
+type readHistory interface {
+	NearestTagRead(ctx context.Context, tagID, readerID string, at time.Time, within time.Duration) (time.Time, error)
+}
+
+// debouncer finds the previous processed read of a tag, so a tag held to a
+// reader for a while is processed once
+type debouncer struct {
+	reads     readHistory
+	tagWindow time.Duration // window for reads of the same tag at any reader
+}
+
+// newDebouncer creates a debouncer, reads of a tag at different readers are
+// debounced within tagWindow if it is positive
+func newDebouncer(reads readHistory, tagWindow time.Duration) *debouncer {
+	return &debouncer{
+		reads:     reads,
+		tagWindow: tagWindow,
+	}
+}
+
+// within reports whether at lies within the window around the previous read
+func within(at, previous time.Time, window time.Duration) bool {
+	if previous.IsZero() || window <= 0 {
+		return false
+	}
+	d := at.Sub(previous)
+	if d < 0 {
+		d = -d
+	}
+	return d < window
+}
+
+// debounceWindow returns the debounce window of the reader, or the configured
+// default for unregistered readers and readers without their own window
+func (a *API) debounceWindow(reader *Reader) time.Duration {
+	if reader != nil && reader.DebounceSeconds != nil {
+		return time.Duration(*reader.DebounceSeconds) * time.Second
+	}
+	return a.debounceDefault
+}
+
+// antiPassback returns the anti-passback policy of the reader, or the
+// configured default for unregistered readers and readers without their own policy
+func (a *API) antiPassback(reader *Reader) string {
+	if reader != nil && reader.AntiPassback != "" {
+		return reader.AntiPassback
+	}
+	if a.antiPassbackDefault != "" {
+		return a.antiPassbackDefault
+	}
+	return AntiPassbackIgnore
+}
+
+// debounced reports whether the read repeats a processed read of the tag within
+// the debounce window. Only processed reads count, so a card held to the reader
+// is processed again once the window after its last processed read ended. The
+// reader is only looked up for repeated reads.
+func (a *API) debounced(ctx context.Context, log logrus.FieldLogger, tagID, readerID string, at time.Time) bool {
+	if a.debounce == nil {
+		return false
+	}
+
+	if a.debounce.tagWindow > 0 {
+		anywhere, err := a.debounce.reads.NearestTagRead(ctx, tagID, "", at, a.debounce.tagWindow)
+		if err != nil {
+			log.WithError(err).WithField("tag_id", tagID).Error("Failed to look up previous reads")
+			return false
+		}
+		if !anywhere.IsZero() {
+			return true
+		}
+	}
+
+	atReader, err := a.debounce.reads.NearestTagRead(ctx, tagID, readerID, at, maxDebounceSeconds*time.Second)
+	if err != nil {
+		log.WithError(err).WithField("tag_id", tagID).Error("Failed to look up previous reads")
+		return false
+	}
+	if atReader.IsZero() {
+		return false
+	}
+
+	reader, err := a.lookupReader(ctx, readerID)
+	if err != nil {
+		log.WithError(err).WithField("reader_id", readerID).Error("Failed to look up reader")
+	}
+	return within(at, atReader, a.debounceWindow(reader))
+}
@@ -0,0 +1,179 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhax/go-base/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDebounced(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{
+		store:           mockRFIDStore,
+		debounce:        newDebouncer(mockRFIDStore, 2*time.Second),
+		debounceDefault: 5 * time.Second,
+	}
+	now := time.Now()
+	log := logrus.StandardLogger()
+
+	// Only reads marked processed are found, a debounced read is not one of them
+	mockRFIDStore.On("NearestTagRead", mock.Anything, "TAG-A", "", now, 2*time.Second).Return(time.Time{}, nil)
+	mockRFIDStore.On("NearestTagRead", mock.Anything, "TAG-A", "R1", now, maxDebounceSeconds*time.Second).Return(now.Add(-4*time.Second), nil).Once()
+	mockRFIDStore.On("GetReader", mock.Anything, "R1").Return(nil, sql.ErrNoRows)
+	assert.True(t, api.debounced(context.Background(), log, "TAG-A", "R1", now))
+
+	// Reads after the window of the last processed read are processed again
+	mockRFIDStore.On("NearestTagRead", mock.Anything, "TAG-A", "R1", now, maxDebounceSeconds*time.Second).Return(now.Add(-6*time.Second), nil).Once()
+	assert.False(t, api.debounced(context.Background(), log, "TAG-A", "R1", now))
+
+	// Reads at another reader only fall into the tag window
+	mockRFIDStore.On("NearestTagRead", mock.Anything, "TAG-B", "", now, 2*time.Second).Return(now.Add(-time.Second), nil)
+	assert.True(t, api.debounced(context.Background(), log, "TAG-B", "R2", now))
+
+	mockRFIDStore.AssertExpectations(t)
+
+	assert.False(t, within(now, time.Time{}, time.Minute))
+	assert.False(t, within(now, now, 0))
+}
+
+func TestHandleRoomEntryDebounced(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{
+		store:           mockRFIDStore,
+		debounce:        newDebouncer(mockRFIDStore, 0),
+		debounceDefault: 5 * time.Second,
+	}
+
+	mockRFIDStore.On("NearestTagRead", mock.Anything, "ABCDEF123456", "ROOM_READER", mock.Anything, mock.Anything).Return(time.Now().Add(-time.Second), nil)
+	mockRFIDStore.On("GetReader", mock.Anything, "ROOM_READER").Return(nil, sql.ErrNoRows)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"ROOM_READER","room_id":5}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response OccupancyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, DecisionDebounced, response.Decision)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockRFIDStore.AssertNotCalled(t, "SaveTag", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleRoomEntryAntiPassback(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		decision string
+	}{
+		{"ignore", AntiPassbackIgnore, DecisionIgnored},
+		{"toggle", AntiPassbackToggle, DecisionToggled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			mockUserStore := new(MockUserStore)
+			mockStudentStore := new(MockStudentStore)
+
+			api := &API{
+				store:               mockRFIDStore,
+				userStore:           mockUserStore,
+				studentStore:        mockStudentStore,
+				antiPassbackDefault: tt.policy,
+			}
+
+			roomID := int64(5)
+			mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "ROOM_READER", "", mock.Anything).Return(&Tag{ID: 1}, nil)
+			mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+			mockUserStore.On("GetCustomUserByTagID", mock.Anything, "ABCDEF123456").Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil)
+			mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+				ID:             24,
+				Location:       models.LocationRoom,
+				LocationRoomID: &roomID,
+			}, nil)
+			mockRFIDStore.On("GetReader", mock.Anything, "ROOM_READER").Return(nil, sql.ErrNoRows)
+			mockRFIDStore.On("GetRoomOccupancy", mock.Anything, roomID).Return(&RoomOccupancyData{RoomID: roomID, StudentCount: 1}, nil)
+			if tt.policy == AntiPassbackToggle {
+				mockRFIDStore.On("RecordRoomExit", mock.Anything, int64(24), roomID, mock.Anything).Return(nil)
+				mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+					return t.StudentID == 24 && t.ToLocation == models.LocationInHouse
+				})).Return(nil)
+			}
+
+			payload := `{"tag_id":"ABCDEF123456","reader_id":"ROOM_READER","room_id":5}`
+			req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			api.handleRoomEntry(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response OccupancyResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.True(t, response.Success)
+			assert.Equal(t, tt.decision, response.Decision)
+
+			mockRFIDStore.AssertExpectations(t)
+			mockUserStore.AssertExpectations(t)
+			mockStudentStore.AssertExpectations(t)
+			mockRFIDStore.AssertNotCalled(t, "RecordRoomEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHandleStudentTrackingRepeatedEntryIgnored(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	mockStudentStore := new(MockStudentStore)
+
+	api := &API{
+		store:        mockRFIDStore,
+		userStore:    mockUserStore,
+		studentStore: mockStudentStore,
+	}
+
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "MAIN-DOOR", "", mock.Anything).Return(&Tag{ID: 1}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+	mockRFIDStore.On("GetReader", mock.Anything, "MAIN-DOOR").Return(&Reader{
+		ReaderID:     "MAIN-DOOR",
+		LocationType: ReaderLocationBuilding,
+		Direction:    ReaderDirectionEntry,
+	}, nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "ABCDEF123456").Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil)
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+		ID:       24,
+		Location: models.LocationWC,
+	}, nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"MAIN-DOOR"}`
+	req := httptest.NewRequest("POST", "/track-student", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleStudentTracking(w, req)
+
+	var response StudentTrackingResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.Equal(t, DecisionIgnored, response.Decision)
+	assert.Equal(t, string(models.LocationWC), response.Location)
+
+	mockStudentStore.AssertNotCalled(t, "TransitionStudentLocation", mock.Anything, mock.Anything)
+}
@@ -59,3 +59,33 @@ func ErrUnauthorized(err error) render.Renderer {
 		ErrorText:      err.Error(),
 	}
 }
+
+// ErrForbidden returns a 403 Forbidden error response.
+func ErrForbidden(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusForbidden,
+		StatusText:     "Forbidden.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrNotFound returns a 404 Not Found error response.
+func ErrNotFound(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusNotFound,
+		StatusText:     "Resource not found.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// ErrConflict returns a 409 Conflict error response.
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
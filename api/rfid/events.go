@@ -0,0 +1,130 @@
+package rfid
+
+import (
+	"sync"
+	"time"
+)
+
+// Location event types published on the event bus
+const (
+	EventRoomEntry      = "room_entry"
+	EventRoomExit       = "room_exit"
+	EventLocationChange = "location_change"
+	EventTagBlocked     = "tag_blocked" // alert for a read of a lost or blocked tag
+)
+
+// eventBufferSize is the number of events buffered per subscriber before
+// further events are dropped for that subscriber
+const eventBufferSize = 32
+
+// LocationEvent describes a change of a student's location
+type LocationEvent struct {
+	Type         string    `json:"type"`
+	StudentID    int64     `json:"student_id"`
+	Name         string    `json:"name,omitempty"`
+	GroupID      int64     `json:"group_id,omitempty"`
+	RoomID       int64     `json:"room_id,omitempty"`
+	Location     string    `json:"location,omitempty"`
+	StudentCount int       `json:"student_count,omitempty"`
+	ReaderID     string    `json:"reader_id,omitempty"`
+	TagID        string    `json:"tag_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EventFilter restricts the events delivered to a subscriber. Zero values match everything.
+type EventFilter struct {
+	RoomID   int64
+	GroupID  int64
+	GroupIDs map[int64]bool // groups the subscriber may access, nil for all groups
+}
+
+// Matches reports whether the event passes the filter
+func (f EventFilter) Matches(e LocationEvent) bool {
+	if f.RoomID != 0 && f.RoomID != e.RoomID {
+		return false
+	}
+	if f.GroupID != 0 && f.GroupID != e.GroupID {
+		return false
+	}
+	if f.GroupIDs != nil && !f.GroupIDs[e.GroupID] {
+		return false
+	}
+	return true
+}
+
+// EventBus is an in-process publish/subscribe hub for location events
+type EventBus struct {
+	subscribers map[chan LocationEvent]EventFilter
+	mux         sync.RWMutex
+	closed      bool
+}
+
+// NewEventBus returns an empty EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan LocationEvent]EventFilter),
+	}
+}
+
+// Subscribe registers a subscriber for events matching the filter. The returned
+// channel is closed when the subscriber is removed or the bus is closed.
+func (b *EventBus) Subscribe(filter EventFilter) (<-chan LocationEvent, func()) {
+	ch := make(chan LocationEvent, eventBufferSize)
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = filter
+
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+func (b *EventBus) unsubscribe(ch chan LocationEvent) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish delivers the event to all matching subscribers. Slow subscribers
+// with a full buffer miss the event rather than blocking the publisher.
+func (b *EventBus) Publish(e LocationEvent) {
+	if b == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	for ch, filter := range b.subscribers {
+		if !filter.Matches(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Close removes all subscribers, ending their event streams
+func (b *EventBus) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.closed = true
+}
@@ -0,0 +1,201 @@
+package rfid
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestEventBusFilter(t *testing.T) {
+	bus := NewEventBus()
+	defer bus.Close()
+
+	all, unsubAll := bus.Subscribe(EventFilter{})
+	defer unsubAll()
+	room, unsubRoom := bus.Subscribe(EventFilter{RoomID: 1})
+	defer unsubRoom()
+	group, unsubGroup := bus.Subscribe(EventFilter{GroupID: 7})
+	defer unsubGroup()
+	scoped, unsubScoped := bus.Subscribe(EventFilter{GroupIDs: map[int64]bool{5: true}})
+	defer unsubScoped()
+
+	bus.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 10, RoomID: 1, GroupID: 3})
+	bus.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 11, RoomID: 2, GroupID: 7})
+
+	assert.Len(t, all, 2)
+	require.Len(t, room, 1)
+	require.Len(t, group, 1)
+	assert.Len(t, scoped, 0, "events of groups out of scope are not delivered")
+
+	e := <-room
+	assert.Equal(t, int64(10), e.StudentID)
+	assert.False(t, e.Timestamp.IsZero())
+	e = <-group
+	assert.Equal(t, int64(11), e.StudentID)
+}
+
+func TestEventBusUnsubscribeAndClose(t *testing.T) {
+	bus := NewEventBus()
+
+	ch, unsubscribe := bus.Subscribe(EventFilter{})
+	unsubscribe()
+	_, ok := <-ch
+	assert.False(t, ok, "channel should be closed after unsubscribe")
+
+	// unsubscribing twice must not panic
+	unsubscribe()
+
+	ch, _ = bus.Subscribe(EventFilter{})
+	bus.Close()
+	_, ok = <-ch
+	assert.False(t, ok, "channel should be closed after bus close")
+
+	// publishing on a closed or nil bus is a no-op
+	bus.Publish(LocationEvent{Type: EventRoomExit})
+	var nilBus *EventBus
+	nilBus.Publish(LocationEvent{Type: EventRoomExit})
+}
+
+func TestHandleEventStream(t *testing.T) {
+	api := &API{events: NewEventBus()}
+	server := httptest.NewServer(setupTestRouter(api))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?room_id=5")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// wait for the subscription to be registered before publishing
+	require.Eventually(t, func() bool {
+		api.events.mux.RLock()
+		defer api.events.mux.RUnlock()
+		return len(api.events.subscribers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	api.events.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 1, RoomID: 4})
+	api.events.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 2, RoomID: 5, Name: "Jane Doe"})
+
+	reader := bufio.NewReader(resp.Body)
+	eventLine, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event: room_entry\n", eventLine)
+
+	dataLine, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(dataLine, "data: "))
+
+	var e LocationEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(dataLine, "data: ")), &e))
+	assert.Equal(t, int64(2), e.StudentID)
+	assert.Equal(t, "Jane Doe", e.Name)
+
+	api.CloseEventStreams()
+}
+
+func TestHandleEventStreamInvalidFilter(t *testing.T) {
+	api := &API{events: NewEventBus()}
+	server := httptest.NewServer(setupTestRouter(api))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/events?group_id=abc")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+}
+
+func TestHandleEventStreamScope(t *testing.T) {
+	IsTestMode = false
+	defer func() { IsTestMode = true }()
+
+	viper.Set("auth_jwt_alg", "HS256")
+	viper.Set("auth_jwt_secret", "events-test-secret")
+	viper.Set("auth_jwt_expiry", "15m")
+	viper.Set("auth_jwt_refresh_expiry", "1h")
+	tokenAuth, err := jwt.NewTokenAuth(jwt.NewMemoryKeyStore())
+	require.NoError(t, err)
+	token := func(roles ...string) string {
+		accessToken, err := tokenAuth.CreateJWT(jwt.AppClaims{ID: 3, Sub: "staff", Roles: roles})
+		require.NoError(t, err)
+		return "Bearer " + accessToken
+	}
+	roomID := int64(5)
+
+	tests := []struct {
+		name      string
+		header    string
+		device    *TauriDevice
+		status    int
+		studentID int64 // of the first event delivered
+	}{
+		{"staff", token("staff"), nil, http.StatusOK, 1},
+		{"specialist", token("specialist"), nil, http.StatusOK, 2},
+		{"device bound to a room", "Bearer valid-key", &TauriDevice{DeviceID: "dev-1", Status: DeviceStatusActive, RoomID: &roomID}, http.StatusOK, 2},
+		{"device without room", "Bearer valid-key", &TauriDevice{DeviceID: "dev-1", Status: DeviceStatusActive}, http.StatusForbidden, 0},
+		{"user", token("user"), nil, http.StatusForbidden, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			mockStudentStore := new(MockStudentStore)
+			api := &API{store: mockRFIDStore, studentStore: mockStudentStore, events: NewEventBus()}
+			defer api.CloseEventStreams()
+
+			if tt.device != nil {
+				mockRFIDStore.On("GetDeviceByAPIKey", mock.Anything, "valid-key").Return(tt.device, nil)
+				mockRFIDStore.On("UpdateDevice", mock.Anything, "dev-1", mock.Anything).Return(nil)
+			}
+			mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(3)).Return([]int64{7}, nil).Maybe()
+
+			server := httptest.NewServer(tokenAuth.Verifier()(api.eventsAuthMiddleware(http.HandlerFunc(api.handleEventStream))))
+			defer server.Close()
+
+			req, err := http.NewRequest("GET", server.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", tt.header)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tt.status, resp.StatusCode)
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			require.Eventually(t, func() bool {
+				api.events.mux.RLock()
+				defer api.events.mux.RUnlock()
+				return len(api.events.subscribers) == 1
+			}, time.Second, 10*time.Millisecond)
+
+			api.events.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 1, RoomID: 4, GroupID: 3})
+			api.events.Publish(LocationEvent{Type: EventRoomEntry, StudentID: 2, RoomID: 5, GroupID: 7})
+
+			reader := bufio.NewReader(resp.Body)
+			_, err = reader.ReadString('\n')
+			require.NoError(t, err)
+			dataLine, err := reader.ReadString('\n')
+			require.NoError(t, err)
+
+			var e LocationEvent
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(dataLine, "data: ")), &e))
+			assert.Equal(t, tt.studentID, e.StudentID)
+			mockRFIDStore.AssertExpectations(t)
+		})
+	}
+}
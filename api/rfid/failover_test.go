@@ -2,6 +2,7 @@ package rfid
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -37,7 +38,7 @@ func TestUnknownTagID(t *testing.T) {
 	roomID := int64(101)
 
 	// Setup expectations
-	mockRFIDStore.On("SaveTag", mock.Anything, unknownTagID, readerID).Return(&Tag{
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, unknownTagID, readerID, "", mock.Anything).Return(&Tag{
 		ID:        1,
 		TagID:     unknownTagID,
 		ReaderID:  readerID,
@@ -45,8 +46,10 @@ func TestUnknownTagID(t *testing.T) {
 		CreatedAt: now,
 		UpdatedAt: now,
 	}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 
-	mockUserStore.On("GetCustomUserByTagID", mock.Anything, unknownTagID).Return(nil, errors.New("user not found"))
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, unknownTagID).Return(nil, sql.ErrNoRows)
+	mockRFIDStore.On("RecordUnknownTag", mock.Anything, unknownTagID, readerID, mock.Anything).Return(nil)
 
 	// Create a router and test server with mock authentication
 	router := setupTestRouter(api)
@@ -109,13 +112,11 @@ func TestDatabaseFailureWhenSavingTag(t *testing.T) {
 		ID:           456,
 		SchoolClass:  "4A",
 		CustomUserID: user.ID,
-		InHouse:      false,
-		WC:           false,
-		SchoolYard:   false,
+		Location:     models.LocationAbsent,
 	}
 
 	// Setup expectations for database failure
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(nil, errors.New("database connection failed"))
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(nil, errors.New("database connection failed"))
 
 	// Continue with other operations despite tag saving failure
 	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil)
@@ -141,11 +142,11 @@ func TestDatabaseFailureWhenSavingTag(t *testing.T) {
 	}
 	mockStudentStore.On("CreateStudentVisit", mock.Anything, student.ID, roomID, int64(1)).Return(mockVisit, nil)
 
-	mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID).Return(nil)
+	mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID, mock.Anything).Return(nil)
 
 	// Expect location update
-	mockStudentStore.On("UpdateStudentLocation", mock.Anything, student.ID, mock.MatchedBy(func(locations map[string]bool) bool {
-		return locations["in_house"] == true
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == student.ID && t.ToLocation == models.LocationRoom && *t.RoomID == roomID
 	})).Return(nil)
 
 	// Mock for GetRoomOccupancy
@@ -227,9 +228,7 @@ func TestErrorCreatingTimespan(t *testing.T) {
 		ID:           456,
 		SchoolClass:  "4A",
 		CustomUserID: user.ID,
-		InHouse:      false,
-		WC:           false,
-		SchoolYard:   false,
+		Location:     models.LocationAbsent,
 	}
 
 	// Setup expectations
@@ -242,7 +241,7 @@ func TestErrorCreatingTimespan(t *testing.T) {
 		UpdatedAt: now,
 	}
 
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
 	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil)
 	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(student, nil)
 
@@ -307,9 +306,7 @@ func TestErrorUpdatingStudentLocation(t *testing.T) {
 		ID:           456,
 		SchoolClass:  "4A",
 		CustomUserID: user.ID,
-		InHouse:      false,
-		WC:           false,
-		SchoolYard:   false,
+		Location:     models.LocationAbsent,
 	}
 
 	// Setup expectations
@@ -322,7 +319,8 @@ func TestErrorUpdatingStudentLocation(t *testing.T) {
 		UpdatedAt: now,
 	}
 
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil)
 	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(student, nil)
 
@@ -347,12 +345,12 @@ func TestErrorUpdatingStudentLocation(t *testing.T) {
 	mockStudentStore.On("CreateStudentVisit", mock.Anything, student.ID, roomID, timespan.ID).Return(visit, nil)
 
 	// Success recording room entry
-	mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID).Return(nil)
+	mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID, mock.Anything).Return(nil)
 
 	// Error updating student location
 	locationErr := errors.New("failed to update student location")
-	mockStudentStore.On("UpdateStudentLocation", mock.Anything, student.ID, mock.MatchedBy(func(locations map[string]bool) bool {
-		return locations["in_house"] == true
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == student.ID && t.ToLocation == models.LocationRoom && *t.RoomID == roomID
 	})).Return(locationErr)
 
 	// Success getting room occupancy despite location update error
@@ -464,7 +462,8 @@ func TestUserFoundButNoStudentRecord(t *testing.T) {
 		UpdatedAt: now,
 	}
 
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil)
 	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(nil, errors.New("student not found"))
 
@@ -538,7 +537,10 @@ func TestConcurrentRFIDTagReads(t *testing.T) {
 			CreatedAt: now,
 			UpdatedAt: now,
 		}
-		mockRFIDStore.On("SaveTag", mock.Anything, tagIDs[i], readerIDs[i]).Return(mockTag, nil)
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagIDs[i], readerIDs[i], "", mock.Anything).Return(mockTag, nil)
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagIDs[i]).Return(&models.CustomUser{ID: int64(i + 1)}, nil)
+		mockRFIDStore.On("GetReader", mock.Anything, readerIDs[i]).Return(nil, sql.ErrNoRows)
 	}
 
 	// Run test: Simulate concurrent tag reads
@@ -616,7 +618,7 @@ func TestMissingStores(t *testing.T) {
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil)
 
 	// Perform request
 	resp := performRequest(t, serverWithoutUserStore, "POST", "/room-entry", bytes.NewBuffer(jsonData))
@@ -0,0 +1,339 @@
+package rfid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/email"
+	"github.com/dhax/go-base/logging"
+)
+
+// handleHeartbeat stores the status reported by a device and marks it online
+func (a *API) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	device, ok := r.Context().Value("device").(*TauriDevice)
+	if !ok {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("device authentication error")))
+		return
+	}
+
+	data := &HeartbeatRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	now := time.Now()
+	var offset time.Duration
+	if data.SentAt != "" {
+		sentAt, err := time.Parse(time.RFC3339Nano, data.SentAt)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("sent_at must be an RFC 3339 time")))
+			return
+		}
+		offset = sentAt.Sub(now)
+	}
+
+	wasOffline := device.LastHeartbeatAt != nil && !device.Online
+	device.LastHeartbeatAt = &now
+	device.AppVersion = data.AppVersion
+	device.BufferedReads = data.BufferedReads
+	device.ClockOffsetMs = offset.Milliseconds()
+	device.ReaderHealth = trackReadersDown(device.ReaderHealth, data.Readers, now)
+
+	if err := a.store.RecordHeartbeat(r.Context(), device); err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	log := logging.GetLogEntry(r).WithField("device_id", device.DeviceID)
+	if wasOffline {
+		log.Info("Device back online")
+	}
+	for _, rd := range device.ReaderHealth {
+		if rd.Status != ReaderHealthOK {
+			log.WithFields(logrus.Fields{
+				"reader_id":  rd.ReaderID,
+				"status":     rd.Status,
+				"message":    rd.Message,
+				"down_since": rd.DownSince,
+			}).Warning("Reader hardware problem")
+		}
+	}
+
+	render.JSON(w, r, &HeartbeatResponse{
+		Success:       true,
+		ServerTime:    now,
+		ClockOffsetMs: device.ClockOffsetMs,
+	})
+}
+
+// trackReadersDown sets the time since which each reported reader is down,
+// carried over from the previous heartbeat while the reader stays down
+func trackReadersDown(previous, readers []ReaderHealth, now time.Time) []ReaderHealth {
+	downSince := map[string]*time.Time{}
+	for _, rd := range previous {
+		if rd.Status != ReaderHealthOK {
+			downSince[rd.ReaderID] = rd.DownSince
+		}
+	}
+
+	for i := range readers {
+		readers[i].DownSince = nil
+		if readers[i].Status == ReaderHealthOK {
+			continue
+		}
+		if since := downSince[readers[i].ReaderID]; since != nil {
+			readers[i].DownSince = since
+		} else {
+			readers[i].DownSince = &now
+		}
+	}
+	return readers
+}
+
+// readerDown reports whether a reader has a problem
+func readerDown(readers []ReaderHealth) bool {
+	for _, rd := range readers {
+		if rd.Status != ReaderHealthOK {
+			return true
+		}
+	}
+	return false
+}
+
+// handleHealthDashboard returns the health of all active devices
+func (a *API) handleHealthDashboard(w http.ResponseWriter, r *http.Request) {
+	devices, err := a.store.ListDevices(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	response := &HealthDashboardResponse{Devices: []DeviceHealth{}}
+	for _, d := range devices {
+		if d.Status != DeviceStatusActive {
+			continue
+		}
+
+		health := deviceHealth(&d)
+		switch health {
+		case DeviceHealthOnline:
+			response.Online++
+		case DeviceHealthDegraded:
+			response.Degraded++
+		case DeviceHealthOffline:
+			response.Offline++
+		default:
+			response.Unknown++
+		}
+
+		response.Devices = append(response.Devices, DeviceHealth{
+			DeviceID:        d.DeviceID,
+			Name:            d.Name,
+			RoomID:          d.RoomID,
+			Health:          health,
+			LastHeartbeatAt: d.LastHeartbeatAt,
+			OfflineSince:    d.OfflineSince,
+			AppVersion:      d.AppVersion,
+			BufferedReads:   d.BufferedReads,
+			ClockOffsetMs:   d.ClockOffsetMs,
+			Readers:         d.ReaderHealth,
+		})
+	}
+
+	render.JSON(w, r, response)
+}
+
+// deviceHealth returns the health state of a device from its latest heartbeat
+func deviceHealth(d *TauriDevice) string {
+	if d.LastHeartbeatAt == nil {
+		return DeviceHealthUnknown
+	}
+	if !d.Online {
+		return DeviceHealthOffline
+	}
+	if readerDown(d.ReaderHealth) {
+		return DeviceHealthDegraded
+	}
+	return DeviceHealthOnline
+}
+
+// StartHealthMonitor checks the heartbeats of the devices periodically, marks
+// silent devices offline and sends alerts for rooms whose reader is down. The
+// returned function stops the monitor.
+func (a *API) StartHealthMonitor() func() {
+	if a.healthCheckInterval <= 0 {
+		return func() {}
+	}
+
+	ticker := time.NewTicker(a.healthCheckInterval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				a.checkDeviceHealth(context.Background(), time.Now())
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// checkDeviceHealth marks the devices silent for longer than the offline limit
+// offline and alerts about devices bound to a room offline, or with a reader
+// down, for longer than the alert limit. Devices are alerted about once per
+// outage, the check runs on one instance at a time.
+func (a *API) checkDeviceHealth(ctx context.Context, now time.Time) {
+	log := logging.Logger.WithField("chore", "deviceHealth")
+
+	unlock, ok, err := a.store.LockHealthCheck(ctx)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !ok {
+		log.Debug("Device health checked on another instance")
+		return
+	}
+	defer unlock()
+
+	offline, err := a.store.MarkDevicesOffline(ctx, now.Add(-a.offlineAfter))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, d := range offline {
+		log.WithField("device_id", d.DeviceID).Warning("Device offline")
+	}
+
+	if a.mailer == nil || len(a.alertRecipients) == 0 {
+		return
+	}
+
+	devices, err := a.store.ListDevicesToAlert(ctx, now.Add(-a.offlineAlertAfter))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, d := range devices {
+		if !d.Online {
+			content := ContentDeviceOffline{
+				DeviceID:     d.DeviceID,
+				Name:         d.Name,
+				RoomID:       *d.RoomID,
+				OfflineSince: *d.OfflineSince,
+				Minutes:      int(now.Sub(*d.OfflineSince).Minutes()),
+			}
+			// Failed alerts are retried on the next check
+			if !a.sendAlert(log, func(address string) email.Message { return DeviceOfflineEmail(address, content) }) {
+				continue
+			}
+			if err := a.store.SetOfflineAlerted(ctx, d.DeviceID, now); err != nil {
+				log.WithField("device_id", d.DeviceID).Error(err)
+			}
+			continue
+		}
+
+		content := ContentReaderDown{
+			DeviceID: d.DeviceID,
+			Name:     d.Name,
+			RoomID:   *d.RoomID,
+		}
+		for _, rd := range d.ReaderHealth {
+			if rd.Status == ReaderHealthOK || rd.DownSince == nil {
+				continue
+			}
+			content.Readers = append(content.Readers, rd)
+			if content.DownSince.IsZero() || rd.DownSince.Before(content.DownSince) {
+				content.DownSince = *rd.DownSince
+			}
+		}
+		content.Minutes = int(now.Sub(content.DownSince).Minutes())
+
+		if !a.sendAlert(log, func(address string) email.Message { return ReaderDownEmail(address, content) }) {
+			continue
+		}
+		if err := a.store.SetReaderAlerted(ctx, d.DeviceID, now); err != nil {
+			log.WithField("device_id", d.DeviceID).Error(err)
+		}
+	}
+}
+
+// sendAlert sends the alert created by message to all alert recipients and
+// reports whether it was sent to any of them
+func (a *API) sendAlert(log logrus.FieldLogger, message func(address string) email.Message) bool {
+	sent := false
+	for _, address := range a.alertRecipients {
+		if err := a.mailer.Send(message(address)); err != nil {
+			log.WithField("module", "email").Error(err)
+			continue
+		}
+		sent = true
+	}
+	return sent
+}
+
+// ContentDeviceOffline defines content for the device offline email template.
+type ContentDeviceOffline struct {
+	DeviceID     string
+	Name         string
+	RoomID       int64
+	OfflineSince time.Time
+	Minutes      int
+}
+
+// DeviceOfflineEmail creates an email alerting about a room's reader being down.
+func DeviceOfflineEmail(address string, content ContentDeviceOffline) email.Message {
+	return email.Message{
+		From:     email.NewEmail(os.Getenv("EMAIL_FROM_NAME"), os.Getenv("EMAIL_FROM_ADDRESS")),
+		To:       email.NewEmail("", address),
+		Subject:  fmt.Sprintf("RFID reader %s offline", content.Name),
+		Template: "deviceOffline",
+		Content:  content,
+	}
+}
+
+// ContentReaderDown defines content for the reader down email template.
+type ContentReaderDown struct {
+	DeviceID  string
+	Name      string
+	RoomID    int64
+	Readers   []ReaderHealth // readers with a problem
+	DownSince time.Time      // of the reader down longest
+	Minutes   int
+}
+
+// ReaderDownEmail creates an email alerting about a room's reader reporting a
+// hardware problem.
+func ReaderDownEmail(address string, content ContentReaderDown) email.Message {
+	return email.Message{
+		From:     email.NewEmail(os.Getenv("EMAIL_FROM_NAME"), os.Getenv("EMAIL_FROM_ADDRESS")),
+		To:       email.NewEmail("", address),
+		Subject:  fmt.Sprintf("RFID reader %s down", content.Name),
+		Template: "readerDown",
+		Content:  content,
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
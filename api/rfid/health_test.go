@@ -0,0 +1,200 @@
+package rfid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/email"
+	"github.com/dhax/go-base/logging"
+)
+
+func TestHandleHeartbeat(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	downSince := time.Now().Add(-time.Hour)
+	device := &TauriDevice{DeviceID: "dev-1", Status: DeviceStatusActive, ReaderHealth: []ReaderHealth{
+		{ReaderID: "R1", Status: ReaderHealthError, DownSince: &downSince},
+		{ReaderID: "R2", Status: ReaderHealthOK},
+	}}
+	mockRFIDStore.On("RecordHeartbeat", mock.Anything, mock.MatchedBy(func(d *TauriDevice) bool {
+		return d.DeviceID == "dev-1" && d.AppVersion == "1.4.0" && d.BufferedReads == 3 &&
+			d.ClockOffsetMs > 50000 && len(d.ReaderHealth) == 3 && d.LastHeartbeatAt != nil
+	})).Return(nil)
+
+	payload := `{"app_version":"1.4.0","buffered_reads":3,"sent_at":"` + time.Now().Add(time.Minute).Format(time.RFC3339) + `",
+		"readers":[{"reader_id":"R1","status":"ok"},{"reader_id":"R2","status":"disconnected","message":"USB unplugged"},
+		{"reader_id":"R3","status":"error","down_since":"2020-01-01T00:00:00Z"}]}`
+	req := httptest.NewRequest("POST", "/heartbeat", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), "device", device))
+	w := httptest.NewRecorder()
+
+	api.handleHeartbeat(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response HeartbeatResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+	assert.InDelta(t, 60000, response.ClockOffsetMs, 2000)
+	// Readers down are tracked by the server, not by the device
+	assert.Nil(t, device.ReaderHealth[0].DownSince)
+	assert.Equal(t, device.LastHeartbeatAt, device.ReaderHealth[1].DownSince)
+	assert.Equal(t, device.LastHeartbeatAt, device.ReaderHealth[2].DownSince)
+	mockRFIDStore.AssertExpectations(t)
+}
+
+func TestTrackReadersDown(t *testing.T) {
+	now := time.Now()
+	downSince := now.Add(-time.Hour)
+	previous := []ReaderHealth{
+		{ReaderID: "R1", Status: ReaderHealthError, DownSince: &downSince},
+		{ReaderID: "R2", Status: ReaderHealthDisconnected, DownSince: &downSince},
+		{ReaderID: "R3", Status: ReaderHealthOK},
+	}
+
+	readers := trackReadersDown(previous, []ReaderHealth{
+		{ReaderID: "R1", Status: ReaderHealthDisconnected},
+		{ReaderID: "R2", Status: ReaderHealthOK},
+		{ReaderID: "R3", Status: ReaderHealthError},
+	}, now)
+
+	assert.Equal(t, &downSince, readers[0].DownSince, "still down")
+	assert.Nil(t, readers[1].DownSince, "ok again")
+	assert.Equal(t, &now, readers[2].DownSince, "newly down")
+}
+
+func TestHandleHeartbeatInvalidReaderStatus(t *testing.T) {
+	api := &API{store: new(MockRFIDStore)}
+
+	req := httptest.NewRequest("POST", "/heartbeat", strings.NewReader(`{"readers":[{"reader_id":"R1","status":"smoking"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), "device", &TauriDevice{DeviceID: "dev-1"}))
+	w := httptest.NewRecorder()
+
+	api.handleHeartbeat(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestCheckDeviceHealth(t *testing.T) {
+	logging.NewLogger()
+	now := time.Now()
+	roomID := int64(5)
+	offlineSince := now.Add(-20 * time.Minute)
+
+	mockRFIDStore := new(MockRFIDStore)
+	var sent []email.Message
+	mailer := &email.MockMailer{SendFn: func(m email.Message) error {
+		if m.To.Address == "broken@example.com" {
+			return errors.New("mailbox unavailable")
+		}
+		sent = append(sent, m)
+		return nil
+	}}
+
+	api := &API{
+		store:             mockRFIDStore,
+		mailer:            mailer,
+		alertRecipients:   []string{"office@example.com", "broken@example.com"},
+		offlineAfter:      5 * time.Minute,
+		offlineAlertAfter: 15 * time.Minute,
+	}
+
+	unlocked := false
+	mockRFIDStore.On("LockHealthCheck", mock.Anything).Return(func() { unlocked = true }, true, nil)
+	mockRFIDStore.On("MarkDevicesOffline", mock.Anything, now.Add(-5*time.Minute)).Return([]TauriDevice{{DeviceID: "dev-2"}}, nil)
+	mockRFIDStore.On("ListDevicesToAlert", mock.Anything, now.Add(-15*time.Minute)).Return([]TauriDevice{
+		{DeviceID: "dev-1", Name: "Room 5 reader", RoomID: &roomID, OfflineSince: &offlineSince},
+		{DeviceID: "dev-3", Name: "Room 5 door", RoomID: &roomID, Online: true, ReaderHealth: []ReaderHealth{
+			{ReaderID: "R1", Status: ReaderHealthOK},
+			{ReaderID: "R2", Status: ReaderHealthError, Message: "antenna fault", DownSince: &offlineSince},
+		}},
+	}, nil)
+	mockRFIDStore.On("SetOfflineAlerted", mock.Anything, "dev-1", now).Return(nil)
+	mockRFIDStore.On("SetReaderAlerted", mock.Anything, "dev-3", now).Return(nil)
+
+	api.checkDeviceHealth(context.Background(), now)
+
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "office@example.com", sent[0].To.Address)
+	assert.Equal(t, "deviceOffline", sent[0].Template)
+	assert.Equal(t, 20, sent[0].Content.(ContentDeviceOffline).Minutes)
+	assert.Equal(t, "readerDown", sent[1].Template)
+	readerDown := sent[1].Content.(ContentReaderDown)
+	assert.Equal(t, 20, readerDown.Minutes)
+	assert.Len(t, readerDown.Readers, 1)
+	assert.Equal(t, "R2", readerDown.Readers[0].ReaderID)
+	assert.True(t, unlocked)
+	mockRFIDStore.AssertExpectations(t)
+}
+
+func TestCheckDeviceHealthOnOtherInstance(t *testing.T) {
+	logging.NewLogger()
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore, mailer: email.NewMockMailer(), alertRecipients: []string{"office@example.com"}}
+
+	mockRFIDStore.On("LockHealthCheck", mock.Anything).Return(nil, false, nil)
+
+	api.checkDeviceHealth(context.Background(), time.Now())
+
+	mockRFIDStore.AssertExpectations(t)
+	mockRFIDStore.AssertNotCalled(t, "MarkDevicesOffline", mock.Anything, mock.Anything)
+}
+
+func TestCheckDeviceHealthWithoutRecipients(t *testing.T) {
+	logging.NewLogger()
+	now := time.Now()
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore, mailer: email.NewMockMailer(), offlineAfter: 5 * time.Minute}
+
+	mockRFIDStore.On("LockHealthCheck", mock.Anything).Return(func() {}, true, nil)
+	mockRFIDStore.On("MarkDevicesOffline", mock.Anything, now.Add(-5*time.Minute)).Return([]TauriDevice{}, nil)
+
+	api.checkDeviceHealth(context.Background(), now)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockRFIDStore.AssertNotCalled(t, "ListDevicesToAlert", mock.Anything, mock.Anything)
+}
+
+func TestHandleHealthDashboard(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	now := time.Now()
+	mockRFIDStore.On("ListDevices", mock.Anything).Return([]TauriDevice{
+		{DeviceID: "dev-1", Status: DeviceStatusActive, Online: true, LastHeartbeatAt: &now},
+		{DeviceID: "dev-2", Status: DeviceStatusActive, Online: true, LastHeartbeatAt: &now,
+			ReaderHealth: []ReaderHealth{{ReaderID: "R2", Status: ReaderHealthError}}},
+		{DeviceID: "dev-3", Status: DeviceStatusActive, LastHeartbeatAt: &now, OfflineSince: &now},
+		{DeviceID: "dev-4", Status: DeviceStatusActive},
+		{DeviceID: "dev-5", Status: DeviceStatusPending},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	api.AdminRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response HealthDashboardResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Online)
+	assert.Equal(t, 1, response.Degraded)
+	assert.Equal(t, 1, response.Offline)
+	assert.Equal(t, 1, response.Unknown)
+	assert.Len(t, response.Devices, 4)
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, splitList(" a@example.com, ,b@example.com "))
+	assert.Empty(t, splitList(""))
+}
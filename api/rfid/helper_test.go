@@ -58,7 +58,6 @@ func mockAPIKeyAuthMiddleware(next http.Handler) http.Handler {
 			LastSyncAt:  nil,
 			LastIP:      "127.0.0.1",
 			Status:      "active",
-			APIKey:      "test_api_key",
 			CreatedAt:   time.Now(),
 			UpdatedAt:   time.Now(),
 		}
@@ -79,7 +78,7 @@ func setupTestRouter(api *API) *chi.Mux {
 	r.Post("/devices", api.handleRegisterDevice)
 	r.Get("/app/status", api.handleTauriStatus)
 	r.Post("/tag", api.handleTagRead)
-	r.Get("/tags", api.handleGetAllTags)
+	r.Get("/tags", api.handleListTags)
 	r.Post("/track-student", api.handleStudentTracking)
 	r.Post("/room-entry", api.handleRoomEntry)
 	r.Post("/room-exit", api.handleRoomExit)
@@ -88,6 +87,7 @@ func setupTestRouter(api *API) *chi.Mux {
 	r.Get("/room/{id}/visits", api.handleGetRoomVisits)
 	r.Get("/visits/today", api.handleGetTodayVisits)
 	r.Post("/app/sync", api.handleTauriSync)
+	r.Get("/events", api.handleEventStream)
 
 	r.Route("/devices", func(r chi.Router) {
 		r.Get("/", api.handleListDevices)
@@ -115,7 +115,6 @@ func setupTestAPI(t *testing.T) (*API, *MockRFIDStore) {
 		LastSyncAt:  nil,
 		LastIP:      "127.0.0.1",
 		Status:      "active",
-		APIKey:      "test_api_key",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}, nil)
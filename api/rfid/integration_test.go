@@ -49,9 +49,7 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		ID:           456,
 		SchoolClass:  "4A",
 		CustomUserID: user.ID,
-		InHouse:      false,
-		WC:           false,
-		SchoolYard:   false,
+		Location:     models.LocationAbsent,
 	}
 	room := &models.Room{
 		ID:       roomID,
@@ -93,6 +91,7 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		RoomName:     room.RoomName,
 		Capacity:     room.Capacity,
 		StudentCount: 1,
+		FreeSeats:    room.Capacity - 1,
 		Students: []RoomOccupancyStudent{
 			{
 				ID:        student.ID,
@@ -105,15 +104,14 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 	// PHASE 1: Student enters the building (tag read at entrance)
 	t.Run("Phase 1: Student enters building", func(t *testing.T) {
 		// Setup expectations for tag read
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID, readerID).Return(mockTag, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, readerID, "", mock.Anything).Return(mockTag, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(student, nil).Once()
 
 		// Expect student location update to "in-house"
-		mockStudentStore.On("UpdateStudentLocation", mock.Anything, student.ID, mock.MatchedBy(func(locations map[string]bool) bool {
-			return locations["in_house"] == true &&
-				locations["wc"] == false &&
-				locations["school_yard"] == false
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student.ID && t.ToLocation == models.LocationInHouse
 		})).Return(nil).Once()
 
 		// Make request for student tracking
@@ -138,13 +136,14 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 
 		assert.True(t, trackResp.Success)
 		assert.Equal(t, "Jane Doe", trackResp.Name)
-		assert.Equal(t, "in-house", trackResp.Location)
+		assert.Equal(t, string(models.LocationInHouse), trackResp.Location)
 	})
 
 	// PHASE 2: Student enters a classroom
 	t.Run("Phase 2: Student enters classroom", func(t *testing.T) {
 		// Setup expectations
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID, "ROOM_READER").Return(mockTag, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, "ROOM_READER", "", mock.Anything).Return(mockTag, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(student, nil).Once()
 
@@ -155,13 +154,11 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		mockStudentStore.On("CreateStudentVisit", mock.Anything, student.ID, roomID, timespan.ID).Return(visit, nil).Once()
 
 		// Room entry record
-		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID).Return(nil).Once()
+		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student.ID, roomID, mock.Anything).Return(nil).Once()
 
 		// Student location update
-		mockStudentStore.On("UpdateStudentLocation", mock.Anything, student.ID, mock.MatchedBy(func(locations map[string]bool) bool {
-			return locations["in_house"] == true &&
-				locations["wc"] == false &&
-				locations["school_yard"] == false
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student.ID && t.ToLocation == models.LocationRoom && *t.RoomID == roomID
 		})).Return(nil).Once()
 
 		// Room occupancy data
@@ -191,6 +188,8 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		assert.Equal(t, student.ID, entryResp.StudentID)
 		assert.Equal(t, roomID, entryResp.RoomID)
 		assert.Equal(t, 1, entryResp.StudentCount)
+		assert.Equal(t, room.Capacity, entryResp.Capacity)
+		assert.Equal(t, room.Capacity-1, entryResp.FreeSeats)
 	})
 
 	// PHASE 3: Query room occupancy
@@ -212,14 +211,17 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		assert.Equal(t, roomID, occupancyResp.RoomID)
 		assert.Equal(t, room.RoomName, occupancyResp.RoomName)
 		assert.Equal(t, 1, occupancyResp.StudentCount)
+		assert.Equal(t, room.Capacity-1, occupancyResp.FreeSeats)
 		assert.Len(t, occupancyResp.Students, 1)
 		assert.Equal(t, student.ID, occupancyResp.Students[0].ID)
+		assert.Equal(t, "Jane Doe", occupancyResp.Students[0].Name)
 	})
 
 	// PHASE 4: Student leaves the classroom
 	t.Run("Phase 4: Student exits classroom", func(t *testing.T) {
 		// Setup expectations
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID, "EXIT_READER").Return(mockTag, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, "EXIT_READER", "", mock.Anything).Return(mockTag, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(user, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user.ID).Return(student, nil).Once()
 
@@ -241,7 +243,12 @@ func TestRFIDIntegrationFlow(t *testing.T) {
 		mockTimespanStore.On("UpdateTimespanEndTime", mock.Anything, timespan.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
 
 		// Room exit record
-		mockRFIDStore.On("RecordRoomExit", mock.Anything, student.ID, roomID).Return(nil).Once()
+		mockRFIDStore.On("RecordRoomExit", mock.Anything, student.ID, roomID, mock.Anything).Return(nil).Once()
+
+		// The student is tracked in the room and returns to the building
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student.ID && t.ToLocation == models.LocationInHouse
+		})).Return(nil).Once()
 
 		// Updated room occupancy after exit
 		emptyRoomOccupancy := &RoomOccupancyData{
@@ -430,14 +437,15 @@ func TestMultipleStudentsAndRooms(t *testing.T) {
 		}
 
 		// Setup expectations for student 1
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID1, "CLASSROOM_READER").Return(mockTag1, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID1, "CLASSROOM_READER", "", mock.Anything).Return(mockTag1, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID1).Return(user1, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user1.ID).Return(student1, nil).Once()
 		mockTimespanStore.On("CreateTimespan", mock.Anything, mock.AnythingOfType("time.Time"), mock.Anything).Return(timespan1, nil).Once()
 		mockStudentStore.On("CreateStudentVisit", mock.Anything, student1ID, classroom, timespan1.ID).Return(visit1, nil).Once()
-		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student1ID, classroom).Return(nil).Once()
-		mockStudentStore.On("UpdateStudentLocation", mock.Anything, student1ID, mock.MatchedBy(func(locations map[string]bool) bool {
-			return locations["in_house"] == true
+		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student1ID, classroom, mock.Anything).Return(nil).Once()
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student1ID && t.ToLocation == models.LocationRoom && *t.RoomID == classroom
 		})).Return(nil).Once()
 		mockRFIDStore.On("GetRoomOccupancy", mock.Anything, classroom).Return(roomOccupancy1, nil).Once()
 
@@ -498,14 +506,15 @@ func TestMultipleStudentsAndRooms(t *testing.T) {
 		}
 
 		// Setup expectations for student 2
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID2, "LIBRARY_READER").Return(mockTag2, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID2, "LIBRARY_READER", "", mock.Anything).Return(mockTag2, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID2).Return(user2, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user2.ID).Return(student2, nil).Once()
 		mockTimespanStore.On("CreateTimespan", mock.Anything, mock.AnythingOfType("time.Time"), mock.Anything).Return(timespan2, nil).Once()
 		mockStudentStore.On("CreateStudentVisit", mock.Anything, student2ID, library, timespan2.ID).Return(visit2, nil).Once()
-		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student2ID, library).Return(nil).Once()
-		mockStudentStore.On("UpdateStudentLocation", mock.Anything, student2ID, mock.MatchedBy(func(locations map[string]bool) bool {
-			return locations["in_house"] == true
+		mockRFIDStore.On("RecordRoomEntry", mock.Anything, student2ID, library, mock.Anything).Return(nil).Once()
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student2ID && t.ToLocation == models.LocationRoom && *t.RoomID == library
 		})).Return(nil).Once()
 		mockRFIDStore.On("GetRoomOccupancy", mock.Anything, library).Return(roomOccupancy2, nil).Once()
 
@@ -629,12 +638,16 @@ func TestMultipleStudentsAndRooms(t *testing.T) {
 		classroomVisits := []models.Visit{visit1}
 
 		// Expectations for student 1 exit
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID1, "CLASSROOM_EXIT").Return(mockTag1Exit, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID1, "CLASSROOM_EXIT", "", mock.Anything).Return(mockTag1Exit, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID1).Return(user1, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user1.ID).Return(student1, nil).Once()
 		mockStudentStore.On("GetRoomVisits", mock.Anything, classroom, mock.Anything, true).Return(classroomVisits, nil).Once()
 		mockTimespanStore.On("UpdateTimespanEndTime", mock.Anything, timespan1NoEnd.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
-		mockRFIDStore.On("RecordRoomExit", mock.Anything, student1ID, classroom).Return(nil).Once()
+		mockRFIDStore.On("RecordRoomExit", mock.Anything, student1ID, classroom, mock.Anything).Return(nil).Once()
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student1ID && t.ToLocation == models.LocationInHouse
+		})).Return(nil).Once()
 
 		roomOccupancy1Empty := &RoomOccupancyData{
 			RoomID:       classroom,
@@ -691,12 +704,16 @@ func TestMultipleStudentsAndRooms(t *testing.T) {
 		libraryVisits := []models.Visit{visit2}
 
 		// Expectations for student 2 exit
-		mockRFIDStore.On("SaveTag", mock.Anything, tagID2, "LIBRARY_EXIT").Return(mockTag2Exit, nil).Once()
+		mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID2, "LIBRARY_EXIT", "", mock.Anything).Return(mockTag2Exit, nil).Once()
+		mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
 		mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID2).Return(user2, nil).Once()
 		mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, user2.ID).Return(student2, nil).Once()
 		mockStudentStore.On("GetRoomVisits", mock.Anything, library, mock.Anything, true).Return(libraryVisits, nil).Once()
 		mockTimespanStore.On("UpdateTimespanEndTime", mock.Anything, timespan2NoEnd.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
-		mockRFIDStore.On("RecordRoomExit", mock.Anything, student2ID, library).Return(nil).Once()
+		mockRFIDStore.On("RecordRoomExit", mock.Anything, student2ID, library, mock.Anything).Return(nil).Once()
+		mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+			return t.StudentID == student2ID && t.ToLocation == models.LocationInHouse
+		})).Return(nil).Once()
 
 		roomOccupancy2Empty := &RoomOccupancyData{
 			RoomID:       library,
@@ -3,37 +3,79 @@ package rfid
 import (
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/dhax/go-base/models"
 )
 
 // Tag represents an RFID tag read
 type Tag struct {
 	ID        int64     `json:"id" bun:"id,pk,autoincrement"`
+	DeviceID  string    `json:"device_id,omitempty" bun:"device_id,nullzero"` // device that sent the read
 	TagID     string    `json:"tag_id" bun:"tag_id,notnull"`
 	ReaderID  string    `json:"reader_id" bun:"reader_id,notnull"`
-	ReadAt    time.Time `json:"read_at" bun:"read_at,notnull"`
-	CreatedAt time.Time `json:"created_at" bun:"created_at,notnull"`
+	EventID   string    `json:"event_id,omitempty" bun:"event_id,nullzero"` // client event ID, unique per reader
+	ReadAt    time.Time `json:"read_at" bun:"read_at,notnull"`              // time the reader read the tag
+	CreatedAt time.Time `json:"created_at" bun:"created_at,notnull"`        // time the server received the read
 	UpdatedAt time.Time `json:"updated_at" bun:"updated_at,notnull"`
+	// ProcessedAt is the time the read was processed, unset while processing failed
+	ProcessedAt time.Time `json:"-" bun:"processed_at,nullzero"`
+}
+
+// TagListResponse is a page of the tag read history
+type TagListResponse struct {
+	Tags       []Tag  `json:"tags"`
+	NextCursor string `json:"next_cursor,omitempty"` // absent on the last page
+}
+
+// HourlyReadCount is the number of reads of a reader within an hour
+type HourlyReadCount struct {
+	ReaderID     string    `json:"reader_id" bun:"reader_id"`
+	Hour         time.Time `json:"hour" bun:"hour"`
+	Reads        int       `json:"reads" bun:"reads"`
+	DistinctTags int       `json:"distinct_tags" bun:"distinct_tags"` // few distinct tags with many reads hint at a stuck card
 }
 
 // TagReadRequest is the payload for tag read endpoint
 type TagReadRequest struct {
 	TagID    string `json:"tag_id"`
 	ReaderID string `json:"reader_id"`
-	ReadAt   string `json:"read_at,omitempty"` // Optional ISO8601 timestamp
+	ReadAt   string `json:"read_at,omitempty"`  // Optional RFC 3339 time of the read, defaults to now
+	EventID  string `json:"event_id,omitempty"` // Optional client event ID making retries safe
 }
 
 // Bind preprocesses a TagReadRequest
 func (t *TagReadRequest) Bind(r *http.Request) error {
-	// Validation logic can be added here
+	return validateEventID(t.EventID)
+}
+
+// TagReadResponse is the response for the tag read endpoint
+type TagReadResponse struct {
+	*Tag
+	Duplicate bool   `json:"duplicate,omitempty"` // the event ID was already received
+	Decision  string `json:"decision,omitempty"`  // how the read was handled
+}
+
+// maxEventIDLength is the maximum length of client event IDs
+const maxEventIDLength = 255
+
+// validateEventID checks an optional client event ID
+func validateEventID(eventID string) error {
+	if len(eventID) > maxEventIDLength {
+		return fmt.Errorf("event_id must not exceed %d characters", maxEventIDLength)
+	}
 	return nil
 }
 
 // TauriSyncRequest is the payload for Tauri app sync endpoint
 type TauriSyncRequest struct {
-	DeviceID   string    `json:"device_id"`
-	Data       []SyncTag `json:"data"`
-	AppVersion string    `json:"app_version,omitempty"`
+	DeviceID      string    `json:"device_id"`
+	Data          []SyncTag `json:"data"`
+	AppVersion    string    `json:"app_version,omitempty"`
+	ConfigVersion int       `json:"config_version,omitempty"` // version of the configuration applied by the device
 }
 
 // SyncTag represents a tag record from the Tauri app
@@ -41,6 +83,7 @@ type SyncTag struct {
 	TagID       string    `json:"tag_id"`
 	ReaderID    string    `json:"reader_id"`
 	LocalReadAt time.Time `json:"local_read_at"`
+	EventID     string    `json:"event_id,omitempty"` // Optional client event ID making retries safe
 }
 
 // Bind preprocesses a TauriSyncRequest
@@ -48,13 +91,19 @@ func (t *TauriSyncRequest) Bind(r *http.Request) error {
 	if t.DeviceID == "" {
 		return fmt.Errorf("device_id is required")
 	}
+	for _, tag := range t.Data {
+		if err := validateEventID(tag.EventID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // TauriSyncResponse is the response for the Tauri app sync endpoint
 type TauriSyncResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Results []SyncTagResult `json:"results,omitempty"` // result of every read, in request order
 }
 
 // AppStats contains statistics for the Tauri app
@@ -71,20 +120,51 @@ type AppStatus struct {
 	Timestamp time.Time `json:"timestamp"`
 	Stats     AppStats  `json:"stats"`
 	Version   string    `json:"version"`
+
+	DeviceStatus string `json:"device_status,omitempty"` // status of the authenticated device
 }
 
-// TauriDevice represents a registered Tauri desktop application device
+// The list of device statuses. Registered devices are pending until an admin
+// approves or rejects them.
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusActive   = "active"
+	DeviceStatusInactive = "inactive"
+	DeviceStatusRejected = "rejected"
+)
+
+// TauriDevice represents a registered Tauri desktop application device. API keys
+// are stored as SHA-256 hash, with their first characters for lookup.
 type TauriDevice struct {
-	ID          int64      `json:"id" bun:"id,pk,autoincrement"`
-	DeviceID    string     `json:"device_id" bun:"device_id,notnull,unique"`
-	Name        string     `json:"name" bun:"name,notnull"`
-	Description string     `json:"description,omitempty" bun:"description"`
-	LastSyncAt  *time.Time `json:"last_sync_at,omitempty" bun:"last_sync_at"`
-	LastIP      string     `json:"last_ip,omitempty" bun:"last_ip"`
-	Status      string     `json:"status" bun:"status,notnull"`
-	APIKey      string     `json:"-" bun:"api_key,notnull,unique"` // Not exposed in JSON
-	CreatedAt   time.Time  `json:"created_at" bun:"created_at,notnull"`
-	UpdatedAt   time.Time  `json:"updated_at" bun:"updated_at,notnull"`
+	ID                   int64          `json:"id" bun:"id,pk,autoincrement"`
+	DeviceID             string         `json:"device_id" bun:"device_id,notnull,unique"`
+	Name                 string         `json:"name" bun:"name,notnull"`
+	Description          string         `json:"description,omitempty" bun:"description"`
+	LastSyncAt           *time.Time     `json:"last_sync_at,omitempty" bun:"last_sync_at"`
+	LastIP               string         `json:"last_ip,omitempty" bun:"last_ip"`
+	Status               string         `json:"status" bun:"status,notnull"`
+	APIKeyPrefix         string         `json:"api_key_prefix" bun:"api_key_prefix,notnull"`
+	APIKeyHash           string         `json:"-" bun:"api_key_hash,notnull,unique"` // Not exposed in JSON
+	PreviousKeyPrefix    string         `json:"-" bun:"previous_key_prefix,nullzero"`
+	PreviousKeyHash      string         `json:"-" bun:"previous_key_hash,nullzero"`
+	PreviousKeyExpiresAt *time.Time     `json:"previous_key_expires_at,omitempty" bun:"previous_key_expires_at"` // end of the grace period of the rotated key
+	KeyRotatedAt         *time.Time     `json:"key_rotated_at,omitempty" bun:"key_rotated_at"`
+	RevokedAt            *time.Time     `json:"revoked_at,omitempty" bun:"revoked_at"`
+	LastUsedAt           *time.Time     `json:"last_used_at,omitempty" bun:"last_used_at"`
+	RoomID               *int64         `json:"room_id,omitempty" bun:"room_id"` // room the device is bound to on approval
+	ReviewedAt           *time.Time     `json:"reviewed_at,omitempty" bun:"reviewed_at"`
+	ReviewedBy           *int64         `json:"reviewed_by,omitempty" bun:"reviewed_by"` // account of the reviewing admin
+	LastHeartbeatAt      *time.Time     `json:"last_heartbeat_at,omitempty" bun:"last_heartbeat_at"`
+	AppVersion           string         `json:"app_version,omitempty" bun:"app_version,nullzero"`
+	BufferedReads        int            `json:"buffered_reads" bun:"buffered_reads,notnull"`            // reads not yet synced
+	ClockOffsetMs        int64          `json:"clock_offset_ms" bun:"clock_offset_ms,notnull"`          // device clock ahead of the server
+	ReaderHealth         []ReaderHealth `json:"reader_health,omitempty" bun:"reader_health,type:jsonb"` // hardware status of the connected readers
+	Online               bool           `json:"online" bun:"online,notnull"`
+	OfflineSince         *time.Time     `json:"offline_since,omitempty" bun:"offline_since"`
+	OfflineAlertedAt     *time.Time     `json:"offline_alerted_at,omitempty" bun:"offline_alerted_at"`
+	ReaderAlertedAt      *time.Time     `json:"reader_alerted_at,omitempty" bun:"reader_alerted_at"` // alert about a reader down sent
+	CreatedAt            time.Time      `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt            time.Time      `json:"updated_at" bun:"updated_at,notnull"`
 }
 
 // DeviceRegisterRequest is the payload for registering a new Tauri app device
@@ -114,6 +194,14 @@ type DeviceRegisterResponse struct {
 	DeviceID string      `json:"device_id"`
 }
 
+// KeyRotationResponse is the response for rotating the API key of a device
+type KeyRotationResponse struct {
+	Success              bool       `json:"success"`
+	DeviceID             string     `json:"device_id"`
+	APIKey               string     `json:"api_key"` // Visible only in the rotation response
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+}
+
 // DeviceUpdateRequest is the payload for updating a Tauri app device
 type DeviceUpdateRequest struct {
 	Name        string `json:"name,omitempty"`
@@ -123,51 +211,88 @@ type DeviceUpdateRequest struct {
 
 // Bind preprocesses a DeviceUpdateRequest
 func (req *DeviceUpdateRequest) Bind(r *http.Request) error {
+	switch req.Status {
+	case "", DeviceStatusActive, DeviceStatusInactive:
+		return nil
+	}
+	return fmt.Errorf("status must be active or inactive")
+}
+
+// DeviceApprovalRequest is the payload for approving a device registration
+type DeviceApprovalRequest struct {
+	RoomID *int64 `json:"room_id,omitempty"`
+}
+
+// Bind preprocesses a DeviceApprovalRequest
+func (req *DeviceApprovalRequest) Bind(r *http.Request) error {
 	return nil
 }
 
 // DeviceSyncHistory represents a record of a device synchronization
 type DeviceSyncHistory struct {
-	ID         int64     `json:"id" bun:"id,pk,autoincrement"`
-	DeviceID   string    `json:"device_id" bun:"device_id,notnull"`
-	SyncAt     time.Time `json:"sync_at" bun:"sync_at,notnull"`
-	IPAddress  string    `json:"ip_address,omitempty" bun:"ip_address"`
-	TagsCount  int       `json:"tags_count" bun:"tags_count,notnull"`
-	AppVersion string    `json:"app_version,omitempty" bun:"app_version"`
-	CreatedAt  time.Time `json:"created_at" bun:"created_at,notnull"`
+	bun.BaseModel `bun:"table:tauri_device_syncs"`
+
+	ID            int64     `json:"id" bun:"id,pk,autoincrement"`
+	DeviceID      string    `json:"device_id" bun:"device_id,notnull"`
+	SyncAt        time.Time `json:"sync_at" bun:"sync_at,notnull"`
+	IPAddress     string    `json:"ip_address,omitempty" bun:"ip_address"`
+	TagsCount     int       `json:"tags_count" bun:"tags_count,notnull"`
+	AppVersion    string    `json:"app_version,omitempty" bun:"app_version"`
+	ConfigVersion int       `json:"config_version" bun:"config_version,notnull"` // configuration applied by the device
+	CreatedAt     time.Time `json:"created_at" bun:"created_at,notnull"`
 }
 
 // RoomEntryRequest represents a student entering a room with an RFID tag
 type RoomEntryRequest struct {
 	TagID    string `json:"tag_id"`
-	RoomID   int64  `json:"room_id"`
+	RoomID   int64  `json:"room_id"` // optional for registered room readers
 	ReaderID string `json:"reader_id"`
+	EventID  string `json:"event_id,omitempty"` // Optional client event ID making retries safe
+	ReadAt   string `json:"read_at,omitempty"`  // Optional RFC 3339 time of the read, defaults to now
 }
 
 // Bind preprocesses a RoomEntryRequest
 func (req *RoomEntryRequest) Bind(r *http.Request) error {
-	return nil
+	return validateEventID(req.EventID)
 }
 
 // RoomExitRequest represents a student exiting a room with an RFID tag
 type RoomExitRequest struct {
 	TagID    string `json:"tag_id"`
-	RoomID   int64  `json:"room_id"`
+	RoomID   int64  `json:"room_id"` // optional for registered room readers
 	ReaderID string `json:"reader_id"`
+	EventID  string `json:"event_id,omitempty"` // Optional client event ID making retries safe
+	ReadAt   string `json:"read_at,omitempty"`  // Optional RFC 3339 time of the read, defaults to now
 }
 
 // Bind preprocesses a RoomExitRequest
 func (req *RoomExitRequest) Bind(r *http.Request) error {
-	return nil
+	return validateEventID(req.EventID)
 }
 
 // OccupancyResponse represents a response for room occupancy operations
 type OccupancyResponse struct {
 	Success      bool   `json:"success"`
 	Message      string `json:"message"`
+	Duplicate    bool   `json:"duplicate,omitempty"`
+	Decision     string `json:"decision,omitempty"` // how the read was handled
 	StudentID    int64  `json:"student_id,omitempty"`
 	RoomID       int64  `json:"room_id,omitempty"`
 	StudentCount int    `json:"student_count,omitempty"`
+	Capacity     int    `json:"capacity,omitempty"`
+	FreeSeats    int    `json:"free_seats,omitempty"`
+}
+
+// StudentRoomVisit represents a student's stay in a room as recorded by the RFID readers
+type StudentRoomVisit struct {
+	bun.BaseModel `bun:"table:student_room_visits"`
+
+	ID        int64      `json:"id" bun:"id,pk,autoincrement"`
+	RoomID    int64      `json:"room_id" bun:"room_id,notnull"`
+	StudentID int64      `json:"student_id" bun:"student_id,notnull"`
+	EntryTime time.Time  `json:"entry_time" bun:"entry_time,notnull"`
+	ExitTime  *time.Time `json:"exit_time,omitempty" bun:"exit_time"`
+	CreatedAt time.Time  `json:"created_at" bun:"created_at,notnull"`
 }
 
 // RoomOccupancyStudent represents a student in a room for occupancy reporting
@@ -183,5 +308,419 @@ type RoomOccupancyData struct {
 	RoomName     string                 `json:"room_name"`
 	Capacity     int                    `json:"capacity"`
 	StudentCount int                    `json:"student_count"`
+	FreeSeats    int                    `json:"free_seats"`
 	Students     []RoomOccupancyStudent `json:"students"`
 }
+
+// Reader location types describe the area an RFID reader is mounted at
+const (
+	ReaderLocationBuilding   = "building"
+	ReaderLocationRoom       = "room"
+	ReaderLocationWC         = "wc"
+	ReaderLocationSchoolYard = "schoolyard"
+	ReaderLocationBus        = "bus"
+	ReaderLocationPickup     = "pickup"
+)
+
+// Reader directions define whether a read moves a student into or out of the area
+const (
+	ReaderDirectionEntry  = "entry"
+	ReaderDirectionExit   = "exit"
+	ReaderDirectionToggle = "toggle"
+)
+
+// Anti-passback policies define how entry and exit readers handle a repeated
+// passage, e.g. a second entry into a room without exiting it in between
+const (
+	AntiPassbackIgnore = "ignore" // the read is stored but does not change the location
+	AntiPassbackToggle = "toggle" // the read is handled as passage in the other direction
+	AntiPassbackOff    = "off"    // the read is processed like any other
+)
+
+// maxDebounceSeconds is the longest debounce window a reader can be configured with
+const maxDebounceSeconds = 3600
+
+// readerAreas maps reader location types to the location of a student inside
+// the area and the location the student returns to when leaving it
+var readerAreas = map[string]struct{ inside, outside models.LocationState }{
+	ReaderLocationBuilding:   {models.LocationInHouse, models.LocationAbsent},
+	ReaderLocationRoom:       {models.LocationRoom, models.LocationInHouse},
+	ReaderLocationWC:         {models.LocationWC, models.LocationInHouse},
+	ReaderLocationSchoolYard: {models.LocationSchoolYard, models.LocationInHouse},
+	ReaderLocationBus:        {models.LocationBus, models.LocationInHouse},
+	ReaderLocationPickup:     {models.LocationPickedUp, models.LocationAbsent},
+}
+
+// Reader maps an RFID reader to the location it is mounted at, so reads can be
+// interpreted by the server without the device knowing the building layout
+type Reader struct {
+	bun.BaseModel `bun:"table:rfid_readers"`
+
+	ID           int64  `json:"id" bun:"id,pk,autoincrement"`
+	ReaderID     string `json:"reader_id" bun:"reader_id,notnull,unique"`
+	Name         string `json:"name" bun:"name,notnull"`
+	LocationType string `json:"location_type" bun:"location_type,notnull"`
+	RoomID       *int64 `json:"room_id,omitempty" bun:"room_id"`
+	Direction    string `json:"direction" bun:"direction,notnull"`
+	// Debounce window and anti-passback policy, the configured defaults apply if unset
+	DebounceSeconds *int      `json:"debounce_seconds,omitempty" bun:"debounce_seconds"`
+	AntiPassback    string    `json:"anti_passback,omitempty" bun:"anti_passback,nullzero"`
+	CreatedAt       time.Time `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt       time.Time `json:"updated_at" bun:"updated_at,notnull"`
+}
+
+// Validate checks the reader configuration
+func (rd *Reader) Validate() error {
+	if rd.ReaderID == "" {
+		return fmt.Errorf("reader_id is required")
+	}
+	if _, ok := readerAreas[rd.LocationType]; !ok {
+		return fmt.Errorf("invalid location_type: %q", rd.LocationType)
+	}
+	if rd.LocationType == ReaderLocationRoom && rd.RoomID == nil {
+		return fmt.Errorf("room_id is required for room readers")
+	}
+	if rd.LocationType != ReaderLocationRoom && rd.RoomID != nil {
+		return fmt.Errorf("room_id is only allowed for room readers")
+	}
+	switch rd.Direction {
+	case ReaderDirectionEntry, ReaderDirectionExit, ReaderDirectionToggle:
+	default:
+		return fmt.Errorf("invalid direction: %q", rd.Direction)
+	}
+	if rd.DebounceSeconds != nil && (*rd.DebounceSeconds < 0 || *rd.DebounceSeconds > maxDebounceSeconds) {
+		return fmt.Errorf("debounce_seconds must be between 0 and %d", maxDebounceSeconds)
+	}
+	switch rd.AntiPassback {
+	case "", AntiPassbackIgnore, AntiPassbackToggle, AntiPassbackOff:
+	default:
+		return fmt.Errorf("invalid anti_passback: %q", rd.AntiPassback)
+	}
+	return nil
+}
+
+// Resolve returns the location and room a read of this reader moves a student
+// to, given the student's current location. Toggle readers move students out
+// of the area if they are inside and into it otherwise.
+func (rd *Reader) Resolve(current models.LocationState, currentRoomID *int64) (models.LocationState, *int64) {
+	area := readerAreas[rd.LocationType]
+
+	entering := rd.Direction != ReaderDirectionExit
+	if rd.Direction == ReaderDirectionToggle && current == area.inside {
+		entering = rd.LocationType == ReaderLocationRoom &&
+			(currentRoomID == nil || *currentRoomID != *rd.RoomID)
+	}
+
+	if !entering {
+		return area.outside, nil
+	}
+	if rd.LocationType == ReaderLocationRoom {
+		return area.inside, rd.RoomID
+	}
+	return area.inside, nil
+}
+
+// Contains reports whether a student at the given location is inside the area
+// of the reader. Every location inside the building counts for building readers.
+func (rd *Reader) Contains(current models.LocationState, currentRoomID *int64) bool {
+	switch rd.LocationType {
+	case ReaderLocationBuilding:
+		return current.InHouse()
+	case ReaderLocationRoom:
+		return current == models.LocationRoom && sameRoom(currentRoomID, rd.RoomID)
+	}
+	return current == readerAreas[rd.LocationType].inside
+}
+
+// Repeats reports whether a read of this reader repeats the last passage of the
+// student: an entry into the area the student is inside of or an exit from an
+// area the student is not inside of. Reads of toggle readers never repeat.
+func (rd *Reader) Repeats(current models.LocationState, currentRoomID *int64) bool {
+	switch rd.Direction {
+	case ReaderDirectionEntry:
+		return rd.Contains(current, currentRoomID)
+	case ReaderDirectionExit:
+		return !rd.Contains(current, currentRoomID)
+	}
+	return false
+}
+
+// ReaderRequest is the payload for creating or updating a reader
+type ReaderRequest struct {
+	*Reader
+}
+
+// Bind preprocesses a ReaderRequest
+func (req *ReaderRequest) Bind(r *http.Request) error {
+	if req.Reader == nil {
+		return fmt.Errorf("missing reader fields")
+	}
+	if req.Direction == "" {
+		req.Direction = ReaderDirectionEntry
+	}
+	if req.Name == "" {
+		req.Name = req.ReaderID
+	}
+	return req.Validate()
+}
+
+// UnknownTag is a tag read by a reader but not assigned to any user. Unknown
+// tags are collected until they are assigned, e.g. when handing out cards.
+type UnknownTag struct {
+	bun.BaseModel `bun:"table:rfid_unknown_tags,alias:ut"`
+
+	ID          int64     `json:"id" bun:"id,pk,autoincrement"`
+	TagID       string    `json:"tag_id" bun:"tag_id,notnull,unique"`
+	ReaderID    string    `json:"reader_id" bun:"reader_id,notnull"` // reader of the latest read
+	FirstSeenAt time.Time `json:"first_seen_at" bun:"first_seen_at,notnull"`
+	LastSeenAt  time.Time `json:"last_seen_at" bun:"last_seen_at,notnull"`
+	ReadCount   int       `json:"read_count" bun:"read_count,notnull"`
+	CreatedAt   time.Time `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt   time.Time `json:"updated_at" bun:"updated_at,notnull"`
+}
+
+// AssignTagRequest is the payload for assigning an unknown tag to a user
+type AssignTagRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+// Bind preprocesses an AssignTagRequest
+func (req *AssignTagRequest) Bind(r *http.Request) error {
+	if req.UserID <= 0 {
+		return fmt.Errorf("user_id is required")
+	}
+	return nil
+}
+
+// AssignTagResponse is the response after assigning a tag to a user
+type AssignTagResponse struct {
+	Success bool   `json:"success"`
+	TagID   string `json:"tag_id"`
+	UserID  int64  `json:"user_id"`
+	Name    string `json:"name"`
+}
+
+// The list of reader hardware statuses reported in heartbeats.
+const (
+	ReaderHealthOK           = "ok"
+	ReaderHealthError        = "error"
+	ReaderHealthDisconnected = "disconnected"
+)
+
+// ReaderHealth is the hardware status of a reader connected to a device
+type ReaderHealth struct {
+	ReaderID  string     `json:"reader_id"`
+	Status    string     `json:"status"`
+	Message   string     `json:"message,omitempty"`
+	DownSince *time.Time `json:"down_since,omitempty"` // first heartbeat reporting the problem, set by the server
+}
+
+// HeartbeatRequest is the payload of the periodic heartbeat of a device
+type HeartbeatRequest struct {
+	AppVersion    string         `json:"app_version"`
+	BufferedReads int            `json:"buffered_reads"`
+	SentAt        string         `json:"sent_at,omitempty"` // RFC 3339 device time, to measure the clock offset
+	Readers       []ReaderHealth `json:"readers,omitempty"`
+}
+
+// Bind preprocesses a HeartbeatRequest
+func (req *HeartbeatRequest) Bind(r *http.Request) error {
+	if req.BufferedReads < 0 {
+		return fmt.Errorf("buffered_reads must not be negative")
+	}
+	for _, rd := range req.Readers {
+		if rd.ReaderID == "" {
+			return fmt.Errorf("reader_id is required for reader status")
+		}
+		switch rd.Status {
+		case ReaderHealthOK, ReaderHealthError, ReaderHealthDisconnected:
+		default:
+			return fmt.Errorf("invalid reader status: %q", rd.Status)
+		}
+	}
+	return nil
+}
+
+// HeartbeatResponse is the response to a heartbeat, with the server time so the
+// device can correct its clock
+type HeartbeatResponse struct {
+	Success       bool      `json:"success"`
+	ServerTime    time.Time `json:"server_time"`
+	ClockOffsetMs int64     `json:"clock_offset_ms"`
+}
+
+// The list of device health states on the dashboard.
+const (
+	DeviceHealthOnline   = "online"
+	DeviceHealthDegraded = "degraded" // online, with a reader reporting an error
+	DeviceHealthOffline  = "offline"
+	DeviceHealthUnknown  = "unknown" // no heartbeat received yet
+)
+
+// DeviceHealth is the health of an active device on the dashboard
+type DeviceHealth struct {
+	DeviceID        string         `json:"device_id"`
+	Name            string         `json:"name"`
+	RoomID          *int64         `json:"room_id,omitempty"`
+	Health          string         `json:"health"`
+	LastHeartbeatAt *time.Time     `json:"last_heartbeat_at,omitempty"`
+	OfflineSince    *time.Time     `json:"offline_since,omitempty"`
+	AppVersion      string         `json:"app_version,omitempty"`
+	BufferedReads   int            `json:"buffered_reads"`
+	ClockOffsetMs   int64          `json:"clock_offset_ms"`
+	Readers         []ReaderHealth `json:"readers,omitempty"`
+}
+
+// HealthDashboardResponse summarizes the health of all active devices
+type HealthDashboardResponse struct {
+	Online   int            `json:"online"`
+	Degraded int            `json:"degraded"`
+	Offline  int            `json:"offline"`
+	Unknown  int            `json:"unknown"`
+	Devices  []DeviceHealth `json:"devices"`
+}
+
+// The list of reader modes of a device.
+const (
+	ReaderModeSync     = "sync"     // reads are buffered and sent in sync batches
+	ReaderModeRoom     = "room"     // reads are entries and exits of the assigned room
+	ReaderModeTracking = "tracking" // reads are location changes by the reader registry
+)
+
+// DeviceConfig is the configuration of a device set by an admin. The version
+// is incremented on every change.
+type DeviceConfig struct {
+	bun.BaseModel `bun:"table:rfid_device_configs,alias:dc"`
+
+	ID                  int64     `json:"id" bun:"id,pk,autoincrement"`
+	DeviceID            string    `json:"device_id" bun:"device_id,notnull,unique"`
+	ReaderMode          string    `json:"reader_mode" bun:"reader_mode,notnull"`
+	DebounceSeconds     int       `json:"debounce_seconds" bun:"debounce_seconds,notnull"`
+	SyncIntervalSeconds int       `json:"sync_interval_seconds" bun:"sync_interval_seconds,notnull"`
+	MinAppVersion       string    `json:"min_app_version,omitempty" bun:"min_app_version,nullzero"`
+	Version             int       `json:"version" bun:"version,notnull"`
+	UpdatedBy           *int64    `json:"updated_by,omitempty" bun:"updated_by"` // account of the admin
+	CreatedAt           time.Time `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt           time.Time `json:"updated_at" bun:"updated_at,notnull"`
+}
+
+// DeviceConfigRequest is the payload for setting the configuration of a device
+type DeviceConfigRequest struct {
+	RoomID              *int64 `json:"room_id"`
+	ReaderMode          string `json:"reader_mode"`
+	DebounceSeconds     int    `json:"debounce_seconds"`
+	SyncIntervalSeconds int    `json:"sync_interval_seconds"`
+	MinAppVersion       string `json:"min_app_version,omitempty"`
+}
+
+// appVersionPattern matches versions like 1, 1.4 or 1.4.2
+var appVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+// Bind preprocesses a DeviceConfigRequest
+func (req *DeviceConfigRequest) Bind(r *http.Request) error {
+	switch req.ReaderMode {
+	case ReaderModeSync, ReaderModeTracking:
+	case ReaderModeRoom:
+		if req.RoomID == nil {
+			return fmt.Errorf("room_id is required for reader mode room")
+		}
+	default:
+		return fmt.Errorf("invalid reader_mode: %q", req.ReaderMode)
+	}
+	if req.DebounceSeconds < 0 {
+		return fmt.Errorf("debounce_seconds must not be negative")
+	}
+	if req.SyncIntervalSeconds <= 0 {
+		return fmt.Errorf("sync_interval_seconds must be positive")
+	}
+	if req.MinAppVersion != "" && !appVersionPattern.MatchString(req.MinAppVersion) {
+		return fmt.Errorf("invalid min_app_version: %q", req.MinAppVersion)
+	}
+	return nil
+}
+
+// DeviceConfigResponse is the configuration a device applies. Devices without
+// a configuration get the defaults with version 0.
+type DeviceConfigResponse struct {
+	DeviceID            string `json:"device_id"`
+	Version             int    `json:"version"`
+	RoomID              *int64 `json:"room_id,omitempty"`
+	ReaderMode          string `json:"reader_mode"`
+	DebounceSeconds     int    `json:"debounce_seconds"`
+	SyncIntervalSeconds int    `json:"sync_interval_seconds"`
+	MinAppVersion       string `json:"min_app_version,omitempty"`
+}
+
+// The list of commands that can be queued for a device.
+const (
+	DeviceCommandResync       = "resync"        // send all buffered reads again
+	DeviceCommandClearBuffer  = "clear_buffer"  // discard the buffered reads
+	DeviceCommandRebootReader = "reboot_reader" // restart a connected reader
+	DeviceCommandShowMessage  = "show_message"  // display a message on the device
+)
+
+// The list of device command statuses.
+const (
+	CommandStatusPending      = "pending"
+	CommandStatusDelivered    = "delivered"
+	CommandStatusAcknowledged = "acknowledged"
+	CommandStatusFailed       = "failed"
+)
+
+// DeviceCommand is a command queued for a device. Commands are delivered on
+// every poll until the device acknowledges them.
+type DeviceCommand struct {
+	bun.BaseModel `bun:"table:rfid_device_commands,alias:cmd"`
+
+	ID             int64      `json:"id" bun:"id,pk,autoincrement"`
+	DeviceID       string     `json:"device_id" bun:"device_id,notnull"`
+	Command        string     `json:"command" bun:"command,notnull"`
+	ReaderID       string     `json:"reader_id,omitempty" bun:"reader_id,nullzero"` // reader to reboot
+	Message        string     `json:"message,omitempty" bun:"message,nullzero"`     // message to show
+	Status         string     `json:"status" bun:"status,notnull"`
+	Result         string     `json:"result,omitempty" bun:"result,nullzero"` // reported by the device
+	CreatedBy      *int64     `json:"created_by,omitempty" bun:"created_by"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty" bun:"delivered_at"` // first delivery
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty" bun:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt      time.Time  `json:"updated_at" bun:"updated_at,notnull"`
+}
+
+// DeviceCommandRequest is the payload for queueing a command for a device
+type DeviceCommandRequest struct {
+	Command  string `json:"command"`
+	ReaderID string `json:"reader_id,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Bind preprocesses a DeviceCommandRequest
+func (req *DeviceCommandRequest) Bind(r *http.Request) error {
+	switch req.Command {
+	case DeviceCommandResync, DeviceCommandClearBuffer:
+	case DeviceCommandRebootReader:
+		if req.ReaderID == "" {
+			return fmt.Errorf("reader_id is required for command reboot_reader")
+		}
+	case DeviceCommandShowMessage:
+		if req.Message == "" {
+			return fmt.Errorf("message is required for command show_message")
+		}
+	default:
+		return fmt.Errorf("invalid command: %q", req.Command)
+	}
+	return nil
+}
+
+// CommandAckRequest is the payload a device acknowledges a command with
+type CommandAckRequest struct {
+	Success *bool  `json:"success"`
+	Result  string `json:"result,omitempty"`
+}
+
+// Bind preprocesses a CommandAckRequest
+func (req *CommandAckRequest) Bind(r *http.Request) error {
+	if req.Success == nil {
+		return fmt.Errorf("success is required")
+	}
+	return nil
+}
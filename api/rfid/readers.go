@@ -0,0 +1,249 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/logging"
+	"github.com/dhax/go-base/models"
+)
+
+// lookupReader returns the registered reader, or nil if the reader is not in the registry
+func (a *API) lookupReader(ctx context.Context, readerID string) (*Reader, error) {
+	if readerID == "" {
+		return nil, nil
+	}
+	reader, err := a.store.GetReader(ctx, readerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return reader, err
+}
+
+// readerRoom returns the room of a registered room reader, used for room
+// entries and exits sent without a room ID
+func (a *API) readerRoom(ctx context.Context, readerID string) (int64, error) {
+	reader, err := a.lookupReader(ctx, readerID)
+	if err != nil {
+		return 0, err
+	}
+	if reader == nil || reader.LocationType != ReaderLocationRoom {
+		return 0, fmt.Errorf("room_id is required unless reader %q is registered as room reader", readerID)
+	}
+	return *reader.RoomID, nil
+}
+
+// trackReaderRead moves the student to the location a read of the reader at the
+// given time resolves to and publishes the matching location event. Room readers
+// open and close room visits like room entries and exits do. Repeated entries and
+// exits are handled by the anti-passback policy of the reader. The location of the
+// student is updated in place and returned with the decision taken.
+func (a *API) trackReaderRead(ctx context.Context, log logrus.FieldLogger, reader *Reader, user *models.CustomUser, student *models.Student, at time.Time) (models.LocationState, string, error) {
+	decision := DecisionProcessed
+	if reader.Repeats(student.Location, student.LocationRoomID) {
+		switch a.antiPassback(reader) {
+		case AntiPassbackIgnore:
+			return student.Location, DecisionIgnored, nil
+		case AntiPassbackToggle:
+			toggle := *reader
+			toggle.Direction = ReaderDirectionToggle
+			reader, decision = &toggle, DecisionToggled
+		}
+	}
+
+	location, roomID := reader.Resolve(student.Location, student.LocationRoomID)
+	event := LocationEvent{
+		Type:      EventLocationChange,
+		StudentID: student.ID,
+		Name:      user.FirstName + " " + user.SecondName,
+		GroupID:   student.GroupID,
+		Location:  string(location),
+		ReaderID:  reader.ReaderID,
+		Timestamp: at,
+	}
+
+	if reader.LocationType == ReaderLocationRoom {
+		var err error
+		event.RoomID = *reader.RoomID
+		if location == models.LocationRoom {
+			event.Type = EventRoomEntry
+			err = a.enterRoom(ctx, log, student, *roomID, reader.ReaderID, at)
+		} else {
+			event.Type = EventRoomExit
+			if student.Location != models.LocationRoom || !sameRoom(student.LocationRoomID, reader.RoomID) {
+				// the visit is closed, but the student stays where tracked
+				location, roomID = student.Location, student.LocationRoomID
+			}
+			err = a.exitRoom(ctx, log, student, *reader.RoomID, reader.ReaderID, at)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		event.StudentCount, _, _ = a.roomCounts(ctx, *reader.RoomID)
+	} else {
+		err := a.studentStore.TransitionStudentLocation(ctx, &models.LocationTransition{
+			StudentID:  student.ID,
+			ToLocation: location,
+			Source:     reader.ReaderID,
+			At:         at,
+		})
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	student.Location, student.LocationRoomID, student.LocationSince = location, roomID, at
+	a.events.Publish(event)
+	return location, decision, nil
+}
+
+// sameRoom reports whether both room IDs are set and equal
+func sameRoom(a, b *int64) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// handleListReaders returns all registered readers
+func (a *API) handleListReaders(w http.ResponseWriter, r *http.Request) {
+	readers, err := a.store.ListReaders(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, readers)
+}
+
+// handleCreateReader registers a reader with its location
+func (a *API) handleCreateReader(w http.ResponseWriter, r *http.Request) {
+	data := &ReaderRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := a.store.CreateReader(r.Context(), data.Reader); err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"reader_id":     data.ReaderID,
+		"location_type": data.LocationType,
+		"direction":     data.Direction,
+	}).Info("Reader registered")
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, data.Reader)
+}
+
+// handleGetReader returns a registered reader
+func (a *API) handleGetReader(w http.ResponseWriter, r *http.Request) {
+	reader, err := a.store.GetReader(r.Context(), chi.URLParam(r, "reader_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("reader not registered")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, reader)
+}
+
+// handleUpdateReader changes the location of a registered reader
+func (a *API) handleUpdateReader(w http.ResponseWriter, r *http.Request) {
+	data := &ReaderRequest{Reader: &Reader{ReaderID: chi.URLParam(r, "reader_id")}}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	data.ReaderID = chi.URLParam(r, "reader_id")
+
+	err := a.store.UpdateReader(r.Context(), data.Reader)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("reader not registered")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, data.Reader)
+}
+
+// handleDeleteReader removes a reader from the registry
+func (a *API) handleDeleteReader(w http.ResponseWriter, r *http.Request) {
+	err := a.store.DeleteReader(r.Context(), chi.URLParam(r, "reader_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("reader not registered")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
+// trackTagRead tracks the student owning the tag at the time of the read if the
+// reader is registered and returns the decision taken, empty if the student was
+// not tracked. Unknown and blocked tags are handled whatever the reader.
+// Rejected location changes are logged only, other failures are returned.
+func (a *API) trackTagRead(ctx context.Context, log logrus.FieldLogger, tagID, readerID string, readAt time.Time) (string, error) {
+	user, err := a.userStore.GetCustomUserByTagID(ctx, tagID)
+	if err != nil {
+		_, err := a.unresolvedTag(ctx, log, tagID, readerID, readAt, user, err)
+		return "", err
+	}
+
+	reader, err := a.lookupReader(ctx, readerID)
+	if err != nil {
+		log.WithError(err).Error("Failed to look up reader")
+		return "", err
+	}
+	if reader == nil {
+		return "", nil
+	}
+
+	student, err := a.studentStore.GetStudentByCustomUserID(ctx, user.ID)
+	if err != nil {
+		log.WithField("user_id", user.ID).Info("User found but no student record")
+		return "", nil
+	}
+
+	// Reads recorded before the current location can't change it anymore
+	if staleRead(student, readAt) {
+		log.WithField("student_id", student.ID).Info("Stale tag read")
+		return DecisionStale, nil
+	}
+
+	location, decision, err := a.trackReaderRead(ctx, log, reader, user, student, readAt)
+	if errors.Is(err, models.ErrInvalidTransition) {
+		log.WithError(err).WithField("student_id", student.ID).Warning("Rejected student location change")
+		return "", nil
+	}
+	if err != nil {
+		log.WithError(err).WithField("student_id", student.ID).Error("Failed to update student location")
+		return "", err
+	}
+
+	log.WithFields(logrus.Fields{
+		"tag_id":     tagID,
+		"reader_id":  readerID,
+		"student_id": student.ID,
+		"location":   location,
+		"decision":   decision,
+	}).Info("Student location tracked via registered reader")
+	return decision, nil
+}
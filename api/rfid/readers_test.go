@@ -0,0 +1,173 @@
+package rfid
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhax/go-base/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReaderResolve(t *testing.T) {
+	room, otherRoom := int64(3), int64(4)
+
+	tests := []struct {
+		name     string
+		reader   Reader
+		current  models.LocationState
+		roomID   *int64
+		location models.LocationState
+		toRoomID *int64
+	}{
+		{"building entry", Reader{LocationType: ReaderLocationBuilding, Direction: ReaderDirectionEntry}, models.LocationAbsent, nil, models.LocationInHouse, nil},
+		{"building exit", Reader{LocationType: ReaderLocationBuilding, Direction: ReaderDirectionExit}, models.LocationInHouse, nil, models.LocationAbsent, nil},
+		{"wc toggle in", Reader{LocationType: ReaderLocationWC, Direction: ReaderDirectionToggle}, models.LocationInHouse, nil, models.LocationWC, nil},
+		{"wc toggle out", Reader{LocationType: ReaderLocationWC, Direction: ReaderDirectionToggle}, models.LocationWC, nil, models.LocationInHouse, nil},
+		{"room entry", Reader{LocationType: ReaderLocationRoom, RoomID: &room, Direction: ReaderDirectionEntry}, models.LocationInHouse, nil, models.LocationRoom, &room},
+		{"room toggle out", Reader{LocationType: ReaderLocationRoom, RoomID: &room, Direction: ReaderDirectionToggle}, models.LocationRoom, &room, models.LocationInHouse, nil},
+		{"room toggle from other room", Reader{LocationType: ReaderLocationRoom, RoomID: &room, Direction: ReaderDirectionToggle}, models.LocationRoom, &otherRoom, models.LocationRoom, &room},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, roomID := tt.reader.Resolve(tt.current, tt.roomID)
+			assert.Equal(t, tt.location, location)
+			assert.Equal(t, tt.toRoomID, roomID)
+		})
+	}
+}
+
+func TestReaderValidate(t *testing.T) {
+	room := int64(3)
+
+	assert.NoError(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationRoom, RoomID: &room, Direction: ReaderDirectionToggle}).Validate())
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationRoom, Direction: ReaderDirectionEntry}).Validate())
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationWC, RoomID: &room, Direction: ReaderDirectionEntry}).Validate())
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: "garden", Direction: ReaderDirectionEntry}).Validate())
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationWC, Direction: "sideways"}).Validate())
+
+	debounce := -1
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationWC, Direction: ReaderDirectionEntry, DebounceSeconds: &debounce}).Validate())
+	assert.Error(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationWC, Direction: ReaderDirectionEntry, AntiPassback: "block"}).Validate())
+	assert.NoError(t, (&Reader{ReaderID: "R1", LocationType: ReaderLocationWC, Direction: ReaderDirectionEntry, AntiPassback: AntiPassbackToggle}).Validate())
+}
+
+func TestReaderRepeats(t *testing.T) {
+	room, otherRoom := int64(3), int64(4)
+
+	entry := Reader{LocationType: ReaderLocationBuilding, Direction: ReaderDirectionEntry}
+	assert.True(t, entry.Repeats(models.LocationInHouse, nil))
+	assert.True(t, entry.Repeats(models.LocationRoom, &otherRoom))
+	assert.False(t, entry.Repeats(models.LocationAbsent, nil))
+
+	roomExit := Reader{LocationType: ReaderLocationRoom, RoomID: &room, Direction: ReaderDirectionExit}
+	assert.False(t, roomExit.Repeats(models.LocationRoom, &room))
+	assert.True(t, roomExit.Repeats(models.LocationRoom, &otherRoom))
+	assert.True(t, roomExit.Repeats(models.LocationInHouse, nil))
+
+	toggle := Reader{LocationType: ReaderLocationWC, Direction: ReaderDirectionToggle}
+	assert.False(t, toggle.Repeats(models.LocationWC, nil))
+}
+
+func TestHandleStudentTrackingRegisteredReader(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	mockStudentStore := new(MockStudentStore)
+
+	api := &API{
+		store:        mockRFIDStore,
+		userStore:    mockUserStore,
+		studentStore: mockStudentStore,
+	}
+
+	tagID := "ABCDEF123456"
+	now := time.Now()
+
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, tagID, "WC-READER", "", mock.Anything).Return(&Tag{ID: 1, TagID: tagID, ReaderID: "WC-READER", ReadAt: now}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+	mockRFIDStore.On("GetReader", mock.Anything, "WC-READER").Return(&Reader{
+		ReaderID:     "WC-READER",
+		LocationType: ReaderLocationWC,
+		Direction:    ReaderDirectionToggle,
+	}, nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, tagID).Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil)
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+		ID:           24,
+		CustomUserID: 42,
+		Location:     models.LocationWC,
+	}, nil)
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 24 && t.ToLocation == models.LocationInHouse && t.Source == "WC-READER"
+	})).Return(nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"WC-READER"}`
+	req := httptest.NewRequest("POST", "/track-student", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleStudentTracking(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response StudentTrackingResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Success)
+	assert.Equal(t, string(models.LocationInHouse), response.Location)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockUserStore.AssertExpectations(t)
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestHandleRoomEntryRequiresRoomReader(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	mockRFIDStore.On("GetReader", mock.Anything, "DOOR-1").Return(nil, sql.ErrNoRows)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"DOOR-1"}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockRFIDStore.AssertExpectations(t)
+}
+
+func TestReaderRegistryChangesRequireAdmin(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	mockRFIDStore.On("CreateReader", mock.Anything, mock.AnythingOfType("*rfid.Reader")).Return(nil)
+
+	payload := `{"reader_id":"reader-room-3","location_type":"room","room_id":3}`
+	for _, method := range []string{"POST", "PUT", "DELETE"} {
+		target := "/readers"
+		if method != "POST" {
+			target += "/reader-room-3"
+		}
+		req := httptest.NewRequest(method, target, strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		api.Router().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code, method)
+	}
+
+	req := httptest.NewRequest("POST", "/readers", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	api.AdminRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockRFIDStore.AssertExpectations(t)
+}
@@ -0,0 +1,62 @@
+package rfid
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dhax/go-base/models"
+)
+
+// staleReadMessage explains why a stale read didn't change the student's location
+const staleReadMessage = "read is older than the current location of the student"
+
+// Default limits for read times supplied by readers
+const (
+	defaultMaxClockSkew = time.Minute
+	defaultMaxReadAge   = 7 * 24 * time.Hour
+)
+
+// checkReadTime validates the time a reader read a tag against the time the
+// server received it. Reads may lie up to the maximum clock skew in the future,
+// which is clamped to now, and up to the maximum read age in the past, leaving
+// readers time to deliver reads buffered while offline.
+func (a *API) checkReadTime(readAt, now time.Time) (time.Time, error) {
+	maxClockSkew, maxReadAge := a.maxClockSkew, a.maxReadAge
+	if maxClockSkew <= 0 {
+		maxClockSkew = defaultMaxClockSkew
+	}
+	if maxReadAge <= 0 {
+		maxReadAge = defaultMaxReadAge
+	}
+
+	switch {
+	case readAt.After(now.Add(maxClockSkew)):
+		return time.Time{}, fmt.Errorf("read_at %s is more than %s ahead of server time", readAt.Format(time.RFC3339), maxClockSkew)
+	case readAt.Before(now.Add(-maxReadAge)):
+		return time.Time{}, fmt.Errorf("read_at %s is older than %s", readAt.Format(time.RFC3339), maxReadAge)
+	case readAt.After(now):
+		return now, nil
+	}
+	return readAt, nil
+}
+
+// parseReadTime parses the optional RFC 3339 read time of a request and checks
+// it against the clock-skew limits. Without read time the tag was read now.
+func (a *API) parseReadTime(readAt string) (time.Time, error) {
+	now := time.Now()
+	if readAt == "" {
+		return now, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, readAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid read_at, expected RFC 3339: %s", readAt)
+	}
+	return a.checkReadTime(t, now)
+}
+
+// staleRead reports whether the read was recorded before the student's current
+// location, e.g. a read delivered late, which can't change the location anymore
+func staleRead(student *models.Student, at time.Time) bool {
+	return at.Before(student.LocationSince)
+}
@@ -0,0 +1,155 @@
+package rfid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhax/go-base/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckReadTime(t *testing.T) {
+	api := &API{maxClockSkew: time.Minute, maxReadAge: 24 * time.Hour}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		readAt time.Time
+		want   time.Time
+		err    bool
+	}{
+		{"now", now, now, false},
+		{"buffered read", now.Add(-3 * time.Hour), now.Add(-3 * time.Hour), false},
+		{"ahead within skew", now.Add(30 * time.Second), now, false},
+		{"ahead beyond skew", now.Add(2 * time.Minute), time.Time{}, true},
+		{"too old", now.Add(-25 * time.Hour), time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := api.checkReadTime(tt.readAt, now)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s, want %s", got, tt.want)
+		})
+	}
+}
+
+func TestHandleTagReadWithReadAt(t *testing.T) {
+	mockStore := new(MockRFIDStore)
+	api := &API{store: mockStore}
+
+	readAt := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	mockStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "READER001", "", mock.MatchedBy(func(at time.Time) bool {
+		return at.Equal(readAt)
+	})).Return(&Tag{ID: 1, TagID: "ABCDEF123456", ReaderID: "READER001", ReadAt: readAt}, nil)
+	mockStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"READER001","read_at":"` + readAt.Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest("POST", "/tag", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleTagRead(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandleTagReadInvalidReadAt(t *testing.T) {
+	mockStore := new(MockRFIDStore)
+	api := &API{store: mockStore}
+
+	for _, readAt := range []string{"yesterday", time.Now().Add(time.Hour).Format(time.RFC3339)} {
+		payload := `{"tag_id":"ABCDEF123456","reader_id":"READER001","read_at":"` + readAt + `"}`
+		req := httptest.NewRequest("POST", "/tag", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		api.handleTagRead(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, readAt)
+	}
+	mockStore.AssertNotCalled(t, "SaveTag")
+}
+
+func TestHandleRoomEntryStaleRead(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	mockStudentStore := new(MockStudentStore)
+	api := &API{store: mockRFIDStore, userStore: mockUserStore, studentStore: mockStudentStore}
+
+	// The student moved to another room after the buffered read
+	readAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	otherRoom := int64(6)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "ROOM_READER", "", mock.Anything).Return(&Tag{ID: 1, TagID: "ABCDEF123456", ReaderID: "ROOM_READER", ReadAt: readAt}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "ABCDEF123456").Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil)
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+		ID:             24,
+		Location:       models.LocationRoom,
+		LocationRoomID: &otherRoom,
+		LocationSince:  readAt.Add(30 * time.Minute),
+	}, nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"ROOM_READER","room_id":5,"read_at":"` + readAt.Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response OccupancyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, DecisionStale, response.Decision)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockRFIDStore.AssertNotCalled(t, "RecordRoomEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockStudentStore.AssertNotCalled(t, "TransitionStudentLocation", mock.Anything, mock.Anything)
+}
+
+func TestHandleStudentTrackingStaleRead(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	mockStudentStore := new(MockStudentStore)
+	api := &API{store: mockRFIDStore, userStore: mockUserStore, studentStore: mockStudentStore}
+
+	readAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "ABCDEF123456", "MAIN-DOOR", "", mock.Anything).Return(&Tag{ID: 1, TagID: "ABCDEF123456", ReaderID: "MAIN-DOOR", ReadAt: readAt}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "ABCDEF123456").Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil)
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+		ID:            24,
+		Location:      models.LocationPickedUp,
+		LocationSince: readAt.Add(time.Minute),
+	}, nil)
+
+	payload := `{"tag_id":"ABCDEF123456","reader_id":"MAIN-DOOR","location_type":"entry","read_at":"` + readAt.Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest("POST", "/track-student", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleStudentTracking(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response StudentTrackingResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, DecisionStale, response.Decision)
+	assert.Equal(t, string(models.LocationPickedUp), response.Location)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockStudentStore.AssertNotCalled(t, "TransitionStudentLocation", mock.Anything, mock.Anything)
+}
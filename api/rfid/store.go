@@ -3,24 +3,45 @@ package rfid
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/uptrace/bun"
+
+	"github.com/dhax/go-base/database"
+	"github.com/dhax/go-base/models"
 )
 
+// ErrDuplicateEvent is returned for reads with an event ID that was already processed
+var ErrDuplicateEvent = errors.New("duplicate event")
+
+// ErrDeviceNotPending is returned when reviewing a device that was reviewed already
+var ErrDeviceNotPending = errors.New("device is not pending approval")
+
+// ErrDeviceRevoked is returned when a revoked device would be reinstated without an admin
+var ErrDeviceRevoked = errors.New("device is revoked")
+
+// ErrCommandAcknowledged is returned when acknowledging a command that was acknowledged already
+var ErrCommandAcknowledged = errors.New("command was acknowledged already")
+
 // RFIDStore defines database operations for RFID tag management
 type RFIDStore interface {
-	SaveTag(ctx context.Context, tagID, readerID string) (*Tag, error)
-	GetAllTags(ctx context.Context) ([]Tag, error)
+	SaveTag(ctx context.Context, deviceID, tagID, readerID, eventID string, readAt time.Time) (*Tag, error)
+	ListTags(ctx context.Context, f *TagFilter) ([]Tag, error)
+	GetHourlyReadCounts(ctx context.Context, f *TagFilter) ([]HourlyReadCount, error)
 	GetTagStats(ctx context.Context) (int, error)
-	SaveTauriTags(ctx context.Context, deviceID string, tags []SyncTag) error
+	SaveTauriTags(ctx context.Context, deviceID string, tags []SyncTag) ([]int, error)
+	MarkTagsProcessed(ctx context.Context, tags []Tag) error
+	NearestTagRead(ctx context.Context, tagID, readerID string, at time.Time, within time.Duration) (time.Time, error)
 
 	// Room occupancy tracking operations
-	RecordRoomEntry(ctx context.Context, studentID, roomID int64) error
-	RecordRoomExit(ctx context.Context, studentID, roomID int64) error
+	RecordRoomEntry(ctx context.Context, studentID, roomID int64, at time.Time) error
+	RecordRoomExit(ctx context.Context, studentID, roomID int64, at time.Time) error
 	GetRoomOccupancy(ctx context.Context, roomID int64) (*RoomOccupancyData, error)
 	GetCurrentRooms(ctx context.Context) ([]RoomOccupancyData, error)
 
@@ -28,10 +49,39 @@ type RFIDStore interface {
 	RegisterDevice(ctx context.Context, deviceID, name, description string) (*TauriDevice, string, error)
 	GetDevice(ctx context.Context, deviceID string) (*TauriDevice, error)
 	GetDeviceByAPIKey(ctx context.Context, apiKey string) (*TauriDevice, error)
+	RotateDeviceKey(ctx context.Context, deviceID string, grace time.Duration, reinstate bool) (*TauriDevice, string, error)
+	RevokeDeviceKey(ctx context.Context, deviceID string) error
+	ReviewDevice(ctx context.Context, deviceID, status string, roomID *int64, reviewedBy int64) (*TauriDevice, error)
+	RecordHeartbeat(ctx context.Context, device *TauriDevice) error
+	MarkDevicesOffline(ctx context.Context, silentSince time.Time) ([]TauriDevice, error)
+	ListDevicesToAlert(ctx context.Context, downSince time.Time) ([]TauriDevice, error)
+	SetOfflineAlerted(ctx context.Context, deviceID string, at time.Time) error
+	SetReaderAlerted(ctx context.Context, deviceID string, at time.Time) error
+	LockHealthCheck(ctx context.Context) (unlock func(), ok bool, err error)
 	UpdateDevice(ctx context.Context, deviceID string, updates map[string]interface{}) error
 	ListDevices(ctx context.Context) ([]TauriDevice, error)
-	RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, tagsCount int) error
+	RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, configVersion, tagsCount int) error
 	GetDeviceSyncHistory(ctx context.Context, deviceID string, limit int) ([]DeviceSyncHistory, error)
+
+	// Remote configuration and command operations
+	GetDeviceConfig(ctx context.Context, deviceID string) (*DeviceConfig, error)
+	SaveDeviceConfig(ctx context.Context, cfg *DeviceConfig, roomID *int64) error
+	CreateDeviceCommand(ctx context.Context, cmd *DeviceCommand) error
+	ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]DeviceCommand, error)
+	PollDeviceCommands(ctx context.Context, deviceID string, at time.Time) ([]DeviceCommand, error)
+	AcknowledgeDeviceCommand(ctx context.Context, deviceID string, id int64, status, result string, at time.Time) (*DeviceCommand, error)
+
+	// Reader registry operations
+	CreateReader(ctx context.Context, reader *Reader) error
+	GetReader(ctx context.Context, readerID string) (*Reader, error)
+	ListReaders(ctx context.Context) ([]Reader, error)
+	UpdateReader(ctx context.Context, reader *Reader) error
+	DeleteReader(ctx context.Context, readerID string) error
+
+	// Unknown tag inbox operations
+	RecordUnknownTag(ctx context.Context, tagID, readerID string, readAt time.Time) error
+	ListUnknownTags(ctx context.Context) ([]UnknownTag, error)
+	DeleteUnknownTag(ctx context.Context, tagID string) error
 }
 
 type rfidStore struct {
@@ -43,34 +93,58 @@ func NewRFIDStore(db *bun.DB) RFIDStore {
 	return &rfidStore{db: db}
 }
 
-// SaveTag saves an RFID tag read to the database
-func (s *rfidStore) SaveTag(ctx context.Context, tagID, readerID string) (*Tag, error) {
+// SaveTag saves an RFID tag read at the given time to the database, the time it
+// is received is kept as creation time. Reads with an event ID are stored once
+// per reader, a retried read returns the stored tag and ErrDuplicateEvent once
+// the event is marked processed. Until then the stored tag is returned without
+// error, so a read whose processing failed is processed again.
+func (s *rfidStore) SaveTag(ctx context.Context, deviceID, tagID, readerID, eventID string, readAt time.Time) (*Tag, error) {
 	now := time.Now()
 	tag := &Tag{
+		DeviceID:  deviceID,
 		TagID:     tagID,
 		ReaderID:  readerID,
-		ReadAt:    now,
+		EventID:   eventID,
+		ReadAt:    readAt,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
-	_, err := s.db.NewInsert().
+	res, err := s.db.NewInsert().
 		Model(tag).
+		On("CONFLICT (reader_id, event_id) DO NOTHING").
 		Exec(ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		existing := new(Tag)
+		err := s.db.NewSelect().
+			Model(existing).
+			Where("reader_id = ?", readerID).
+			Where("event_id = ?", eventID).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if existing.ProcessedAt.IsZero() {
+			return existing, nil
+		}
+		return existing, ErrDuplicateEvent
+	}
+
 	return tag, nil
 }
 
-// GetAllTags returns all stored RFID tags
-func (s *rfidStore) GetAllTags(ctx context.Context) ([]Tag, error) {
+// ListTags returns a page of the tag reads matching the filter, latest first
+func (s *rfidStore) ListTags(ctx context.Context, f *TagFilter) ([]Tag, error) {
 	var tags []Tag
 	err := s.db.NewSelect().
 		Model(&tags).
-		Order("read_at DESC").
+		Apply(f.Apply).
+		Apply(f.ApplyPage).
 		Scan(ctx)
 
 	if err != nil {
@@ -80,6 +154,28 @@ func (s *rfidStore) GetAllTags(ctx context.Context) ([]Tag, error) {
 	return tags, nil
 }
 
+// GetHourlyReadCounts returns the number of reads matching the filter per reader
+// and hour
+func (s *rfidStore) GetHourlyReadCounts(ctx context.Context, f *TagFilter) ([]HourlyReadCount, error) {
+	var counts []HourlyReadCount
+	err := s.db.NewSelect().
+		Model((*Tag)(nil)).
+		ColumnExpr("tag.reader_id").
+		ColumnExpr("date_trunc('hour', tag.read_at) AS hour").
+		ColumnExpr("count(*) AS reads").
+		ColumnExpr("count(DISTINCT tag.tag_id) AS distinct_tags").
+		Apply(f.Apply).
+		GroupExpr("tag.reader_id, hour").
+		OrderExpr("tag.reader_id, hour").
+		Scan(ctx, &counts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
 // GetTagStats returns statistics about RFID tags
 func (s *rfidStore) GetTagStats(ctx context.Context) (int, error) {
 	count, err := s.db.NewSelect().
@@ -93,72 +189,211 @@ func (s *rfidStore) GetTagStats(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-// SaveTauriTags saves a batch of tags from the Tauri app
-func (s *rfidStore) SaveTauriTags(ctx context.Context, deviceID string, syncTags []SyncTag) error {
+// SaveTauriTags saves a batch of tags from the Tauri app. It returns the indices
+// of reads whose event was already processed, e.g. because the app retried the
+// sync. Stored events that are not processed yet are returned for processing again.
+func (s *rfidStore) SaveTauriTags(ctx context.Context, deviceID string, syncTags []SyncTag) ([]int, error) {
 	if len(syncTags) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	stored, err := s.storedEvents(ctx, syncTags)
+	if err != nil {
+		return nil, err
 	}
 
-	tags := make([]Tag, len(syncTags))
+	tags := make([]Tag, 0, len(syncTags))
+	duplicates := []int{}
 	now := time.Now()
 
 	for i, syncTag := range syncTags {
-		tags[i] = Tag{
+		if syncTag.EventID != "" {
+			key := [2]string{syncTag.ReaderID, syncTag.EventID}
+			if stored[key] {
+				duplicates = append(duplicates, i)
+				continue
+			}
+			stored[key] = true
+		}
+
+		tags = append(tags, Tag{
+			DeviceID:  deviceID,
 			TagID:     syncTag.TagID,
 			ReaderID:  syncTag.ReaderID,
+			EventID:   syncTag.EventID,
 			ReadAt:    syncTag.LocalReadAt,
 			CreatedAt: now,
 			UpdatedAt: now,
-		}
+		})
 	}
 
-	_, err := s.db.NewInsert().
+	if len(tags) == 0 {
+		return duplicates, nil
+	}
+
+	_, err = s.db.NewInsert().
 		Model(&tags).
+		On("CONFLICT (reader_id, event_id) DO NOTHING").
 		Exec(ctx)
 
-	return err
+	return duplicates, err
 }
 
-// RecordRoomEntry records a student entering a room
-func (s *rfidStore) RecordRoomEntry(ctx context.Context, studentID, roomID int64) error {
-	// This is a simplified implementation
-	// In a real application, you would:
-	// 1. Check if the student is already in a room
-	// 2. If yes, record an exit from the previous room
-	// 3. Record an entry to the new room
+// MarkTagsProcessed marks the stored reads of the tags as processed, identified
+// by tag, reader and read time. Further reads with their event IDs are rejected
+// as duplicates and only processed reads are taken into account for debouncing.
+func (s *rfidStore) MarkTagsProcessed(ctx context.Context, tags []Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
 
-	// For now, we'll just create a visit record in the database
-	// This assumes the database has a table for student visits with roomID, studentID, and entryTime fields
-	now := time.Now()
-	visit := struct {
-		RoomID    int64     `bun:"room_id"`
-		StudentID int64     `bun:"student_id"`
-		EntryTime time.Time `bun:"entry_time"`
-		ExitTime  time.Time `bun:"exit_time,nullzero"`
-		CreatedAt time.Time `bun:"created_at"`
-	}{
-		RoomID:    roomID,
-		StudentID: studentID,
-		EntryTime: now,
-		CreatedAt: now,
+	reads := make([][]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		reads = append(reads, []interface{}{tag.TagID, tag.ReaderID, tag.ReadAt})
 	}
 
-	_, err := s.db.NewInsert().
-		Model(&visit).
+	_, err := s.db.NewUpdate().
+		Model((*Tag)(nil)).
+		Set("processed_at = ?", time.Now()).
+		Where("(tag_id, reader_id, read_at) IN (?)", bun.In(reads)).
+		Where("processed_at IS NULL").
 		Exec(ctx)
 
 	return err
 }
 
-// RecordRoomExit records a student exiting a room
-func (s *rfidStore) RecordRoomExit(ctx context.Context, studentID, roomID int64) error {
+// NearestTagRead returns the read time of the processed read of the tag at the
+// reader closest to at, within the given duration before or after at. Reads at
+// any reader are considered if readerID is empty. It returns the zero time if
+// there is no such read.
+func (s *rfidStore) NearestTagRead(ctx context.Context, tagID, readerID string, at time.Time, within time.Duration) (time.Time, error) {
+	var readAt time.Time
+	q := s.db.NewSelect().
+		Model((*Tag)(nil)).
+		Column("read_at").
+		Where("tag_id = ?", tagID).
+		Where("read_at > ? AND read_at < ?", at.Add(-within), at.Add(within)).
+		Where("processed_at IS NOT NULL").
+		OrderExpr("abs(extract(epoch FROM read_at - ?::timestamptz))", at).
+		Limit(1)
+	if readerID != "" {
+		q = q.Where("reader_id = ?", readerID)
+	}
+
+	err := q.Scan(ctx, &readAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return readAt, err
+}
+
+// storedEvents returns the reader and event ID pairs of the sync tags already processed
+func (s *rfidStore) storedEvents(ctx context.Context, syncTags []SyncTag) (map[[2]string]bool, error) {
+	stored := make(map[[2]string]bool)
+
+	eventIDs := make([]string, 0, len(syncTags))
+	for _, syncTag := range syncTags {
+		if syncTag.EventID != "" {
+			eventIDs = append(eventIDs, syncTag.EventID)
+		}
+	}
+	if len(eventIDs) == 0 {
+		return stored, nil
+	}
+
+	var tags []Tag
+	err := s.db.NewSelect().
+		Model(&tags).
+		Column("reader_id", "event_id").
+		Where("event_id IN (?)", bun.In(eventIDs)).
+		Where("processed_at IS NOT NULL").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		stored[[2]string{tag.ReaderID, tag.EventID}] = true
+	}
+	return stored, nil
+}
+
+// RecordRoomEntry records a student entering a room. If the student still has
+// an open visit in another room, that visit and the timespan of its matching
+// visit record are closed at the time of entry in the same transaction before the
+// new entry is opened.
+func (s *rfidStore) RecordRoomEntry(ctx context.Context, studentID, roomID int64, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Close any room visit the student did not scan out of
+	_, err = tx.NewUpdate().
+		Model((*StudentRoomVisit)(nil)).
+		Set("exit_time = ?", at).
+		Where("student_id = ? AND room_id <> ? AND exit_time IS NULL", studentID, roomID).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// End the timespans of the visit records belonging to those rooms
+	openVisits := tx.NewSelect().
+		Model((*models.Visit)(nil)).
+		Column("timespan_id").
+		Where("student_id = ? AND room_id <> ?", studentID, roomID)
+
+	_, err = tx.NewUpdate().
+		Model((*models.Timespan)(nil)).
+		Set("endtime = ?", at).
+		Where("endtime IS NULL").
+		Where("id IN (?)", openVisits).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// A student who is already in this room keeps the existing visit
+	exists, err := tx.NewSelect().
+		Model((*StudentRoomVisit)(nil)).
+		Where("student_id = ? AND room_id = ? AND exit_time IS NULL", studentID, roomID).
+		Exists(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		visit := &StudentRoomVisit{
+			RoomID:    roomID,
+			StudentID: studentID,
+			EntryTime: at,
+			CreatedAt: time.Now(),
+		}
+
+		_, err = tx.NewInsert().
+			Model(visit).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordRoomExit records a student exiting a room at the given time
+func (s *rfidStore) RecordRoomExit(ctx context.Context, studentID, roomID int64, at time.Time) error {
 	// Find the current visit for this student and room where exit time is null
 	// and update the exit time
-	now := time.Now()
-
 	_, err := s.db.NewUpdate().
-		Table("student_room_visits").
-		Set("exit_time = ?", now).
+		Model((*StudentRoomVisit)(nil)).
+		Set("exit_time = ?", at).
 		Where("student_id = ? AND room_id = ? AND exit_time IS NULL", studentID, roomID).
 		Exec(ctx)
 
@@ -167,15 +402,22 @@ func (s *rfidStore) RecordRoomExit(ctx context.Context, studentID, roomID int64)
 
 // GetRoomOccupancy gets the current occupancy for a specific room
 func (s *rfidStore) GetRoomOccupancy(ctx context.Context, roomID int64) (*RoomOccupancyData, error) {
-	// This is a simplified implementation
-	// In a real application, you would join with the rooms and students tables
-	// to get all the details needed
+	// Load the room itself for name and capacity
+	room := new(models.Room)
+	err := s.db.NewSelect().
+		Model(room).
+		Column("id", "room_name", "capacity").
+		Where("id = ?", roomID).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
 
-	// For now, we'll return a mocked response
 	result := &RoomOccupancyData{
-		RoomID:       roomID,
-		RoomName:     "Room " + fmt.Sprintf("%d", roomID), // You would get this from the DB
-		Capacity:     30,                                  // You would get this from the DB
+		RoomID:       room.ID,
+		RoomName:     room.RoomName,
+		Capacity:     room.Capacity,
 		StudentCount: 0,
 		Students:     []RoomOccupancyStudent{},
 	}
@@ -183,21 +425,24 @@ func (s *rfidStore) GetRoomOccupancy(ctx context.Context, roomID int64) (*RoomOc
 	// Query the students currently in the room (entry_time IS NOT NULL AND exit_time IS NULL)
 	type queryResult struct {
 		StudentID int64     `bun:"student_id"`
-		Name      string    `bun:"name"` // This assumes you have a way to join to get the name
+		Name      string    `bun:"name"`
 		EntryTime time.Time `bun:"entry_time"`
 	}
 
 	var results []queryResult
 
-	// This is a placeholder query - in a real app, you would join with student/user tables
-	err := s.db.NewSelect().
-		TableExpr("student_room_visits v").
-		ColumnExpr("v.student_id, 'Student Name' AS name, v.entry_time"). // Placeholder name
+	err = s.db.NewSelect().
+		TableExpr("student_room_visits AS v").
+		ColumnExpr("v.student_id, v.entry_time").
+		ColumnExpr("cu.first_name || ' ' || cu.second_name AS name").
+		Join("JOIN students AS st ON st.id = v.student_id").
+		Join("JOIN custom_users AS cu ON cu.id = st.custom_user_id").
 		Where("v.room_id = ? AND v.exit_time IS NULL", roomID).
+		OrderExpr("v.entry_time ASC").
 		Scan(ctx, &results)
 
 	if err != nil {
-		return result, err
+		return nil, err
 	}
 
 	// Convert the results to RoomOccupancyStudent objects
@@ -212,10 +457,19 @@ func (s *rfidStore) GetRoomOccupancy(ctx context.Context, roomID int64) (*RoomOc
 
 	result.Students = students
 	result.StudentCount = len(students)
+	result.FreeSeats = freeSeats(result.Capacity, result.StudentCount)
 
 	return result, nil
 }
 
+// freeSeats returns the number of remaining seats, never going below zero
+func freeSeats(capacity, studentCount int) int {
+	if studentCount >= capacity {
+		return 0
+	}
+	return capacity - studentCount
+}
+
 // GetCurrentRooms gets all rooms with their current occupancy
 func (s *rfidStore) GetCurrentRooms(ctx context.Context) ([]RoomOccupancyData, error) {
 	// This is a simplified implementation
@@ -236,13 +490,13 @@ func (s *rfidStore) GetCurrentRooms(ctx context.Context) ([]RoomOccupancyData, e
 	}
 
 	// Get occupancy for each room
-	result := make([]RoomOccupancyData, len(roomIDs))
-	for i, roomID := range roomIDs {
+	result := make([]RoomOccupancyData, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
 		occupancy, err := s.GetRoomOccupancy(ctx, roomID)
 		if err != nil {
 			continue // Skip rooms with errors
 		}
-		result[i] = *occupancy
+		result = append(result, *occupancy)
 	}
 
 	return result, nil
@@ -258,7 +512,7 @@ func generateAPIKey() (string, error) {
 	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "="), nil
 }
 
-// RegisterDevice creates a new Tauri device registration
+// RegisterDevice creates a new Tauri device registration, pending until approved
 func (s *rfidStore) RegisterDevice(ctx context.Context, deviceID, name, description string) (*TauriDevice, string, error) {
 	// Check if device already exists
 	var existingDevice TauriDevice
@@ -277,16 +531,17 @@ func (s *rfidStore) RegisterDevice(ctx context.Context, deviceID, name, descript
 		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
 	}
 
-	// Create the new device
+	// Create the new device, the API key is stored hashed only
 	now := time.Now()
 	device := &TauriDevice{
-		DeviceID:    deviceID,
-		Name:        name,
-		Description: description,
-		Status:      "active",
-		APIKey:      apiKey,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		DeviceID:     deviceID,
+		Name:         name,
+		Description:  description,
+		Status:       DeviceStatusPending,
+		APIKeyPrefix: apiKeyPrefix(apiKey),
+		APIKeyHash:   hashAPIKey(apiKey),
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 
 	_, err = s.db.NewInsert().
@@ -315,14 +570,145 @@ func (s *rfidStore) GetDevice(ctx context.Context, deviceID string) (*TauriDevic
 	return device, nil
 }
 
-// GetDeviceByAPIKey retrieves a device by its API key
+// GetDeviceByAPIKey retrieves a device by its API key, or by its previous key
+// within the grace period after a rotation. Revoked devices are returned too,
+// it returns sql.ErrNoRows if no device matches.
 func (s *rfidStore) GetDeviceByAPIKey(ctx context.Context, apiKey string) (*TauriDevice, error) {
-	device := new(TauriDevice)
+	now := time.Now()
+	prefix := apiKeyPrefix(apiKey)
+
+	var devices []TauriDevice
 	err := s.db.NewSelect().
+		Model(&devices).
+		Where("api_key_prefix = ?", prefix).
+		WhereOr("previous_key_prefix = ? AND previous_key_expires_at > ?", prefix, now).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range devices {
+		if devices[i].MatchesKey(apiKey, now) {
+			return &devices[i], nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// RotateDeviceKey replaces the API key of a device and returns the new key. The
+// previous key remains valid for the grace period, unless the device was revoked.
+// A revoked device is reinstated with the new key if reinstate is set, otherwise
+// ErrDeviceRevoked is returned.
+func (s *rfidStore) RotateDeviceKey(ctx context.Context, deviceID string, grace time.Duration, reinstate bool) (*TauriDevice, string, error) {
+	apiKey, err := generateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	device := new(TauriDevice)
+	err = tx.NewSelect().
 		Model(device).
-		Where("api_key = ?", apiKey).
+		Where("device_id = ?", deviceID).
+		For("UPDATE").
 		Scan(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if device.RevokedAt != nil && !reinstate {
+		return nil, "", ErrDeviceRevoked
+	}
 
+	now := time.Now()
+	device.PreviousKeyPrefix, device.PreviousKeyHash, device.PreviousKeyExpiresAt = "", "", nil
+	if device.RevokedAt == nil && grace > 0 {
+		expiresAt := now.Add(grace)
+		device.PreviousKeyPrefix = device.APIKeyPrefix
+		device.PreviousKeyHash = device.APIKeyHash
+		device.PreviousKeyExpiresAt = &expiresAt
+	}
+	device.APIKeyPrefix = apiKeyPrefix(apiKey)
+	device.APIKeyHash = hashAPIKey(apiKey)
+	device.KeyRotatedAt = &now
+	device.RevokedAt = nil
+	device.UpdatedAt = now
+
+	_, err = tx.NewUpdate().
+		Model(device).
+		Column("api_key_prefix", "api_key_hash", "previous_key_prefix", "previous_key_hash",
+			"previous_key_expires_at", "key_rotated_at", "revoked_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return device, apiKey, nil
+}
+
+// RevokeDeviceKey revokes the API keys of a device, the current and the
+// previous one. It returns sql.ErrNoRows if the device doesn't exist.
+func (s *rfidStore) RevokeDeviceKey(ctx context.Context, deviceID string) error {
+	now := time.Now()
+	res, err := s.db.NewUpdate().
+		Model((*TauriDevice)(nil)).
+		Set("revoked_at = ?", now).
+		Set("previous_key_prefix = NULL").
+		Set("previous_key_hash = NULL").
+		Set("previous_key_expires_at = NULL").
+		Set("updated_at = ?", now).
+		Where("device_id = ?", deviceID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ReviewDevice approves or rejects a pending device registration, an approved
+// device may be bound to a room. It returns ErrDeviceNotPending for devices
+// reviewed already and sql.ErrNoRows if the device doesn't exist.
+func (s *rfidStore) ReviewDevice(ctx context.Context, deviceID, status string, roomID *int64, reviewedBy int64) (*TauriDevice, error) {
+	now := time.Now()
+	device := new(TauriDevice)
+	err := s.db.NewUpdate().
+		Model(device).
+		Set("status = ?", status).
+		Set("room_id = ?", roomID).
+		Set("reviewed_at = ?", now).
+		Set("reviewed_by = ?", reviewedBy).
+		Set("updated_at = ?", now).
+		Where("device_id = ?", deviceID).
+		Where("status = ?", DeviceStatusPending).
+		Returning("*").
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetDevice(ctx, deviceID); err != nil {
+			return nil, err
+		}
+		return nil, ErrDeviceNotPending
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -330,6 +716,109 @@ func (s *rfidStore) GetDeviceByAPIKey(ctx context.Context, apiKey string) (*Taur
 	return device, nil
 }
 
+// RecordHeartbeat stores the status reported in a heartbeat of the device and
+// marks it online. An alert about a reader down is kept until all readers are
+// ok again.
+func (s *rfidStore) RecordHeartbeat(ctx context.Context, device *TauriDevice) error {
+	device.Online = true
+	device.OfflineSince = nil
+	device.OfflineAlertedAt = nil
+	device.UpdatedAt = time.Now()
+
+	columns := []string{"last_heartbeat_at", "app_version", "buffered_reads", "clock_offset_ms", "reader_health",
+		"online", "offline_since", "offline_alerted_at", "updated_at"}
+	if !readerDown(device.ReaderHealth) {
+		device.ReaderAlertedAt = nil
+		columns = append(columns, "reader_alerted_at")
+	}
+
+	_, err := s.db.NewUpdate().
+		Model(device).
+		Column(columns...).
+		Where("device_id = ?", device.DeviceID).
+		Exec(ctx)
+
+	return err
+}
+
+// MarkDevicesOffline marks active devices offline that sent no heartbeat since
+// the given time and returns them. Devices that never sent a heartbeat are not
+// monitored.
+func (s *rfidStore) MarkDevicesOffline(ctx context.Context, silentSince time.Time) ([]TauriDevice, error) {
+	var devices []TauriDevice
+	err := s.db.NewUpdate().
+		Model(&devices).
+		Set("online = FALSE").
+		Set("offline_since = last_heartbeat_at").
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", DeviceStatusActive).
+		Where("online").
+		Where("last_heartbeat_at < ?", silentSince).
+		Returning("*").
+		Scan(ctx)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// ListDevicesToAlert returns the active devices bound to a room that weren't
+// alerted about yet and are offline, or have a reader down, since before the
+// given time
+func (s *rfidStore) ListDevicesToAlert(ctx context.Context, downSince time.Time) ([]TauriDevice, error) {
+	var devices []TauriDevice
+	err := s.db.NewSelect().
+		Model(&devices).
+		Where("status = ?", DeviceStatusActive).
+		Where("room_id IS NOT NULL").
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("NOT online AND offline_since < ? AND offline_alerted_at IS NULL", downSince).
+				WhereOr(`online AND reader_alerted_at IS NULL AND EXISTS (
+					SELECT 1 FROM jsonb_array_elements(reader_health) AS rh
+					WHERE rh->>'status' <> ? AND (rh->>'down_since')::timestamptz < ?)`, ReaderHealthOK, downSince)
+		}).
+		Order("device_id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// SetOfflineAlerted records the time an offline alert was sent for the device
+func (s *rfidStore) SetOfflineAlerted(ctx context.Context, deviceID string, at time.Time) error {
+	_, err := s.db.NewUpdate().
+		Model((*TauriDevice)(nil)).
+		Set("offline_alerted_at = ?", at).
+		Where("device_id = ?", deviceID).
+		Exec(ctx)
+
+	return err
+}
+
+// SetReaderAlerted records the time an alert about a reader down was sent for
+// the device
+func (s *rfidStore) SetReaderAlerted(ctx context.Context, deviceID string, at time.Time) error {
+	_, err := s.db.NewUpdate().
+		Model((*TauriDevice)(nil)).
+		Set("reader_alerted_at = ?", at).
+		Where("device_id = ?", deviceID).
+		Exec(ctx)
+
+	return err
+}
+
+// LockHealthCheck takes the lock of the device health monitor, so devices are
+// checked and alerted about by one instance at a time
+func (s *rfidStore) LockHealthCheck(ctx context.Context) (unlock func(), ok bool, err error) {
+	return database.TryLock(ctx, s.db, "rfid_health_check")
+}
+
 // UpdateDevice updates a device's information
 func (s *rfidStore) UpdateDevice(ctx context.Context, deviceID string, updates map[string]interface{}) error {
 	updates["updated_at"] = time.Now()
@@ -376,7 +865,7 @@ func (s *rfidStore) ListDevices(ctx context.Context) ([]TauriDevice, error) {
 }
 
 // RecordDeviceSync logs a sync from a device
-func (s *rfidStore) RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, tagsCount int) error {
+func (s *rfidStore) RecordDeviceSync(ctx context.Context, deviceID, ipAddress, appVersion string, configVersion, tagsCount int) error {
 	now := time.Now()
 
 	// Update the device's last sync info
@@ -392,12 +881,13 @@ func (s *rfidStore) RecordDeviceSync(ctx context.Context, deviceID, ipAddress, a
 
 	// Record the sync history
 	syncHistory := &DeviceSyncHistory{
-		DeviceID:   deviceID,
-		SyncAt:     now,
-		IPAddress:  ipAddress,
-		TagsCount:  tagsCount,
-		AppVersion: appVersion,
-		CreatedAt:  now,
+		DeviceID:      deviceID,
+		SyncAt:        now,
+		IPAddress:     ipAddress,
+		TagsCount:     tagsCount,
+		AppVersion:    appVersion,
+		ConfigVersion: configVersion,
+		CreatedAt:     now,
 	}
 
 	_, err := s.db.NewInsert().
@@ -427,3 +917,314 @@ func (s *rfidStore) GetDeviceSyncHistory(ctx context.Context, deviceID string, l
 
 	return history, nil
 }
+
+// GetDeviceConfig returns the configuration of a device, or sql.ErrNoRows if
+// none was set
+func (s *rfidStore) GetDeviceConfig(ctx context.Context, deviceID string) (*DeviceConfig, error) {
+	cfg := new(DeviceConfig)
+	err := s.db.NewSelect().
+		Model(cfg).
+		Where("device_id = ?", deviceID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SaveDeviceConfig sets the configuration and the room of a device. The version
+// of an existing configuration is incremented, the stored configuration is read
+// back into cfg.
+func (s *rfidStore) SaveDeviceConfig(ctx context.Context, cfg *DeviceConfig, roomID *int64) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.NewUpdate().
+		Model((*TauriDevice)(nil)).
+		Set("room_id = ?", roomID).
+		Set("updated_at = ?", now).
+		Where("device_id = ?", cfg.DeviceID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	cfg.Version = 1
+	cfg.CreatedAt = now
+	cfg.UpdatedAt = now
+	err = tx.NewInsert().
+		Model(cfg).
+		On("CONFLICT (device_id) DO UPDATE").
+		Set("reader_mode = EXCLUDED.reader_mode").
+		Set("debounce_seconds = EXCLUDED.debounce_seconds").
+		Set("sync_interval_seconds = EXCLUDED.sync_interval_seconds").
+		Set("min_app_version = EXCLUDED.min_app_version").
+		Set("version = dc.version + 1").
+		Set("updated_by = EXCLUDED.updated_by").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateDeviceCommand queues a command for a device
+func (s *rfidStore) CreateDeviceCommand(ctx context.Context, cmd *DeviceCommand) error {
+	now := time.Now()
+	cmd.Status = CommandStatusPending
+	cmd.CreatedAt = now
+	cmd.UpdatedAt = now
+
+	_, err := s.db.NewInsert().
+		Model(cmd).
+		Exec(ctx)
+	return err
+}
+
+// ListDeviceCommands returns the latest commands of a device, newest first
+func (s *rfidStore) ListDeviceCommands(ctx context.Context, deviceID string, limit int) ([]DeviceCommand, error) {
+	if limit <= 0 {
+		limit = 50 // Default limit
+	}
+
+	var commands []DeviceCommand
+	err := s.db.NewSelect().
+		Model(&commands).
+		Where("device_id = ?", deviceID).
+		Order("id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+// PollDeviceCommands returns the commands of a device not acknowledged yet, in
+// the order they were queued, and marks them delivered
+func (s *rfidStore) PollDeviceCommands(ctx context.Context, deviceID string, at time.Time) ([]DeviceCommand, error) {
+	var commands []DeviceCommand
+	err := s.db.NewUpdate().
+		Model(&commands).
+		Set("status = ?", CommandStatusDelivered).
+		Set("delivered_at = COALESCE(delivered_at, ?)", at).
+		Set("updated_at = ?", at).
+		Where("device_id = ?", deviceID).
+		Where("status IN (?)", bun.In([]string{CommandStatusPending, CommandStatusDelivered})).
+		Returning("*").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].ID < commands[j].ID })
+	return commands, nil
+}
+
+// AcknowledgeDeviceCommand sets the final status of a command of the device. It
+// returns ErrCommandAcknowledged for commands acknowledged already and
+// sql.ErrNoRows if the device has no such command.
+func (s *rfidStore) AcknowledgeDeviceCommand(ctx context.Context, deviceID string, id int64, status, result string, at time.Time) (*DeviceCommand, error) {
+	cmd := new(DeviceCommand)
+	err := s.db.NewUpdate().
+		Model(cmd).
+		Set("status = ?", status).
+		Set("result = NULLIF(?, '')", result).
+		Set("acknowledged_at = ?", at).
+		Set("updated_at = ?", at).
+		Where("id = ?", id).
+		Where("device_id = ?", deviceID).
+		Where("status IN (?)", bun.In([]string{CommandStatusPending, CommandStatusDelivered})).
+		Returning("*").
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		exists, err := s.db.NewSelect().
+			Model((*DeviceCommand)(nil)).
+			Where("id = ?", id).
+			Where("device_id = ?", deviceID).
+			Exists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, sql.ErrNoRows
+		}
+		return nil, ErrCommandAcknowledged
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// CreateReader registers a reader with its location
+func (s *rfidStore) CreateReader(ctx context.Context, reader *Reader) error {
+	now := time.Now()
+	reader.CreatedAt = now
+	reader.UpdatedAt = now
+
+	_, err := s.db.NewInsert().
+		Model(reader).
+		Exec(ctx)
+
+	return err
+}
+
+// GetReader retrieves a reader by its reader ID
+func (s *rfidStore) GetReader(ctx context.Context, readerID string) (*Reader, error) {
+	reader := new(Reader)
+	err := s.db.NewSelect().
+		Model(reader).
+		Where("reader_id = ?", readerID).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// ListReaders returns all registered readers
+func (s *rfidStore) ListReaders(ctx context.Context) ([]Reader, error) {
+	var readers []Reader
+	err := s.db.NewSelect().
+		Model(&readers).
+		Order("reader_id ASC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return readers, nil
+}
+
+// UpdateReader updates the location of a reader
+func (s *rfidStore) UpdateReader(ctx context.Context, reader *Reader) error {
+	reader.UpdatedAt = time.Now()
+
+	res, err := s.db.NewUpdate().
+		Model(reader).
+		Column("name", "location_type", "room_id", "direction", "debounce_seconds", "anti_passback", "updated_at").
+		Where("reader_id = ?", reader.ReaderID).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteReader removes a reader from the registry
+func (s *rfidStore) DeleteReader(ctx context.Context, readerID string) error {
+	res, err := s.db.NewDelete().
+		Model((*Reader)(nil)).
+		Where("reader_id = ?", readerID).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RecordUnknownTag adds a read of a tag not assigned to any user to the inbox,
+// counting the reads and keeping the reader of the latest one
+func (s *rfidStore) RecordUnknownTag(ctx context.Context, tagID, readerID string, readAt time.Time) error {
+	now := time.Now()
+	tag := &UnknownTag{
+		TagID:       tagID,
+		ReaderID:    readerID,
+		FirstSeenAt: readAt,
+		LastSeenAt:  readAt,
+		ReadCount:   1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	_, err := s.db.NewInsert().
+		Model(tag).
+		On("CONFLICT (tag_id) DO UPDATE").
+		Set("reader_id = CASE WHEN EXCLUDED.last_seen_at >= ut.last_seen_at THEN EXCLUDED.reader_id ELSE ut.reader_id END").
+		Set("first_seen_at = LEAST(ut.first_seen_at, EXCLUDED.first_seen_at)").
+		Set("last_seen_at = GREATEST(ut.last_seen_at, EXCLUDED.last_seen_at)").
+		Set("read_count = ut.read_count + 1").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+
+	return err
+}
+
+// ListUnknownTags returns the unknown tags, most recently read first. Tags
+// assigned to a user in the meantime are left out.
+func (s *rfidStore) ListUnknownTags(ctx context.Context) ([]UnknownTag, error) {
+	var tags []UnknownTag
+	err := s.db.NewSelect().
+		Model(&tags).
+		Where("NOT EXISTS (SELECT 1 FROM user_tags t WHERE t.tag_id = ut.tag_id AND t.unassigned_at IS NULL)").
+		Order("last_seen_at DESC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// DeleteUnknownTag removes a tag from the inbox
+func (s *rfidStore) DeleteUnknownTag(ctx context.Context, tagID string) error {
+	res, err := s.db.NewDelete().
+		Model((*UnknownTag)(nil)).
+		Where("tag_id = ?", tagID).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
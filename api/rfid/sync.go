@@ -0,0 +1,234 @@
+package rfid
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/models"
+)
+
+// Result status of a single read of a sync batch
+const (
+	SyncStatusAccepted   = "accepted"    // the read was applied to the student's location
+	SyncStatusDuplicate  = "duplicate"   // repeated read of the same tag at the same reader or retried event
+	SyncStatusStale      = "stale"       // the read is older than the student's current location
+	SyncStatusRejected   = "rejected"    // the location change is not allowed or the read time is out of range
+	SyncStatusIgnored    = "ignored"     // anti-passback ignored a repeated entry or exit
+	SyncStatusUnknownTag = "unknown_tag" // no student is assigned to the tag
+	SyncStatusError      = "error"       // processing failed, the read may be sent again
+)
+
+// SyncTagResult is the outcome of processing a single read of a sync batch
+type SyncTagResult struct {
+	Index       int       `json:"index"` // position of the read in the request data
+	TagID       string    `json:"tag_id"`
+	ReaderID    string    `json:"reader_id"`
+	EventID     string    `json:"event_id,omitempty"`
+	LocalReadAt time.Time `json:"local_read_at"`
+	Status      string    `json:"status"`
+	StudentID   int64     `json:"student_id,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	Message     string    `json:"message,omitempty"`
+}
+
+// syncStudent is a student seen in a sync batch, with its location kept up to
+// date while the batch is replayed. The student is nil if the tag doesn't
+// resolve to one, err is set if it doesn't resolve to a user.
+type syncStudent struct {
+	user    *models.CustomUser
+	student *models.Student
+	err     error
+}
+
+// replaySync processes the reads of a sync batch in the order they were read,
+// through the same location logic as the live endpoints. Reads at the indices
+// given as stored duplicates were received before and are skipped. It returns a
+// result for every read, in the order of the request, and the number of accepted reads.
+func (a *API) replaySync(ctx context.Context, log logrus.FieldLogger, deviceID string, tags []SyncTag, duplicates []int) ([]SyncTagResult, int) {
+	results := make([]SyncTagResult, len(tags))
+	order := make([]int, len(tags))
+	for i, tag := range tags {
+		order[i] = i
+		results[i] = SyncTagResult{
+			Index:       i,
+			TagID:       tag.TagID,
+			ReaderID:    tag.ReaderID,
+			EventID:     tag.EventID,
+			LocalReadAt: tag.LocalReadAt,
+		}
+	}
+	for _, i := range duplicates {
+		results[i].Status = SyncStatusDuplicate
+		results[i].Message = "event already received"
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return tags[order[i]].LocalReadAt.Before(tags[order[j]].LocalReadAt)
+	})
+
+	students := make(map[string]*syncStudent)
+	readers := make(map[string]*Reader)
+	lastReads := make(map[[2]string]time.Time)
+	accepted := 0
+	now := time.Now()
+
+	for _, i := range order {
+		tag := tags[i]
+		result := &results[i]
+		if result.Status == SyncStatusDuplicate {
+			continue
+		}
+		at := tag.LocalReadAt
+		if at.IsZero() {
+			at = now
+		}
+		at, err := a.checkReadTime(at, now)
+		if err != nil {
+			result.Status = SyncStatusRejected
+			result.Message = err.Error()
+			continue
+		}
+
+		reader, cached := readers[tag.ReaderID]
+		if !cached {
+			var err error
+			reader, err = a.lookupReader(ctx, tag.ReaderID)
+			if err != nil {
+				log.WithError(err).WithField("reader_id", tag.ReaderID).Error("Failed to look up reader")
+			}
+			readers[tag.ReaderID] = reader
+		}
+
+		// Repeated reads of a tag at the same reader within its debounce window are processed once
+		key := [2]string{tag.TagID, tag.ReaderID}
+		if last, ok := lastReads[key]; ok && within(at, last, a.debounceWindow(reader)) {
+			result.Status = SyncStatusDuplicate
+			continue
+		}
+		lastReads[key] = at
+
+		s, ok := students[tag.TagID]
+		if !ok {
+			s = a.syncStudent(ctx, log, tag.TagID)
+			students[tag.TagID] = s
+		}
+		if s.student == nil {
+			result.Status = SyncStatusUnknownTag
+			if s.err != nil {
+				message, err := a.unresolvedTag(ctx, log, tag.TagID, tag.ReaderID, at, s.user, s.err)
+				if err != nil {
+					result.Status = SyncStatusError
+					result.Message = "failed to look up the tag owner"
+					continue
+				}
+				result.Message = message
+			}
+			continue
+		}
+		result.StudentID = s.student.ID
+
+		// Reads recorded before the current location can't change it anymore
+		if staleRead(s.student, at) {
+			result.Status = SyncStatusStale
+			result.Location = string(s.student.Location)
+			result.Message = staleReadMessage
+			continue
+		}
+
+		location, decision, err := a.replaySyncRead(ctx, log, deviceID, reader, tag, s, at)
+		switch {
+		case errors.Is(err, models.ErrInvalidTransition):
+			result.Status = SyncStatusRejected
+			result.Location = string(s.student.Location)
+			result.Message = err.Error()
+		case err != nil:
+			log.WithError(err).WithField("student_id", s.student.ID).Error("Failed to update student location")
+			result.Status = SyncStatusError
+			result.Message = "failed to update student location"
+		case decision == DecisionIgnored:
+			result.Status = SyncStatusIgnored
+			result.Location = string(location)
+		default:
+			result.Status = SyncStatusAccepted
+			result.Location = string(location)
+			accepted++
+		}
+	}
+
+	return results, accepted
+}
+
+// processedReads returns the stored reads of a sync batch that were processed,
+// all of them if the batch was not replayed. Failed reads are processed again
+// when sent again, duplicates were either processed before or debounced.
+func processedReads(tags []SyncTag, results []SyncTagResult) []*Tag {
+	var reads []*Tag
+	for i, tag := range tags {
+		if results != nil && (results[i].Status == SyncStatusError || results[i].Status == SyncStatusDuplicate) {
+			continue
+		}
+		reads = append(reads, &Tag{TagID: tag.TagID, ReaderID: tag.ReaderID, EventID: tag.EventID, ReadAt: tag.LocalReadAt})
+	}
+	return reads
+}
+
+// syncStudent returns the student assigned to the tag, without student if there is none
+func (a *API) syncStudent(ctx context.Context, log logrus.FieldLogger, tagID string) *syncStudent {
+	user, err := a.userStore.GetCustomUserByTagID(ctx, tagID)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"tag_id": tagID,
+			"error":  err.Error(),
+		}).Debug("No user found for tag")
+		return &syncStudent{user: user, err: err}
+	}
+
+	student, err := a.studentStore.GetStudentByCustomUserID(ctx, user.ID)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		}).Debug("User found but no student record")
+		return &syncStudent{user: user}
+	}
+
+	return &syncStudent{user: user, student: student}
+}
+
+// replaySyncRead applies a single read of a sync batch. Registered readers
+// define the location of the read, unregistered Tauri readers are placed inside
+// the building and mark the student in-house unless already tracked at a more
+// specific location inside. It returns the location and the decision taken.
+func (a *API) replaySyncRead(ctx context.Context, log logrus.FieldLogger, deviceID string, reader *Reader, tag SyncTag, s *syncStudent, at time.Time) (models.LocationState, string, error) {
+	if reader != nil {
+		return a.trackReaderRead(ctx, log, reader, s.user, s.student, at)
+	}
+
+	student := s.student
+	if !student.Location.InHouse() {
+		err := a.studentStore.TransitionStudentLocation(ctx, &models.LocationTransition{
+			StudentID:  student.ID,
+			ToLocation: models.LocationInHouse,
+			Source:     deviceID,
+			At:         at,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		student.Location, student.LocationRoomID, student.LocationSince = models.LocationInHouse, nil, at
+	}
+
+	a.events.Publish(LocationEvent{
+		Type:      EventLocationChange,
+		StudentID: student.ID,
+		Name:      s.user.FirstName + " " + s.user.SecondName,
+		GroupID:   student.GroupID,
+		Location:  string(student.Location),
+		ReaderID:  tag.ReaderID,
+		Timestamp: at,
+	})
+	return student.Location, DecisionProcessed, nil
+}
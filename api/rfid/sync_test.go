@@ -0,0 +1,186 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dhax/go-base/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReplaySync(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	mockStudentStore := new(MockStudentStore)
+
+	api := &API{
+		store:           mockRFIDStore,
+		userStore:       mockUserStore,
+		studentStore:    mockStudentStore,
+		debounceDefault: 5 * time.Second,
+	}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	mockRFIDStore.On("GetReader", mock.Anything, "WC-1").Return(&Reader{
+		ReaderID:     "WC-1",
+		LocationType: ReaderLocationWC,
+		Direction:    ReaderDirectionToggle,
+	}, nil).Once()
+	mockRFIDStore.On("GetReader", mock.Anything, "PICKUP").Return(&Reader{
+		ReaderID:     "PICKUP",
+		LocationType: ReaderLocationPickup,
+		Direction:    ReaderDirectionEntry,
+	}, nil).Once()
+	mockRFIDStore.On("GetReader", mock.Anything, "DESK").Return(nil, sql.ErrNoRows).Once()
+
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "TAG-A").Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, nil).Once()
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "TAG-B").Return(&models.CustomUser{ID: 43, FirstName: "Jane", SecondName: "Doe"}, nil).Once()
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "TAG-X").Return(nil, sql.ErrNoRows).Once()
+	mockRFIDStore.On("RecordUnknownTag", mock.Anything, "TAG-X", "DESK", start.Add(3*time.Second)).Return(nil).Once()
+
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(42)).Return(&models.Student{
+		ID:       24,
+		Location: models.LocationAbsent,
+	}, nil).Once()
+	mockStudentStore.On("GetStudentByCustomUserID", mock.Anything, int64(43)).Return(&models.Student{
+		ID:            25,
+		Location:      models.LocationInHouse,
+		LocationSince: start.Add(time.Hour),
+	}, nil).Once()
+
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 24 && t.ToLocation == models.LocationInHouse && t.Source == "tauri-1" && t.At.Equal(start)
+	})).Return(nil).Once()
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 24 && t.ToLocation == models.LocationWC && t.At.Equal(start.Add(time.Minute))
+	})).Return(nil).Once()
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 24 && t.ToLocation == models.LocationPickedUp
+	})).Return(fmt.Errorf("%w: wc to picked_up", models.ErrInvalidTransition)).Once()
+
+	// Reads are sent out of order, the replay sorts them by read time
+	tags := []SyncTag{
+		{TagID: "TAG-A", ReaderID: "WC-1", LocalReadAt: start.Add(time.Minute)},
+		{TagID: "TAG-A", ReaderID: "DESK", LocalReadAt: start},
+		{TagID: "TAG-A", ReaderID: "DESK", LocalReadAt: start.Add(2 * time.Second)},
+		{TagID: "TAG-X", ReaderID: "DESK", LocalReadAt: start.Add(3 * time.Second)},
+		{TagID: "TAG-B", ReaderID: "DESK", LocalReadAt: start.Add(4 * time.Second)},
+		{TagID: "TAG-A", ReaderID: "PICKUP", LocalReadAt: start.Add(2 * time.Minute)},
+		{TagID: "TAG-A", ReaderID: "DESK", LocalReadAt: start.Add(-30 * 24 * time.Hour)},
+	}
+
+	results, accepted := api.replaySync(context.Background(), logrus.StandardLogger(), "tauri-1", tags, nil)
+
+	assert.Equal(t, 2, accepted)
+	assert.Len(t, results, len(tags))
+
+	statuses := make([]string, len(results))
+	for i, r := range results {
+		assert.Equal(t, i, r.Index)
+		statuses[i] = r.Status
+	}
+	assert.Equal(t, []string{
+		SyncStatusAccepted,
+		SyncStatusAccepted,
+		SyncStatusDuplicate,
+		SyncStatusUnknownTag,
+		SyncStatusStale,
+		SyncStatusRejected,
+		SyncStatusRejected,
+	}, statuses)
+	assert.Equal(t, string(models.LocationWC), results[0].Location)
+	assert.Equal(t, string(models.LocationInHouse), results[1].Location)
+	assert.Equal(t, string(models.LocationWC), results[5].Location)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockUserStore.AssertExpectations(t)
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestReplaySyncReaderDebounceWindow(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+
+	api := &API{
+		store:           mockRFIDStore,
+		userStore:       mockUserStore,
+		studentStore:    new(MockStudentStore),
+		debounceDefault: 5 * time.Second,
+	}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	debounce := 1
+	mockRFIDStore.On("GetReader", mock.Anything, "DOOR").Return(&Reader{
+		ReaderID:        "DOOR",
+		LocationType:    ReaderLocationBuilding,
+		Direction:       ReaderDirectionToggle,
+		DebounceSeconds: &debounce,
+	}, nil).Once()
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "TAG-X").Return(nil, sql.ErrNoRows).Once()
+	mockRFIDStore.On("RecordUnknownTag", mock.Anything, "TAG-X", "DOOR", mock.Anything).Return(nil).Times(2)
+
+	// The reads are further apart than the reader's window, but within the default
+	tags := []SyncTag{
+		{TagID: "TAG-X", ReaderID: "DOOR", LocalReadAt: start},
+		{TagID: "TAG-X", ReaderID: "DOOR", LocalReadAt: start.Add(2 * time.Second)},
+		{TagID: "TAG-X", ReaderID: "DOOR", LocalReadAt: start.Add(2500 * time.Millisecond)},
+	}
+
+	results, _ := api.replaySync(context.Background(), logrus.StandardLogger(), "tauri-1", tags, nil)
+
+	assert.Equal(t, SyncStatusUnknownTag, results[0].Status)
+	assert.Equal(t, SyncStatusUnknownTag, results[1].Status)
+	assert.Equal(t, SyncStatusDuplicate, results[2].Status)
+
+	mockRFIDStore.AssertExpectations(t)
+	mockUserStore.AssertExpectations(t)
+}
+
+func TestReplaySyncSkipsStoredEvents(t *testing.T) {
+	api := &API{
+		store:        new(MockRFIDStore),
+		userStore:    new(MockUserStore),
+		studentStore: new(MockStudentStore),
+	}
+
+	tags := []SyncTag{
+		{TagID: "TAG-A", ReaderID: "DESK", EventID: "evt-1", LocalReadAt: time.Now()},
+	}
+
+	results, accepted := api.replaySync(context.Background(), logrus.StandardLogger(), "tauri-1", tags, []int{0})
+
+	assert.Equal(t, 0, accepted)
+	assert.Equal(t, SyncStatusDuplicate, results[0].Status)
+	assert.Equal(t, "evt-1", results[0].EventID)
+}
+
+func TestProcessedReads(t *testing.T) {
+	readAt := time.Now()
+	tags := []SyncTag{
+		{TagID: "TAG-A", ReaderID: "DESK", EventID: "evt-1", LocalReadAt: readAt},
+		{TagID: "TAG-B", ReaderID: "DESK", EventID: "evt-2", LocalReadAt: readAt},
+		{TagID: "TAG-A", ReaderID: "DESK", LocalReadAt: readAt},
+		{TagID: "TAG-C", ReaderID: "DESK", LocalReadAt: readAt},
+	}
+	results := []SyncTagResult{
+		{Index: 0, Status: SyncStatusAccepted},
+		{Index: 1, Status: SyncStatusError},
+		{Index: 2, Status: SyncStatusDuplicate},
+		{Index: 3, Status: SyncStatusUnknownTag},
+	}
+
+	// Failed reads are replayed again when the app retries the sync, debounced reads are not processed
+	assert.Equal(t, []*Tag{
+		{TagID: "TAG-A", ReaderID: "DESK", EventID: "evt-1", ReadAt: readAt},
+		{TagID: "TAG-C", ReaderID: "DESK", ReadAt: readAt},
+	}, processedReads(tags, results))
+
+	// Without replay every read is processed once stored
+	assert.Len(t, processedReads(tags, nil), 4)
+}
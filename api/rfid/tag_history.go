@@ -0,0 +1,201 @@
+package rfid
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+)
+
+// Page sizes of the tag read history.
+const (
+	defaultTagPageSize = 100
+	maxTagPageSize     = 1000
+)
+
+// hourlyReadCountsPeriod is the period of the hourly read counts if no start is given
+const hourlyReadCountsPeriod = 24 * time.Hour
+
+// errInvalidCursor is returned for cursors not issued by the tag read history
+var errInvalidCursor = errors.New("invalid cursor")
+
+// TagFilter provides cursor pagination and filtering options on tag reads.
+type TagFilter struct {
+	TagID      string
+	ReaderID   string
+	DeviceID   string
+	From       *time.Time // inclusive
+	To         *time.Time // exclusive
+	Unassigned bool       // only tags not assigned to a user
+	Limit      int
+	Cursor     *TagCursor // the page starts after this read
+}
+
+// TagCursor is the position of a read in the history, latest first.
+type TagCursor struct {
+	ReadAt time.Time
+	ID     int64
+}
+
+// NewTagFilter returns a TagFilter with options parsed from request url values.
+func NewTagFilter(v url.Values) (*TagFilter, error) {
+	f := &TagFilter{
+		TagID:    v.Get("tag_id"),
+		ReaderID: v.Get("reader_id"),
+		DeviceID: v.Get("device_id"),
+		Limit:    defaultTagPageSize,
+	}
+
+	for name, t := range map[string]**time.Time{"from": &f.From, "to": &f.To} {
+		if s := v.Get(name); s != "" {
+			at, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*t = &at
+		}
+	}
+
+	if s := v.Get("unassigned"); s != "" {
+		unassigned, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unassigned: %s", s)
+		}
+		f.Unassigned = unassigned
+	}
+
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", s)
+		}
+		f.Limit = min(limit, maxTagPageSize)
+	}
+
+	if s := v.Get("cursor"); s != "" {
+		cursor, err := decodeTagCursor(s)
+		if err != nil {
+			return nil, err
+		}
+		f.Cursor = cursor
+	}
+
+	return f, nil
+}
+
+// Apply applies the filters of a TagFilter on a bun.SelectQuery.
+func (f *TagFilter) Apply(q *bun.SelectQuery) *bun.SelectQuery {
+	if f.TagID != "" {
+		q = q.Where("tag.tag_id = ?", f.TagID)
+	}
+	if f.ReaderID != "" {
+		q = q.Where("tag.reader_id = ?", f.ReaderID)
+	}
+	if f.DeviceID != "" {
+		q = q.Where("tag.device_id = ?", f.DeviceID)
+	}
+	if f.From != nil {
+		q = q.Where("tag.read_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("tag.read_at < ?", *f.To)
+	}
+	if f.Unassigned {
+		q = q.Where("NOT EXISTS (SELECT 1 FROM user_tags AS ut WHERE ut.tag_id = tag.tag_id AND ut.unassigned_at IS NULL)")
+	}
+	return q
+}
+
+// ApplyPage applies the cursor and limit of a TagFilter on a bun.SelectQuery. One
+// read more than the limit is selected to tell if there is a next page.
+func (f *TagFilter) ApplyPage(q *bun.SelectQuery) *bun.SelectQuery {
+	if f.Cursor != nil {
+		q = q.Where("(tag.read_at, tag.id) < (?, ?)", f.Cursor.ReadAt, f.Cursor.ID)
+	}
+	return q.OrderExpr("tag.read_at DESC, tag.id DESC").Limit(f.Limit + 1)
+}
+
+// encodeTagCursor returns the cursor of the page after the tag read
+func encodeTagCursor(tag *Tag) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%d,%d", tag.ReadAt.UnixMicro(), tag.ID)))
+}
+
+// decodeTagCursor parses a cursor returned by encodeTagCursor
+func decodeTagCursor(s string) (*TagCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	readAt, id, ok := strings.Cut(string(b), ",")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	micros, err := strconv.ParseInt(readAt, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	cursor := &TagCursor{ReadAt: time.UnixMicro(micros).UTC()}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// handleListTags returns a page of the tag reads, latest first
+func (a *API) handleListTags(w http.ResponseWriter, r *http.Request) {
+	f, err := NewTagFilter(r.URL.Query())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	tags, err := a.store.ListTags(r.Context(), f)
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	response := &TagListResponse{Tags: tags}
+	if len(tags) > f.Limit {
+		response.Tags = tags[:f.Limit]
+		response.NextCursor = encodeTagCursor(&response.Tags[f.Limit-1])
+	}
+	if response.Tags == nil {
+		response.Tags = []Tag{}
+	}
+
+	render.JSON(w, r, response)
+}
+
+// handleHourlyReadCounts returns the number of reads per reader and hour, by
+// default of the last 24 hours. Readers with unusual counts may have faulty hardware.
+func (a *API) handleHourlyReadCounts(w http.ResponseWriter, r *http.Request) {
+	f, err := NewTagFilter(r.URL.Query())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if f.From == nil {
+		from := time.Now().Add(-hourlyReadCountsPeriod)
+		f.From = &from
+	}
+
+	counts, err := a.store.GetHourlyReadCounts(r.Context(), f)
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+	if counts == nil {
+		counts = []HourlyReadCount{}
+	}
+
+	render.JSON(w, r, counts)
+}
@@ -0,0 +1,122 @@
+package rfid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewTagFilter(t *testing.T) {
+	f, err := NewTagFilter(url.Values{
+		"tag_id":     {"ABC123"},
+		"reader_id":  {"ROOM-101"},
+		"device_id":  {"dev-1"},
+		"from":       {"2023-10-15T08:00:00Z"},
+		"to":         {"2023-10-15T16:00:00Z"},
+		"unassigned": {"true"},
+		"limit":      {"5000"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ABC123", f.TagID)
+	assert.Equal(t, "ROOM-101", f.ReaderID)
+	assert.Equal(t, "dev-1", f.DeviceID)
+	assert.Equal(t, time.Date(2023, 10, 15, 8, 0, 0, 0, time.UTC), *f.From)
+	assert.Equal(t, time.Date(2023, 10, 15, 16, 0, 0, 0, time.UTC), *f.To)
+	assert.True(t, f.Unassigned)
+	assert.Equal(t, maxTagPageSize, f.Limit)
+
+	f, err = NewTagFilter(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTagPageSize, f.Limit)
+	assert.Nil(t, f.Cursor)
+
+	for _, v := range []url.Values{
+		{"from": {"yesterday"}},
+		{"limit": {"0"}},
+		{"unassigned": {"maybe"}},
+		{"cursor": {"not-a-cursor"}},
+	} {
+		_, err := NewTagFilter(v)
+		assert.Error(t, err, v.Encode())
+	}
+}
+
+func TestTagCursor(t *testing.T) {
+	tag := &Tag{ID: 42, ReadAt: time.Date(2023, 10, 15, 14, 35, 0, 123456000, time.UTC)}
+
+	cursor, err := decodeTagCursor(encodeTagCursor(tag))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), cursor.ID)
+	assert.True(t, tag.ReadAt.Equal(cursor.ReadAt))
+}
+
+func TestHandleListTags(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	mockRFIDStore.On("ListTags", mock.Anything, mock.MatchedBy(func(f *TagFilter) bool {
+		return f.ReaderID == "ROOM-101" && f.Limit == 2 && f.Cursor == nil
+	})).Return([]Tag{
+		{ID: 3, TagID: "A", ReaderID: "ROOM-101", ReadAt: now},
+		{ID: 2, TagID: "B", ReaderID: "ROOM-101", ReadAt: now.Add(-time.Minute)},
+		{ID: 1, TagID: "C", ReaderID: "ROOM-101", ReadAt: now.Add(-2 * time.Minute)},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/tags?reader_id=ROOM-101&limit=2", nil)
+	w := httptest.NewRecorder()
+	api.handleListTags(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page TagListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Tags, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// The next page starts after the last read of the page
+	cursor, err := decodeTagCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), cursor.ID)
+	assert.True(t, now.Add(-time.Minute).Equal(cursor.ReadAt))
+
+	mockRFIDStore.On("ListTags", mock.Anything, mock.MatchedBy(func(f *TagFilter) bool {
+		return f.Cursor != nil
+	})).Return([]Tag{{ID: 1, TagID: "C", ReaderID: "ROOM-101", ReadAt: now.Add(-2 * time.Minute)}}, nil)
+
+	req = httptest.NewRequest("GET", "/tags?reader_id=ROOM-101&limit=2&cursor="+page.NextCursor, nil)
+	w = httptest.NewRecorder()
+	api.handleListTags(w, req)
+
+	page = TagListResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Tags, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestHandleHourlyReadCounts(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	api := &API{store: mockRFIDStore}
+
+	mockRFIDStore.On("GetHourlyReadCounts", mock.Anything, mock.MatchedBy(func(f *TagFilter) bool {
+		return f.From != nil && time.Since(*f.From) >= hourlyReadCountsPeriod
+	})).Return([]HourlyReadCount{
+		{ReaderID: "ROOM-101", Hour: time.Now().Truncate(time.Hour), Reads: 840, DistinctTags: 1},
+	}, nil)
+
+	req := httptest.NewRequest("GET", "/tags/hourly", nil)
+	w := httptest.NewRecorder()
+	api.handleHourlyReadCounts(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var counts []HourlyReadCount
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &counts))
+	assert.Len(t, counts, 1)
+	assert.Equal(t, 840, counts[0].Reads)
+	mockRFIDStore.AssertExpectations(t)
+}
@@ -0,0 +1,150 @@
+package rfid
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/sirupsen/logrus"
+
+	"github.com/dhax/go-base/logging"
+	"github.com/dhax/go-base/models"
+)
+
+// recordUnknownTag collects a read of a tag not assigned to any user in the
+// inbox. Failures are logged only.
+func (a *API) recordUnknownTag(ctx context.Context, log logrus.FieldLogger, tagID, readerID string, readAt time.Time) {
+	if err := a.store.RecordUnknownTag(ctx, tagID, readerID, readAt); err != nil {
+		log.WithError(err).WithField("tag_id", tagID).Error("Failed to record unknown tag")
+	}
+}
+
+// unresolvedTag handles a read of a tag that doesn't identify a user and returns
+// the message for the reader. Reads of lost or blocked tags raise an alert on the
+// event bus, tags not assigned to any user are collected in the inbox. Other
+// errors of the user lookup are logged and returned.
+func (a *API) unresolvedTag(ctx context.Context, log logrus.FieldLogger, tagID, readerID string, readAt time.Time, user *models.CustomUser, err error) (string, error) {
+	log = log.WithFields(logrus.Fields{
+		"tag_id":    tagID,
+		"reader_id": readerID,
+	})
+
+	switch {
+	case errors.Is(err, models.ErrTagBlocked):
+		log.WithField("user_id", user.ID).Warning("Blocked tag scanned")
+		if a.events != nil {
+			a.events.Publish(LocationEvent{
+				Type:      EventTagBlocked,
+				Name:      user.FirstName + " " + user.SecondName,
+				ReaderID:  readerID,
+				TagID:     tagID,
+				Timestamp: readAt,
+			})
+		}
+		return "Tag is blocked", nil
+	case errors.Is(err, models.ErrTagNotValid):
+		log.WithField("user_id", user.ID).Info("Tag scanned outside its validity period")
+		return "Tag is not valid at this time", nil
+	case !errors.Is(err, sql.ErrNoRows):
+		log.WithError(err).Error("Failed to look up tag owner")
+		return "", err
+	}
+
+	log.Info("Tag scanned but no user found")
+	a.recordUnknownTag(ctx, log, tagID, readerID, readAt)
+	return "No user found with this tag ID", nil
+}
+
+// handleListUnknownTags returns the tags read but not assigned to any user
+func (a *API) handleListUnknownTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.store.ListUnknownTags(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, tags)
+}
+
+// handleAssignTag assigns an unknown tag to a user and removes it from the inbox
+func (a *API) handleAssignTag(w http.ResponseWriter, r *http.Request) {
+	tagID := chi.URLParam(r, "tag_id")
+	data := &AssignTagRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if a.userStore == nil {
+		render.Render(w, r, ErrInternalServer(fmt.Errorf("user store not configured")))
+		return
+	}
+
+	ctx := r.Context()
+	// Lost or blocked tags remain assigned to their user
+	owner, err := a.userStore.GetCustomUserByTagID(ctx, tagID)
+	if errors.Is(err, models.ErrTagBlocked) || errors.Is(err, models.ErrTagNotValid) {
+		err = nil
+	}
+	if err == nil && owner.ID != data.UserID {
+		render.Render(w, r, ErrConflict(fmt.Errorf("tag is already assigned to another user")))
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	user, err := a.userStore.GetCustomUserByID(ctx, data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("user not found")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	if err := a.userStore.UpdateTagID(ctx, user.ID, tagID); err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	log := logging.GetLogEntry(r)
+	// Tags can be assigned without having been read before
+	if err := a.store.DeleteUnknownTag(ctx, tagID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.WithError(err).WithField("tag_id", tagID).Warning("Failed to remove assigned tag from inbox")
+	}
+
+	log.WithFields(logrus.Fields{
+		"tag_id":  tagID,
+		"user_id": user.ID,
+	}).Info("Tag assigned to user")
+
+	render.JSON(w, r, &AssignTagResponse{
+		Success: true,
+		TagID:   tagID,
+		UserID:  user.ID,
+		Name:    user.FirstName + " " + user.SecondName,
+	})
+}
+
+// handleDeleteUnknownTag dismisses a tag from the inbox, e.g. a foreign card
+func (a *API) handleDeleteUnknownTag(w http.ResponseWriter, r *http.Request) {
+	err := a.store.DeleteUnknownTag(r.Context(), chi.URLParam(r, "tag_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound(fmt.Errorf("unknown tag not found")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.NoContent(w, r)
+}
@@ -0,0 +1,155 @@
+package rfid
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/dhax/go-base/models"
+)
+
+func TestHandleAssignTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		owner  *models.CustomUser
+		user   *models.CustomUser
+		status int
+	}{
+		{"unassigned tag", nil, &models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, http.StatusOK},
+		{"tag of another user", &models.CustomUser{ID: 7}, nil, http.StatusConflict},
+		{"unknown user", nil, nil, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRFIDStore := new(MockRFIDStore)
+			mockUserStore := new(MockUserStore)
+			api := &API{store: mockRFIDStore, userStore: mockUserStore}
+
+			if tt.owner != nil {
+				mockUserStore.On("GetCustomUserByTagID", mock.Anything, "NEWCARD01").Return(tt.owner, nil)
+			} else {
+				mockUserStore.On("GetCustomUserByTagID", mock.Anything, "NEWCARD01").Return(nil, sql.ErrNoRows)
+				if tt.user != nil {
+					mockUserStore.On("GetCustomUserByID", mock.Anything, int64(42)).Return(tt.user, nil)
+					mockUserStore.On("UpdateTagID", mock.Anything, int64(42), "NEWCARD01").Return(nil)
+					mockRFIDStore.On("DeleteUnknownTag", mock.Anything, "NEWCARD01").Return(nil)
+				} else {
+					mockUserStore.On("GetCustomUserByID", mock.Anything, int64(42)).Return(nil, sql.ErrNoRows)
+				}
+			}
+
+			router := chi.NewRouter()
+			router.Post("/unknown-tags/{tag_id}/assign", api.handleAssignTag)
+
+			req := httptest.NewRequest("POST", "/unknown-tags/NEWCARD01/assign", strings.NewReader(`{"user_id":42}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				var response AssignTagResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.True(t, response.Success)
+				assert.Equal(t, "John Doe", response.Name)
+			}
+
+			mockRFIDStore.AssertExpectations(t)
+			mockUserStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleAssignTagRequiresUser(t *testing.T) {
+	api := &API{store: new(MockRFIDStore), userStore: new(MockUserStore)}
+
+	router := chi.NewRouter()
+	router.Post("/unknown-tags/{tag_id}/assign", api.handleAssignTag)
+
+	req := httptest.NewRequest("POST", "/unknown-tags/NEWCARD01/assign", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestHandleRoomEntryBlockedTag(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	api := &API{store: mockRFIDStore, userStore: mockUserStore, events: NewEventBus()}
+
+	events, unsubscribe := api.events.Subscribe(EventFilter{})
+	defer unsubscribe()
+
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "LOSTCARD01", "ROOM_READER", "", mock.Anything).Return(&Tag{ID: 1}, nil)
+	mockRFIDStore.On("MarkTagsProcessed", mock.Anything, mock.Anything).Return(nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "LOSTCARD01").
+		Return(&models.CustomUser{ID: 42, FirstName: "John", SecondName: "Doe"}, models.ErrTagBlocked)
+
+	payload := `{"tag_id":"LOSTCARD01","reader_id":"ROOM_READER","room_id":5}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	var response OccupancyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	assert.Equal(t, "Tag is blocked", response.Message)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, EventTagBlocked, event.Type)
+		assert.Equal(t, "LOSTCARD01", event.TagID)
+		assert.Equal(t, "John Doe", event.Name)
+	default:
+		t.Fatal("expected a tag_blocked event")
+	}
+
+	mockRFIDStore.AssertExpectations(t)
+	mockUserStore.AssertExpectations(t)
+	mockRFIDStore.AssertNotCalled(t, "RecordUnknownTag", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleRoomEntryTagLookupFailure(t *testing.T) {
+	mockRFIDStore := new(MockRFIDStore)
+	mockUserStore := new(MockUserStore)
+	api := &API{store: mockRFIDStore, userStore: mockUserStore}
+
+	mockRFIDStore.On("SaveTag", mock.Anything, mock.Anything, "CARD01", "ROOM_READER", "", mock.Anything).Return(&Tag{ID: 1}, nil)
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "CARD01").Return(nil, errors.New("connection refused"))
+
+	payload := `{"tag_id":"CARD01","reader_id":"ROOM_READER","room_id":5}`
+	req := httptest.NewRequest("POST", "/room-entry", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	api.handleRoomEntry(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRFIDStore.AssertNotCalled(t, "RecordUnknownTag", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnknownTagsRequireAdmin(t *testing.T) {
+	api := &API{store: new(MockRFIDStore), userStore: new(MockUserStore)}
+
+	req := httptest.NewRequest("POST", "/unknown-tags/NEWCARD01/assign", strings.NewReader(`{"user_id":42}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	api.Router().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/dhax/go-base/auth/authorize"
 	"github.com/dhax/go-base/models"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -30,28 +31,33 @@ func NewAPI(db *bun.DB) (*API, error) {
 func (a *API) Router() *chi.Mux {
 	r := chi.NewRouter()
 
+	canRead := authorize.RequiresPermission(authorize.PermRead)
+	canWrite := authorize.RequiresPermission(authorize.PermWrite)
+	canManage := authorize.RequiresPermission(authorize.PermManage)
+
 	// Room endpoints
-	r.Get("/", a.handleGetRooms)
-	r.Post("/", a.handleCreateRoom)
-	r.Get("/grouped_by_category", a.handleGetRoomsGroupedByCategory)
-	r.Get("/choose", a.handleGetRoomsForSelection)
-	r.Get("/{id}", a.handleGetRoomByID)
-	r.Put("/{id}", a.handleUpdateRoom)
-	r.Delete("/{id}", a.handleDeleteRoom)
-	r.Get("/{id}/current_occupancy", a.handleGetCurrentRoomOccupancy)
-	r.Post("/{id}/register_tablet", a.handleRegisterTablet)
-	r.Post("/{id}/unregister_tablet", a.handleUnregisterTablet)
-	r.Get("/{id}/combined_group", a.handleGetCombinedGroupForRoom)
+	r.With(canRead).Get("/", a.handleGetRooms)
+	r.With(canManage).Post("/", a.handleCreateRoom)
+	r.With(canRead).Get("/grouped_by_category", a.handleGetRoomsGroupedByCategory)
+	r.With(canRead).Get("/choose", a.handleGetRoomsForSelection)
+	r.With(canRead).Get("/{id}", a.handleGetRoomByID)
+	r.With(canManage).Put("/{id}", a.handleUpdateRoom)
+	r.With(canManage).Delete("/{id}", a.handleDeleteRoom)
+	r.With(canRead).Get("/{id}/current_occupancy", a.handleGetCurrentRoomOccupancy)
+	r.With(canWrite).Post("/{id}/register_tablet", a.handleRegisterTablet)
+	r.With(canWrite).Post("/{id}/unregister_tablet", a.handleUnregisterTablet)
+	r.With(canRead).Get("/{id}/combined_group", a.handleGetCombinedGroupForRoom)
 
 	// Combined groups endpoints
 	r.Route("/combined_groups", func(r chi.Router) {
-		r.Get("/", a.handleGetActiveCombinedGroups)
-		r.Post("/merge", a.handleMergeRooms)
-		r.Delete("/{id}", a.handleDeactivateCombinedGroup)
+		r.With(canRead).Get("/", a.handleGetActiveCombinedGroups)
+		r.With(canWrite).Post("/merge", a.handleMergeRooms)
+		r.With(canWrite).Delete("/{id}", a.handleDeactivateCombinedGroup)
 	})
 
 	// Room occupancy endpoints
 	r.Route("/occupancies", func(r chi.Router) {
+		r.Use(canRead)
 		r.Get("/", a.handleGetAllRoomOccupancies)
 		r.Get("/{id}", a.handleGetRoomOccupancyByID)
 	})
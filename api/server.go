@@ -19,7 +19,7 @@ type Server struct {
 // NewServer creates and configures an APIServer serving all application routes.
 func NewServer() (*Server, error) {
 	log.Println("configuring server...")
-	api, err := New(viper.GetBool("enable_cors"))
+	api, start, err := New(viper.GetBool("enable_cors"))
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,9 @@ func NewServer() (*Server, error) {
 		Addr:    addr,
 		Handler: api,
 	}
+	for _, f := range start() {
+		srv.RegisterOnShutdown(f)
+	}
 
 	return &Server{&srv}, nil
 }
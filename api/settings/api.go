@@ -10,6 +10,7 @@ import (
 	"github.com/go-chi/render"
 	"github.com/sirupsen/logrus"
 
+	"github.com/dhax/go-base/auth/authorize"
 	"github.com/dhax/go-base/database"
 	"github.com/dhax/go-base/logging"
 	"github.com/dhax/go-base/models"
@@ -34,6 +35,7 @@ func (rs *Resource) Router() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Group(func(r chi.Router) {
+		r.Use(authorize.RequiresPermission(authorize.PermRead))
 		r.Get("/", rs.List)
 		r.Get("/category/{category}", rs.GetByCategory)
 		r.Get("/{id}", rs.Get)
@@ -41,6 +43,7 @@ func (rs *Resource) Router() *chi.Mux {
 
 		// Admin-only routes
 		r.Group(func(r chi.Router) {
+			r.Use(authorize.RequiresPermission(authorize.PermManage))
 			r.Post("/", rs.Create)
 			r.Put("/{id}", rs.Update)
 			r.Patch("/{key}", rs.UpdateByKey)
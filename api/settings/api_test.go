@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/dhax/go-base/auth/jwt"
 	"github.com/dhax/go-base/logging"
 	"github.com/dhax/go-base/models"
 )
@@ -94,17 +95,16 @@ func setupTestRouter(store *MockSettingsStore) chi.Router {
 	return setupTestResource(r, store, nil, logger)
 }
 
-// contextKey is a custom type for context keys
-type contextKey string
-
-// Claims is a simplified version of JWT claims for testing
-type Claims struct {
-	Role string
+// withRoles is a test middleware that sets JWT claims with the given roles
+func withRoles(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := jwt.ContextWithClaims(r.Context(), jwt.AppClaims{ID: 1, Roles: roles})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
-// ContextKey is the context key for JWT claims
-const ContextKey = contextKey("jwt")
-
 // setupTestResource sets up a test router with the settings resource and custom middleware
 func setupTestResource(r chi.Router, store *MockSettingsStore, _ interface{}, logger *logrus.Logger) chi.Router {
 	// Create mock auth
@@ -114,12 +114,7 @@ func setupTestResource(r chi.Router, store *MockSettingsStore, _ interface{}, lo
 	resource := NewResource(store, mockAuth)
 
 	// Add test auth middleware that sets admin role
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), ContextKey, Claims{Role: "admin"})
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	})
+	r.Use(withRoles("admin"))
 
 	// Set up router with resource routes - mount at /settings for tests
 	r.Mount("/settings", resource.Router())
@@ -355,3 +350,26 @@ func TestSettingsResource_Delete(t *testing.T) {
 		store.AssertExpectations(t)
 	})
 }
+
+func TestSettingsResource_RequiresAdminForChanges(t *testing.T) {
+	store := new(MockSettingsStore)
+	store.On("List", mock.Anything).Return([]*models.Setting{}, nil)
+
+	r := chi.NewRouter()
+	r.Use(withRoles("staff"))
+	r.Mount("/settings", NewResource(store, new(MockAuth)).Router())
+
+	// Read-only staff can list settings
+	req := httptest.NewRequest("GET", "/settings", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// but cannot delete them
+	req = httptest.NewRequest("DELETE", "/settings/1", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	store.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
@@ -3,11 +3,14 @@ package student
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
+	"github.com/dhax/go-base/auth/authorize"
 	"github.com/dhax/go-base/auth/jwt"
 	"github.com/dhax/go-base/logging"
 	"github.com/dhax/go-base/models"
@@ -30,13 +33,16 @@ type StudentStore interface {
 	UpdateStudent(ctx context.Context, student *models.Student) error
 	DeleteStudent(ctx context.Context, id int64) error
 	ListStudents(ctx context.Context, filters map[string]interface{}) ([]models.Student, error)
-	UpdateStudentLocation(ctx context.Context, id int64, locations map[string]bool) error
+	TransitionStudentLocation(ctx context.Context, t *models.LocationTransition) error
+	GetStudentLocationAt(ctx context.Context, studentID int64, at time.Time) (*models.LocationTransition, error)
+	GetStudentLocationHistory(ctx context.Context, studentID int64, from, to time.Time) ([]models.LocationTransition, error)
 	CreateStudentVisit(ctx context.Context, studentID, roomID, timespanID int64) (*models.Visit, error)
 	GetStudentVisits(ctx context.Context, studentID int64, date *time.Time) ([]models.Visit, error)
 	GetRoomVisits(ctx context.Context, roomID int64, date *time.Time, active bool) ([]models.Visit, error)
 	GetCombinedGroupVisits(ctx context.Context, combinedGroupID int64, date *time.Time, active bool) ([]models.Visit, error)
 	GetStudentAsList(ctx context.Context, id int64) (*models.StudentList, error)
 	CreateFeedback(ctx context.Context, studentID int64, feedbackValue string, mensaFeedback bool) (*models.Feedback, error)
+	GetAccessibleGroupIDs(ctx context.Context, accountID int64) ([]int64, error)
 }
 
 // AuthTokenStore defines operations for the auth token store
@@ -60,31 +66,78 @@ func (rs *Resource) Router() chi.Router {
 	r.Group(func(r chi.Router) {
 		r.Use(jwt.Authenticator)
 
+		canRead := authorize.RequiresPermission(authorize.PermRead)
+		canWrite := authorize.RequiresPermission(authorize.PermWrite)
+		canManage := authorize.RequiresPermission(authorize.PermManage)
+
 		// Student routes
 		r.Route("/", func(r chi.Router) {
-			r.Get("/", rs.listStudents)
-			r.Post("/", rs.createStudent)
+			r.With(canRead).Get("/", rs.listStudents)
+			r.With(canWrite).Post("/", rs.createStudent)
 			r.Route("/{id}", func(r chi.Router) {
-				r.Get("/", rs.getStudent)
-				r.Put("/", rs.updateStudent)
-				r.Delete("/", rs.deleteStudent)
-				r.Get("/visits", rs.getStudentVisits)
+				r.With(canRead).Get("/", rs.getStudent)
+				r.With(canWrite).Put("/", rs.updateStudent)
+				r.With(canManage).Delete("/", rs.deleteStudent)
+				r.With(canRead).Get("/visits", rs.getStudentVisits)
+				r.With(canRead).Get("/location", rs.getStudentLocation)
+				r.With(canRead).Get("/location-history", rs.getStudentLocationHistory)
 			})
 		})
 
 		// Special operations
-		r.Post("/register-in-room", rs.registerStudentInRoom)
-		r.Post("/unregister-from-room", rs.unregisterStudentFromRoom)
-		r.Post("/update-location", rs.updateStudentLocation)
-		r.Post("/give-feedback", rs.giveFeedback)
+		r.With(canWrite).Post("/register-in-room", rs.registerStudentInRoom)
+		r.With(canWrite).Post("/unregister-from-room", rs.unregisterStudentFromRoom)
+		r.With(canWrite).Post("/update-location", rs.updateStudentLocation)
+		r.With(canWrite).Post("/give-feedback", rs.giveFeedback)
 
 		// Combined group visits
-		r.Get("/combined-group/{id}/visits", rs.getCombinedGroupVisits)
+		r.With(canRead).Get("/combined-group/{id}/visits", rs.getCombinedGroupVisits)
 	})
 
 	return r
 }
 
+// ======== Access Scope ========
+
+// groupScope returns the set of group IDs the requesting account may access. Pedagogical
+// specialists are restricted to the groups they supervise or are granted through combined
+// groups; for all other accounts a nil set is returned, meaning no restriction.
+func (rs *Resource) groupScope(ctx context.Context) (map[int64]bool, error) {
+	claims := jwt.ClaimsFromCtx(ctx)
+	if slices.Contains(claims.Roles, authorize.RoleAdmin) || !slices.Contains(claims.Roles, authorize.RoleSpecialist) {
+		return nil, nil
+	}
+
+	groupIDs, err := rs.Store.GetAccessibleGroupIDs(ctx, int64(claims.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	scope := make(map[int64]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		scope[id] = true
+	}
+	return scope, nil
+}
+
+// canAccessStudent checks whether the requesting account may access the student.
+// Unknown students are reported as not accessible.
+func (rs *Resource) canAccessStudent(ctx context.Context, studentID int64) (bool, error) {
+	scope, err := rs.groupScope(ctx)
+	if err != nil || scope == nil {
+		return err == nil, err
+	}
+
+	student, err := rs.Store.GetStudentByID(ctx, studentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return scope[student.GroupID], nil
+}
+
 // ======== Student Handlers ========
 
 // listStudents returns a list of all students
@@ -110,6 +163,19 @@ func (rs *Resource) listStudents(w http.ResponseWriter, r *http.Request) {
 		filters["in_house"] = inHouse
 	}
 
+	scope, err := rs.groupScope(ctx)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if scope != nil {
+		groupIDs := make([]int64, 0, len(scope))
+		for id := range scope {
+			groupIDs = append(groupIDs, id)
+		}
+		filters["group_ids"] = groupIDs
+	}
+
 	students, err := rs.Store.ListStudents(ctx, filters)
 	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
@@ -128,6 +194,17 @@ func (rs *Resource) createStudent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	scope, err := rs.groupScope(ctx)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	// Students may not be created in groups outside the scope
+	if scope != nil && !scope[data.GroupID] {
+		render.Render(w, r, ErrForbidden)
+		return
+	}
+
 	if err := rs.Store.CreateStudent(ctx, data.Student); err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
@@ -155,7 +232,21 @@ func (rs *Resource) getStudent(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	student, err := rs.Store.GetStudentByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	scope, err := rs.groupScope(ctx)
 	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if scope != nil && !scope[student.GroupID] {
 		render.Render(w, r, ErrNotFound())
 		return
 	}
@@ -180,19 +271,35 @@ func (rs *Resource) updateStudent(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	student, err := rs.Store.GetStudentByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	scope, err := rs.groupScope(ctx)
 	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if scope != nil && !scope[student.GroupID] {
 		render.Render(w, r, ErrNotFound())
 		return
 	}
+	// Students may not be moved to groups outside the scope either
+	if scope != nil && !scope[data.GroupID] {
+		render.Render(w, r, ErrForbidden)
+		return
+	}
 
 	// Update student fields except ID, CreatedAt and relationships
 	student.SchoolClass = data.SchoolClass
 	student.Bus = data.Bus
 	student.NameLG = data.NameLG
 	student.ContactLG = data.ContactLG
-	student.InHouse = data.InHouse
-	student.WC = data.WC
-	student.SchoolYard = data.SchoolYard
 	student.GroupID = data.GroupID
 
 	if err := rs.Store.UpdateStudent(ctx, student); err != nil {
@@ -220,6 +327,16 @@ func (rs *Resource) deleteStudent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	allowed, err := rs.canAccessStudent(ctx, id)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
 	if err := rs.Store.DeleteStudent(ctx, id); err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
@@ -244,6 +361,15 @@ func (rs *Resource) registerStudentInRoom(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
+	allowed, err := rs.canAccessStudent(ctx, data.StudentID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
 
 	// Get the active room occupancy for the device ID
 	log := logging.GetLogEntry(r)
@@ -284,12 +410,15 @@ func (rs *Resource) registerStudentInRoom(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Update in_house status
-	locations := map[string]bool{
-		"in_house": true,
-	}
-	if err := rs.Store.UpdateStudentLocation(ctx, data.StudentID, locations); err != nil {
-		render.Render(w, r, ErrInternalServerError(err))
+	// Move the student into the room
+	if err := rs.Store.TransitionStudentLocation(ctx, &models.LocationTransition{
+		StudentID:  data.StudentID,
+		ToLocation: models.LocationRoom,
+		RoomID:     &roomID,
+		Source:     data.DeviceID,
+		ActorID:    actorID(ctx),
+	}); err != nil {
+		renderTransitionError(w, r, err)
 		return
 	}
 
@@ -305,15 +434,26 @@ func (rs *Resource) unregisterStudentFromRoom(w http.ResponseWriter, r *http.Req
 	}
 
 	ctx := r.Context()
-
-	// Update in_house status to false
-	locations := map[string]bool{
-		"in_house": false,
-	}
-	if err := rs.Store.UpdateStudentLocation(ctx, data.StudentID, locations); err != nil {
+	allowed, err := rs.canAccessStudent(ctx, data.StudentID)
+	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	// Leaving the room returns the student to the building
+	if err := rs.Store.TransitionStudentLocation(ctx, &models.LocationTransition{
+		StudentID:  data.StudentID,
+		ToLocation: models.LocationInHouse,
+		Source:     data.DeviceID,
+		ActorID:    actorID(ctx),
+	}); err != nil {
+		renderTransitionError(w, r, err)
+		return
+	}
 
 	// Return success message
 	render.JSON(w, r, map[string]interface{}{
@@ -342,6 +482,16 @@ func (rs *Resource) getStudentVisits(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	allowed, err := rs.canAccessStudent(ctx, id)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
 	visits, err := rs.Store.GetStudentVisits(ctx, id, date)
 	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
@@ -383,6 +533,22 @@ func (rs *Resource) getCombinedGroupVisits(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	scope, err := rs.groupScope(ctx)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if scope != nil {
+		// Only return visits of students in accessible groups
+		scoped := make([]models.Visit, 0, len(visits))
+		for _, visit := range visits {
+			if visit.Student != nil && scope[visit.Student.GroupID] {
+				scoped = append(scoped, visit)
+			}
+		}
+		visits = scoped
+	}
+
 	render.JSON(w, r, visits)
 }
 
@@ -395,6 +561,16 @@ func (rs *Resource) giveFeedback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	allowed, err := rs.canAccessStudent(ctx, data.StudentID)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
 	feedback, err := rs.Store.CreateFeedback(ctx, data.StudentID, data.FeedbackValue, data.MensaFeedback)
 	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
@@ -405,7 +581,7 @@ func (rs *Resource) giveFeedback(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, feedback)
 }
 
-// updateStudentLocation updates a student's location flags
+// updateStudentLocation manually moves a student to another location
 func (rs *Resource) updateStudentLocation(w http.ResponseWriter, r *http.Request) {
 	data := &LocationUpdateRequest{}
 	if err := render.Bind(r, data); err != nil {
@@ -414,10 +590,26 @@ func (rs *Resource) updateStudentLocation(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := r.Context()
-	if err := rs.Store.UpdateStudentLocation(ctx, data.StudentID, data.Locations); err != nil {
+	allowed, err := rs.canAccessStudent(ctx, data.StudentID)
+	if err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	if err := rs.Store.TransitionStudentLocation(ctx, &models.LocationTransition{
+		StudentID:  data.StudentID,
+		ToLocation: data.Location,
+		RoomID:     data.RoomID,
+		Source:     locationSourceAPI,
+		ActorID:    actorID(ctx),
+	}); err != nil {
+		renderTransitionError(w, r, err)
+		return
+	}
 
 	// Return success message
 	render.JSON(w, r, map[string]interface{}{
@@ -457,8 +649,9 @@ func (fr *FeedbackRequest) Bind(r *http.Request) error {
 
 // LocationUpdateRequest represents request payload for updating student location
 type LocationUpdateRequest struct {
-	StudentID int64           `json:"student_id"`
-	Locations map[string]bool `json:"locations"`
+	StudentID int64                `json:"student_id"`
+	Location  models.LocationState `json:"location"`
+	RoomID    *int64               `json:"room_id,omitempty"`
 }
 
 // Bind preprocesses a LocationUpdateRequest
@@ -466,8 +659,8 @@ func (lu *LocationUpdateRequest) Bind(r *http.Request) error {
 	if lu.StudentID == 0 {
 		return errors.New("student_id is required")
 	}
-	if lu.Locations == nil {
-		return errors.New("locations is required")
+	if !lu.Location.Valid() {
+		return errors.New("location is invalid")
 	}
 	return nil
 }
@@ -2,9 +2,13 @@ package student
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -59,11 +63,24 @@ func (m *MockStudentStore) ListStudents(ctx context.Context, filters map[string]
 	return args.Get(0).([]models.Student), args.Error(1)
 }
 
-func (m *MockStudentStore) UpdateStudentLocation(ctx context.Context, id int64, locations map[string]bool) error {
-	args := m.Called(ctx, id, locations)
+func (m *MockStudentStore) TransitionStudentLocation(ctx context.Context, t *models.LocationTransition) error {
+	args := m.Called(ctx, t)
 	return args.Error(0)
 }
 
+func (m *MockStudentStore) GetStudentLocationAt(ctx context.Context, studentID int64, at time.Time) (*models.LocationTransition, error) {
+	args := m.Called(ctx, studentID, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LocationTransition), args.Error(1)
+}
+
+func (m *MockStudentStore) GetStudentLocationHistory(ctx context.Context, studentID int64, from, to time.Time) ([]models.LocationTransition, error) {
+	args := m.Called(ctx, studentID, from, to)
+	return args.Get(0).([]models.LocationTransition), args.Error(1)
+}
+
 func (m *MockStudentStore) CreateStudentVisit(ctx context.Context, studentID, roomID, timespanID int64) (*models.Visit, error) {
 	args := m.Called(ctx, studentID, roomID, timespanID)
 	if args.Get(0) == nil {
@@ -103,6 +120,11 @@ func (m *MockStudentStore) GetCombinedGroupVisits(ctx context.Context, combinedG
 	return args.Get(0).([]models.Visit), args.Error(1)
 }
 
+func (m *MockStudentStore) GetAccessibleGroupIDs(ctx context.Context, accountID int64) ([]int64, error) {
+	args := m.Called(ctx, accountID)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
 func (m *MockStudentStore) GetRoomOccupancyByDeviceID(ctx context.Context, deviceID string) (*models.RoomOccupancyDetail, error) {
 	args := m.Called(ctx, deviceID)
 	if args.Get(0) == nil {
@@ -131,6 +153,11 @@ func setupTestAPI() (*Resource, *MockStudentStore, *MockAuthTokenStore) {
 	return resource, mockStudentStore, mockAuthStore
 }
 
+// withClaims returns a copy of the request carrying JWT claims with the given roles
+func withClaims(r *http.Request, accountID int, roles ...string) *http.Request {
+	return r.WithContext(jwt.ContextWithClaims(r.Context(), jwt.AppClaims{ID: accountID, Roles: roles}))
+}
+
 func TestListStudents(t *testing.T) {
 	rs, mockStudentStore, _ := setupTestAPI()
 
@@ -174,7 +201,7 @@ func TestListStudents(t *testing.T) {
 	mockStudentStore.On("ListStudents", mock.Anything, mock.Anything).Return(testStudents, nil)
 
 	// Create test request
-	r := httptest.NewRequest("GET", "/", nil)
+	r := withClaims(httptest.NewRequest("GET", "/", nil), 1, "admin")
 	w := httptest.NewRecorder()
 
 	// Call the handler directly
@@ -225,6 +252,7 @@ func TestGetStudent(t *testing.T) {
 	rctx := chi.NewRouteContext()
 	rctx.URLParams.Add("id", "1")
 	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	r = withClaims(r, 1, "admin")
 
 	w := httptest.NewRecorder()
 
@@ -243,6 +271,231 @@ func TestGetStudent(t *testing.T) {
 	mockStudentStore.AssertExpectations(t)
 }
 
+func TestListStudentsScopedToSupervisedGroups(t *testing.T) {
+	rs, mockStudentStore, _ := setupTestAPI()
+
+	mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+	mockStudentStore.On("ListStudents", mock.Anything, mock.MatchedBy(func(filters map[string]interface{}) bool {
+		groupIDs, ok := filters["group_ids"].([]int64)
+		return ok && len(groupIDs) == 1 && groupIDs[0] == 3
+	})).Return([]models.Student{{ID: 1, GroupID: 3}}, nil)
+
+	r := withClaims(httptest.NewRequest("GET", "/", nil), 7, "specialist")
+	w := httptest.NewRecorder()
+
+	rs.listStudents(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestGetStudentOutsideScope(t *testing.T) {
+	rs, mockStudentStore, _ := setupTestAPI()
+
+	mockStudentStore.On("GetStudentByID", mock.Anything, int64(1)).Return(&models.Student{ID: 1, GroupID: 2}, nil)
+	mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+
+	r := httptest.NewRequest("GET", "/1", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	r = withClaims(r, 7, "specialist")
+
+	w := httptest.NewRecorder()
+
+	rs.getStudent(w, r)
+
+	// Students of groups not supervised are reported as not found
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestModifyStudentOutsideScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		body    string
+		handler func(rs *Resource) http.HandlerFunc
+		status  int
+	}{
+		{"create in other group", "POST", "/", `{"group_id":2}`, func(rs *Resource) http.HandlerFunc { return rs.createStudent }, http.StatusForbidden},
+		{"update", "PUT", "/1", `{"group_id":2}`, func(rs *Resource) http.HandlerFunc { return rs.updateStudent }, http.StatusNotFound},
+		{"update into other group", "PUT", "/3", `{"group_id":2}`, func(rs *Resource) http.HandlerFunc { return rs.updateStudent }, http.StatusForbidden},
+		{"delete", "DELETE", "/1", "", func(rs *Resource) http.HandlerFunc { return rs.deleteStudent }, http.StatusNotFound},
+		{"register in room", "POST", "/register-in-room", `{"student_id":1,"device_id":"READER01"}`, func(rs *Resource) http.HandlerFunc { return rs.registerStudentInRoom }, http.StatusNotFound},
+		{"unregister from room", "POST", "/unregister-from-room", `{"student_id":1,"device_id":"READER01"}`, func(rs *Resource) http.HandlerFunc { return rs.unregisterStudentFromRoom }, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, mockStudentStore, _ := setupTestAPI()
+
+			mockStudentStore.On("GetStudentByID", mock.Anything, int64(1)).Return(&models.Student{ID: 1, GroupID: 2}, nil).Maybe()
+			mockStudentStore.On("GetStudentByID", mock.Anything, int64(3)).Return(&models.Student{ID: 3, GroupID: 3}, nil).Maybe()
+			mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", strings.TrimPrefix(tt.target, "/"))
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			r = withClaims(r, 7, "specialist")
+
+			w := httptest.NewRecorder()
+
+			tt.handler(rs)(w, r)
+
+			// The mock panics on any creation, update, deletion or location change
+			assert.Equal(t, tt.status, w.Code)
+			mockStudentStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCanAccessStudentStoreError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"unknown student", sql.ErrNoRows, http.StatusNotFound},
+		{"store error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, mockStudentStore, _ := setupTestAPI()
+
+			mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+			mockStudentStore.On("GetStudentByID", mock.Anything, int64(1)).Return(nil, tt.err)
+
+			r := httptest.NewRequest("GET", "/1/visits", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			r = withClaims(r, 7, "specialist")
+
+			w := httptest.NewRecorder()
+
+			rs.getStudentVisits(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockStudentStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCombinedGroupVisitsScoped(t *testing.T) {
+	rs, mockStudentStore, _ := setupTestAPI()
+
+	visits := []models.Visit{
+		{ID: 1, StudentID: 1, Student: &models.Student{ID: 1, GroupID: 3}},
+		{ID: 2, StudentID: 2, Student: &models.Student{ID: 2, GroupID: 4}},
+	}
+	mockStudentStore.On("GetCombinedGroupVisits", mock.Anything, int64(5), (*time.Time)(nil), false).Return(visits, nil)
+	mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+
+	r := httptest.NewRequest("GET", "/combined-group/5/visits", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "5")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	r = withClaims(r, 7, "specialist")
+
+	w := httptest.NewRecorder()
+
+	rs.getCombinedGroupVisits(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var responseVisits []models.Visit
+	err := json.Unmarshal(w.Body.Bytes(), &responseVisits)
+	assert.NoError(t, err)
+	assert.Len(t, responseVisits, 1)
+	assert.Equal(t, int64(1), responseVisits[0].ID)
+
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestUpdateStudentLocationRejectsInvalidTransition(t *testing.T) {
+	rs, mockStudentStore, _ := setupTestAPI()
+
+	mockStudentStore.On("TransitionStudentLocation", mock.Anything, mock.MatchedBy(func(t *models.LocationTransition) bool {
+		return t.StudentID == 1 && t.ToLocation == models.LocationWC && t.Source == locationSourceAPI
+	})).Return(fmt.Errorf("%w: absent to wc", models.ErrInvalidTransition))
+
+	r := httptest.NewRequest("POST", "/update-location", strings.NewReader(`{"student_id":1,"location":"wc"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r = withClaims(r, 1, "admin")
+	w := httptest.NewRecorder()
+
+	rs.updateStudentLocation(w, r)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestGetStudentLocationAt(t *testing.T) {
+	rs, mockStudentStore, _ := setupTestAPI()
+
+	at := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	roomID := int64(3)
+	mockStudentStore.On("GetStudentLocationAt", mock.Anything, int64(1), at).Return(&models.LocationTransition{
+		StudentID:  1,
+		ToLocation: models.LocationRoom,
+		RoomID:     &roomID,
+		Source:     "READER001",
+		At:         at.Add(-time.Hour),
+	}, nil)
+
+	r := httptest.NewRequest("GET", "/1/location?at=2024-03-01T10:00:00Z", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	r = withClaims(r, 1, "admin")
+	w := httptest.NewRecorder()
+
+	rs.getStudentLocation(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp LocationResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.Equal(t, models.LocationRoom, resp.Location)
+	assert.Equal(t, &roomID, resp.RoomID)
+	assert.Equal(t, "READER001", resp.Source)
+
+	mockStudentStore.AssertExpectations(t)
+}
+
+func TestGetStudentLocationOutsideScope(t *testing.T) {
+	for _, target := range []string{"/1/location", "/1/location-history"} {
+		t.Run(target, func(t *testing.T) {
+			rs, mockStudentStore, _ := setupTestAPI()
+
+			mockStudentStore.On("GetStudentByID", mock.Anything, int64(1)).Return(&models.Student{ID: 1, GroupID: 2}, nil)
+			mockStudentStore.On("GetAccessibleGroupIDs", mock.Anything, int64(7)).Return([]int64{3}, nil)
+
+			r := httptest.NewRequest("GET", target, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			r = withClaims(r, 7, "specialist")
+			w := httptest.NewRecorder()
+
+			if strings.HasSuffix(target, "history") {
+				rs.getStudentLocationHistory(w, r)
+			} else {
+				rs.getStudentLocation(w, r)
+			}
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			mockStudentStore.AssertExpectations(t)
+		})
+	}
+}
+
 func TestRouter(t *testing.T) {
 	rs, _, _ := setupTestAPI()
 	router := rs.Router()
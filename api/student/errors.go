@@ -42,6 +42,16 @@ func ErrRender(err error) render.Renderer {
 	}
 }
 
+// ErrConflict returns a 409 Conflict response with the error message.
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrNotFound returns a 404 Not Found response.
 func ErrNotFound() render.Renderer {
 	return &ErrResponse{
@@ -0,0 +1,140 @@
+package student
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/dhax/go-base/models"
+)
+
+// locationSourceAPI is the transition source for manual location changes by staff
+const locationSourceAPI = "api"
+
+// actorID returns the account ID of the requesting staff member
+func actorID(ctx context.Context) *int64 {
+	id := int64(jwt.ClaimsFromCtx(ctx).ID)
+	return &id
+}
+
+// renderTransitionError renders rejected location transitions as conflict
+func renderTransitionError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		render.Render(w, r, ErrConflict(err))
+	case errors.Is(err, sql.ErrNoRows):
+		render.Render(w, r, ErrNotFound())
+	default:
+		render.Render(w, r, ErrInternalServerError(err))
+	}
+}
+
+// LocationResponse is the location of a student at a point in time
+type LocationResponse struct {
+	StudentID int64                `json:"student_id"`
+	At        time.Time            `json:"at"`
+	Location  models.LocationState `json:"location"`
+	RoomID    *int64               `json:"room_id,omitempty"`
+	Since     *time.Time           `json:"since,omitempty"`
+	Source    string               `json:"source,omitempty"`
+	ActorID   *int64               `json:"actor_id,omitempty"`
+}
+
+// getStudentLocation returns the location of a student, at the time given by the
+// RFC 3339 "at" parameter or now
+func (rs *Resource) getStudentLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	at := time.Now()
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, err = time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("invalid at format, expected RFC 3339")))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	allowed, err := rs.canAccessStudent(ctx, id)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	resp := &LocationResponse{
+		StudentID: id,
+		At:        at,
+		Location:  models.LocationAbsent,
+	}
+
+	t, err := rs.Store.GetStudentLocationAt(ctx, id, at)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// no location recorded until then
+	case err != nil:
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	default:
+		resp.Location = t.ToLocation
+		resp.RoomID = t.RoomID
+		resp.Since = &t.At
+		resp.Source = t.Source
+		resp.ActorID = t.ActorID
+	}
+
+	render.JSON(w, r, resp)
+}
+
+// getStudentLocationHistory returns the location transitions of a student on the
+// day given by the "date" parameter or today
+func (rs *Resource) getStudentLocationHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		from, err = time.ParseInLocation("2006-01-02", dateStr, now.Location())
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("invalid date format, expected YYYY-MM-DD")))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	allowed, err := rs.canAccessStudent(ctx, id)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+	if !allowed {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	transitions, err := rs.Store.GetStudentLocationHistory(ctx, id, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	render.JSON(w, r, transitions)
+}
@@ -2,12 +2,15 @@ package user
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/sirupsen/logrus"
 
 	"github.com/dhax/go-base/auth/authorize"
@@ -20,6 +23,9 @@ import (
 type Resource struct {
 	Store     UserStore
 	AuthStore AuthTokenStore
+
+	// UnknownTags collects scans of tags not assigned to any user, optional
+	UnknownTags UnknownTagRecorder
 }
 
 // UserStore defines database operations for user management
@@ -32,6 +38,13 @@ type UserStore interface {
 	UpdateTagID(ctx context.Context, userID int64, tagID string) error
 	ListCustomUsers(ctx context.Context) ([]models.CustomUser, error)
 
+	ListUserTags(ctx context.Context, userID int64) ([]models.UserTag, error)
+	AssignTag(ctx context.Context, tag *models.UserTag) error
+	SetTagStatus(ctx context.Context, userID int64, tagID, status, note string) error
+	UnassignTag(ctx context.Context, userID int64, tagID string) error
+	GetTagHistory(ctx context.Context, tagID string) ([]models.UserTag, error)
+	GetTagOwnerAt(ctx context.Context, tagID string, at time.Time) (*models.CustomUser, error)
+
 	GetSpecialistByID(ctx context.Context, id int64) (*models.PedagogicalSpecialist, error)
 	GetSpecialistByUserID(ctx context.Context, userID int64) (*models.PedagogicalSpecialist, error)
 	CreateSpecialist(ctx context.Context, specialist *models.PedagogicalSpecialist) error
@@ -47,6 +60,11 @@ type UserStore interface {
 	ListDevicesByUserID(ctx context.Context, userID int64) ([]models.Device, error)
 }
 
+// UnknownTagRecorder collects reads of tags not assigned to any user
+type UnknownTagRecorder interface {
+	RecordUnknownTag(ctx context.Context, tagID, readerID string, readAt time.Time) error
+}
+
 // AuthTokenStore defines operations for the auth token store
 type AuthTokenStore interface {
 	GetToken(t string) (*jwt.Token, error)
@@ -73,7 +91,7 @@ func (rs *Resource) Router() chi.Router {
 	// JWT protected routes
 	r.Group(func(r chi.Router) {
 		r.Use(jwt.Authenticator)
-		r.Use(authorize.RequiresRole("admin"))
+		r.Use(authorize.RequiresRole(authorize.RoleAdmin))
 
 		// User routes
 		r.Route("/users", func(r chi.Router) {
@@ -83,9 +101,21 @@ func (rs *Resource) Router() chi.Router {
 				r.Get("/", rs.getUser)
 				r.Put("/", rs.updateUser)
 				r.Delete("/", rs.deleteUser)
+				r.Route("/tags", func(r chi.Router) {
+					r.Get("/", rs.listUserTags)
+					r.Post("/", rs.assignUserTag)
+					r.Delete("/{tag_id}", rs.unassignUserTag)
+					r.Put("/{tag_id}/status", rs.setUserTagStatus)
+				})
 			})
 		})
 
+		// Tag routes
+		r.Route("/tags/{tag_id}", func(r chi.Router) {
+			r.Get("/history", rs.getTagHistory)
+			r.Get("/owner", rs.getTagOwner)
+		})
+
 		// Specialist routes
 		r.Route("/specialists", func(r chi.Router) {
 			r.Get("/", rs.listSpecialists)
@@ -156,6 +186,38 @@ func (req *ChangeTagIDRequest) Bind(r *http.Request) error {
 	return nil
 }
 
+// UserTagRequest is the request payload for assigning a tag to a user
+type UserTagRequest struct {
+	TagID      string     `json:"tag_id"`
+	Kind       string     `json:"kind"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Note       string     `json:"note,omitempty"`
+}
+
+// Bind preprocesses a UserTagRequest
+func (req *UserTagRequest) Bind(r *http.Request) error {
+	if req.TagID == "" {
+		return errors.New("tag_id is required")
+	}
+	return nil
+}
+
+// TagStatusRequest is the request payload for changing the status of a tag
+type TagStatusRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Bind preprocesses a TagStatusRequest
+func (req *TagStatusRequest) Bind(r *http.Request) error {
+	switch req.Status {
+	case models.TagStatusActive, models.TagStatusLost, models.TagStatusBlocked:
+		return nil
+	}
+	return errors.New("status must be one of active, lost or blocked")
+}
+
 // TagScanRequest is the request payload for processing a tag scan
 type TagScanRequest struct {
 	TagID    string `json:"tag_id"`
@@ -243,15 +305,21 @@ func (rs *Resource) updateUser(w http.ResponseWriter, r *http.Request) {
 	// Update user fields
 	user.FirstName = data.FirstName
 	user.SecondName = data.SecondName
-	if data.TagID != nil {
-		user.TagID = data.TagID
-	}
 
 	if err := rs.Store.UpdateCustomUser(ctx, user); err != nil {
 		render.Render(w, r, ErrInternalServerError(err))
 		return
 	}
 
+	// A new tag replaces the card of the user, keeping the previous one in the history
+	if data.TagID != nil && *data.TagID != "" && (user.TagID == nil || *user.TagID != *data.TagID) {
+		if err := rs.Store.UpdateTagID(ctx, user.ID, *data.TagID); err != nil {
+			renderTagError(w, r, err)
+			return
+		}
+		user.TagID = data.TagID
+	}
+
 	render.JSON(w, r, user)
 }
 
@@ -452,6 +520,165 @@ func (rs *Resource) deleteDevice(w http.ResponseWriter, r *http.Request) {
 	render.NoContent(w, r)
 }
 
+// ======== Tag Handlers ========
+
+// listUserTags returns the tags of a user, including ended assignments
+func (rs *Resource) listUserTags(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	ctx := r.Context()
+	tags, err := rs.Store.ListUserTags(ctx, id)
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	render.JSON(w, r, tags)
+}
+
+// assignUserTag assigns an additional tag to a user, e.g. a wristband
+func (rs *Resource) assignUserTag(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	data := &UserTagRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := rs.Store.GetCustomUserByID(ctx, id); err != nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+
+	tag := &models.UserTag{
+		TagID:        data.TagID,
+		CustomUserID: id,
+		Kind:         data.Kind,
+		ValidUntil:   data.ValidUntil,
+		Note:         data.Note,
+	}
+	if data.ValidFrom != nil {
+		tag.ValidFrom = *data.ValidFrom
+	}
+
+	if err := rs.Store.AssignTag(ctx, tag); err != nil {
+		renderTagError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, tag)
+}
+
+// setUserTagStatus changes the status of a tag of a user, e.g. reports it lost
+func (rs *Resource) setUserTagStatus(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	data := &TagStatusRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	ctx := r.Context()
+	tagID := chi.URLParam(r, "tag_id")
+	if err := rs.Store.SetTagStatus(ctx, id, tagID, data.Status, data.Note); err != nil {
+		renderTagError(w, r, err)
+		return
+	}
+
+	logging.GetLogEntry(r).WithFields(logrus.Fields{
+		"tag_id":  tagID,
+		"user_id": id,
+		"status":  data.Status,
+	}).Info("Tag status changed")
+
+	render.JSON(w, r, map[string]bool{"success": true})
+}
+
+// unassignUserTag ends the assignment of a tag to a user
+func (rs *Resource) unassignUserTag(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid ID format")))
+		return
+	}
+
+	ctx := r.Context()
+	if err := rs.Store.UnassignTag(ctx, id, chi.URLParam(r, "tag_id")); err != nil {
+		renderTagError(w, r, err)
+		return
+	}
+
+	render.NoContent(w, r)
+}
+
+// getTagHistory returns all assignments of a tag with their users
+func (rs *Resource) getTagHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tags, err := rs.Store.GetTagHistory(ctx, chi.URLParam(r, "tag_id"))
+	if err != nil {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
+
+	render.JSON(w, r, tags)
+}
+
+// getTagOwner returns the user holding a tag at the time given as "at", now by default
+func (rs *Resource) getTagOwner(w http.ResponseWriter, r *http.Request) {
+	at := time.Now()
+	if s := r.URL.Query().Get("at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("at must be an RFC 3339 time")))
+			return
+		}
+		at = t
+	}
+
+	ctx := r.Context()
+	user, err := rs.Store.GetTagOwnerAt(ctx, chi.URLParam(r, "tag_id"), at)
+	if err != nil {
+		renderTagError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, user)
+}
+
+// renderTagError renders the response for an error of a tag operation
+func renderTagError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		render.Render(w, r, ErrNotFound)
+	case errors.Is(err, models.ErrTagAssigned):
+		render.Render(w, r, ErrConflict(err))
+	case errors.As(err, new(validation.Errors)):
+		render.Render(w, r, ErrInvalidRequest(err))
+	default:
+		render.Render(w, r, ErrInternalServerError(err))
+	}
+}
+
 // ======== Special Operations ========
 
 // changeTagID changes the RFID tag ID for a user
@@ -464,7 +691,7 @@ func (rs *Resource) changeTagID(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	if err := rs.Store.UpdateTagID(ctx, data.UserID, data.TagID); err != nil {
-		render.Render(w, r, ErrInternalServerError(err))
+		renderTagError(w, r, err)
 		return
 	}
 
@@ -483,6 +710,24 @@ func (rs *Resource) processTagScan(w http.ResponseWriter, r *http.Request) {
 
 	// Get user by tag ID
 	user, err := rs.Store.GetCustomUserByTagID(ctx, data.TagID)
+	if errors.Is(err, models.ErrTagBlocked) || errors.Is(err, models.ErrTagNotValid) {
+		logging.GetLogEntry(r).WithError(err).WithFields(logrus.Fields{
+			"tag_id":    data.TagID,
+			"device_id": data.DeviceID,
+			"user_id":   user.ID,
+		}).Warning("Tag scanned but not accepted")
+
+		render.JSON(w, r, map[string]interface{}{
+			"success": false,
+			"user_id": user.ID,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrInternalServerError(err))
+		return
+	}
 	if err != nil {
 		// If no user found with this tag, just log the tag read
 		log := logging.GetLogEntry(r)
@@ -491,6 +736,13 @@ func (rs *Resource) processTagScan(w http.ResponseWriter, r *http.Request) {
 			"device_id": data.DeviceID,
 		}).Info("Tag scanned but no user associated")
 
+		// Collect the tag so it can be assigned to a user later
+		if rs.UnknownTags != nil {
+			if err := rs.UnknownTags.RecordUnknownTag(ctx, data.TagID, data.DeviceID, time.Now()); err != nil {
+				log.WithError(err).Error("Failed to record unknown tag")
+			}
+		}
+
 		render.JSON(w, r, map[string]interface{}{
 			"success": false,
 			"message": "No user found with this tag ID",
@@ -3,10 +3,12 @@ package user
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dhax/go-base/auth/jwt"
 	"github.com/dhax/go-base/models"
@@ -62,6 +64,39 @@ func (m *MockUserStore) ListCustomUsers(ctx context.Context) ([]models.CustomUse
 	return args.Get(0).([]models.CustomUser), args.Error(1)
 }
 
+func (m *MockUserStore) ListUserTags(ctx context.Context, userID int64) ([]models.UserTag, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.UserTag), args.Error(1)
+}
+
+func (m *MockUserStore) AssignTag(ctx context.Context, tag *models.UserTag) error {
+	args := m.Called(ctx, tag)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) SetTagStatus(ctx context.Context, userID int64, tagID, status, note string) error {
+	args := m.Called(ctx, userID, tagID, status, note)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) UnassignTag(ctx context.Context, userID int64, tagID string) error {
+	args := m.Called(ctx, userID, tagID)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) GetTagHistory(ctx context.Context, tagID string) ([]models.UserTag, error) {
+	args := m.Called(ctx, tagID)
+	return args.Get(0).([]models.UserTag), args.Error(1)
+}
+
+func (m *MockUserStore) GetTagOwnerAt(ctx context.Context, tagID string, at time.Time) (*models.CustomUser, error) {
+	args := m.Called(ctx, tagID, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CustomUser), args.Error(1)
+}
+
 func (m *MockUserStore) GetSpecialistByID(ctx context.Context, id int64) (*models.PedagogicalSpecialist, error) {
 	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
@@ -347,6 +382,161 @@ func TestProcessTagScan(t *testing.T) {
 	mockUserStore.AssertExpectations(t)
 }
 
+type mockUnknownTagRecorder struct {
+	mock.Mock
+}
+
+func (m *mockUnknownTagRecorder) RecordUnknownTag(ctx context.Context, tagID, readerID string, readAt time.Time) error {
+	args := m.Called(ctx, tagID, readerID, readAt)
+	return args.Error(0)
+}
+
+func TestProcessTagScanUnknownTag(t *testing.T) {
+	rs, mockUserStore, _ := setupTestAPI()
+	recorder := new(mockUnknownTagRecorder)
+	rs.UnknownTags = recorder
+
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "NEWCARD01").Return(nil, sql.ErrNoRows)
+	recorder.On("RecordUnknownTag", mock.Anything, "NEWCARD01", "DEVICE001", mock.Anything).Return(nil)
+
+	body, _ := json.Marshal(TagScanRequest{TagID: "NEWCARD01", DeviceID: "DEVICE001"})
+	r := httptest.NewRequest("POST", "/process-tag-scan", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	rs.processTagScan(w, r)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response["success"].(bool))
+
+	mockUserStore.AssertExpectations(t)
+	recorder.AssertExpectations(t)
+}
+
+func TestProcessTagScanBlockedTag(t *testing.T) {
+	rs, mockUserStore, _ := setupTestAPI()
+	recorder := new(mockUnknownTagRecorder)
+	rs.UnknownTags = recorder
+
+	user := &models.CustomUser{ID: 1, FirstName: "John", SecondName: "Doe"}
+	mockUserStore.On("GetCustomUserByTagID", mock.Anything, "LOSTCARD01").Return(user, models.ErrTagBlocked)
+
+	body, _ := json.Marshal(TagScanRequest{TagID: "LOSTCARD01", DeviceID: "DEVICE001"})
+	r := httptest.NewRequest("POST", "/process-tag-scan", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	rs.processTagScan(w, r)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response["success"].(bool))
+	assert.Equal(t, models.ErrTagBlocked.Error(), response["message"])
+
+	mockUserStore.AssertExpectations(t)
+	recorder.AssertNotCalled(t, "RecordUnknownTag", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAssignUserTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"wristband", `{"tag_id":"BAND01","kind":"wristband"}`, nil, http.StatusCreated},
+		{"assigned to another user", `{"tag_id":"BAND01","kind":"wristband"}`, models.ErrTagAssigned, http.StatusConflict},
+		{"missing tag", `{"kind":"wristband"}`, nil, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, mockUserStore, _ := setupTestAPI()
+
+			if tt.status != http.StatusUnprocessableEntity {
+				mockUserStore.On("GetCustomUserByID", mock.Anything, int64(1)).Return(&models.CustomUser{ID: 1}, nil)
+				mockUserStore.On("AssignTag", mock.Anything, mock.MatchedBy(func(tag *models.UserTag) bool {
+					return tag.TagID == "BAND01" && tag.CustomUserID == 1 && tag.Kind == models.TagKindWristband
+				})).Return(tt.err)
+			}
+
+			router := chi.NewRouter()
+			router.Post("/users/{id}/tags", rs.assignUserTag)
+
+			r := httptest.NewRequest("POST", "/users/1/tags", bytes.NewReader([]byte(tt.body)))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockUserStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSetUserTagStatus(t *testing.T) {
+	rs, mockUserStore, _ := setupTestAPI()
+
+	mockUserStore.On("SetTagStatus", mock.Anything, int64(1), "ABC123", models.TagStatusLost, "lost on the playground").Return(nil)
+	mockUserStore.On("SetTagStatus", mock.Anything, int64(1), "OTHER", models.TagStatusLost, "").Return(sql.ErrNoRows)
+
+	router := chi.NewRouter()
+	router.Put("/users/{id}/tags/{tag_id}/status", rs.setUserTagStatus)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/users/1/tags/ABC123/status", `{"status":"lost","note":"lost on the playground"}`, http.StatusOK},
+		{"/users/1/tags/OTHER/status", `{"status":"lost"}`, http.StatusNotFound},
+		{"/users/1/tags/ABC123/status", `{"status":"stolen"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("PUT", tt.path, bytes.NewReader([]byte(tt.body)))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.status, w.Code, tt.path+" "+tt.body)
+	}
+
+	mockUserStore.AssertExpectations(t)
+}
+
+func TestGetTagOwner(t *testing.T) {
+	rs, mockUserStore, _ := setupTestAPI()
+
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	mockUserStore.On("GetTagOwnerAt", mock.Anything, "ABC123", mock.MatchedBy(func(t time.Time) bool {
+		return t.Equal(at)
+	})).Return(&models.CustomUser{ID: 7, FirstName: "Former", SecondName: "Owner"}, nil)
+
+	router := chi.NewRouter()
+	router.Get("/tags/{tag_id}/owner", rs.getTagOwner)
+
+	r := httptest.NewRequest("GET", "/tags/ABC123/owner?at=2025-03-01T10:00:00Z", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var user models.CustomUser
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, int64(7), user.ID)
+
+	r = httptest.NewRequest("GET", "/tags/ABC123/owner?at=yesterday", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockUserStore.AssertExpectations(t)
+}
+
 func TestRouter(t *testing.T) {
 	rs, _, _ := setupTestAPI()
 	router := rs.Router()
@@ -366,6 +556,8 @@ func TestRouter(t *testing.T) {
 	assert.Contains(t, routes, "/specialists/")
 	assert.Contains(t, routes, "/change-tag-id")
 	assert.Contains(t, routes, "/process-tag-scan")
+	assert.Contains(t, routes, "/users/{id}/tags/")
+	assert.Contains(t, routes, "/tags/{tag_id}/history")
 }
 
 // Helper function to extract routes from a chi router
@@ -48,6 +48,16 @@ var ErrNotFound = &ErrResponse{
 	StatusText:     "Resource not found.",
 }
 
+// ErrConflict returns a 409 Conflict response.
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
+
 // ErrInternalServerError returns a 500 Internal Server Error response.
 func ErrInternalServerError(err error) render.Renderer {
 	return &ErrResponse{
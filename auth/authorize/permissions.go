@@ -0,0 +1,64 @@
+package authorize
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/render"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+// Account roles as stored in Account.Roles.
+const (
+	RoleAdmin      = "admin"
+	RoleSpecialist = "specialist" // pedagogical specialist
+	RoleStaff      = "staff"      // read-only staff
+	RoleUser       = "user"       // default role of new accounts, without permissions until granted a staff role
+)
+
+// Permission is an action on application resources granted through roles.
+type Permission string
+
+// Permissions enforced on protected routes.
+const (
+	// PermRead allows reading rooms, students, groups, activities and settings.
+	PermRead Permission = "read"
+	// PermWrite allows day-to-day changes like creating and updating students,
+	// registering visits or enrolling students in activities.
+	PermWrite Permission = "write"
+	// PermManage allows structural changes like deleting records, managing rooms
+	// and changing settings.
+	PermManage Permission = "manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:      {PermRead, PermWrite, PermManage},
+	RoleSpecialist: {PermRead, PermWrite},
+	RoleStaff:      {PermRead},
+}
+
+// RequiresPermission middleware restricts access to accounts having a role granting perm in their jwt claims.
+func RequiresPermission(perm Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			claims := jwt.ClaimsFromCtx(r.Context())
+			if !HasPermission(perm, claims.Roles) {
+				render.Render(w, r, ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+// HasPermission reports whether any of roles grants perm.
+func HasPermission(perm Permission, roles []string) bool {
+	for _, role := range roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
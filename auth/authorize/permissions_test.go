@@ -0,0 +1,71 @@
+package authorize
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		roles []string
+		perm  Permission
+		want  bool
+	}{
+		{[]string{RoleAdmin}, PermRead, true},
+		{[]string{RoleAdmin}, PermWrite, true},
+		{[]string{RoleAdmin}, PermManage, true},
+		{[]string{RoleSpecialist}, PermRead, true},
+		{[]string{RoleSpecialist}, PermWrite, true},
+		{[]string{RoleSpecialist}, PermManage, false},
+		{[]string{RoleStaff}, PermRead, true},
+		{[]string{RoleStaff}, PermWrite, false},
+		{[]string{RoleStaff}, PermManage, false},
+		{[]string{RoleUser}, PermRead, false},
+		{[]string{RoleUser}, PermWrite, false},
+		{[]string{RoleUser}, PermManage, false},
+		{[]string{"unknown"}, PermRead, false},
+		{nil, PermRead, false},
+		// Any role granting the permission is sufficient
+		{[]string{RoleUser, RoleStaff}, PermRead, true},
+		{[]string{RoleStaff, RoleSpecialist}, PermWrite, true},
+	}
+
+	for _, tt := range tests {
+		if got := HasPermission(tt.perm, tt.roles); got != tt.want {
+			t.Errorf("HasPermission(%q, %v) = %v, want %v", tt.perm, tt.roles, got, tt.want)
+		}
+	}
+}
+
+func TestRequiresPermission(t *testing.T) {
+	tests := []struct {
+		roles  []string
+		perm   Permission
+		status int
+	}{
+		{[]string{RoleAdmin}, PermManage, http.StatusOK},
+		{[]string{RoleSpecialist}, PermWrite, http.StatusOK},
+		{[]string{RoleSpecialist}, PermManage, http.StatusForbidden},
+		{[]string{RoleStaff}, PermRead, http.StatusOK},
+		{[]string{RoleStaff}, PermWrite, http.StatusForbidden},
+		{[]string{RoleUser}, PermRead, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		handler := RequiresPermission(tt.perm)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r = r.WithContext(jwt.ContextWithClaims(r.Context(), jwt.AppClaims{ID: 1, Roles: tt.roles}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("RequiresPermission(%q) with roles %v: expected status %d, got %d", tt.perm, tt.roles, tt.status, w.Code)
+		}
+	}
+}
@@ -24,6 +24,11 @@ func ClaimsFromCtx(ctx context.Context) AppClaims {
 	return ctx.Value(ctxClaims).(AppClaims)
 }
 
+// ContextWithClaims returns a copy of ctx carrying the AppClaims.
+func ContextWithClaims(ctx context.Context, c AppClaims) context.Context {
+	return context.WithValue(ctx, ctxClaims, c)
+}
+
 // RefreshTokenFromCtx retrieves the parsed refresh token from context.
 func RefreshTokenFromCtx(ctx context.Context) string {
 	return ctx.Value(ctxRefreshToken).(string)
@@ -57,8 +62,7 @@ func Authenticator(next http.Handler) http.Handler {
 		}
 
 		// Set AppClaims on context
-		ctx := context.WithValue(r.Context(), ctxClaims, c)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), c)))
 	})
 }
 
@@ -13,6 +13,7 @@ var (
 	ErrTokenExpired        = errors.New("token expired")
 	ErrInvalidAccessToken  = errors.New("invalid access token")
 	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrTokenReused         = errors.New("refresh token reused, all sessions revoked")
 )
 
 // ErrResponse renderer type for handling all sorts of errors.
@@ -0,0 +1,141 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/uptrace/bun"
+)
+
+// ErrUnsupportedAlg is returned for signing algorithms without key support.
+var ErrUnsupportedAlg = errors.New("unsupported jwt signing algorithm")
+
+// SigningKey is a private key used to sign JWTs, identified by its key ID.
+// Keys stay valid for verification until ExpiresAt, after they stopped
+// being used for signing.
+type SigningKey struct {
+	KID        string    `bun:"kid,pk"`
+	Alg        string    `bun:"alg,notnull"`
+	PrivateKey []byte    `bun:"private_key,notnull"` // PKCS #8 DER encoded
+	CreatedAt  time.Time `bun:"created_at,notnull"`
+	ExpiresAt  time.Time `bun:"expires_at,notnull"`
+
+	bun.BaseModel `bun:"table:jwt_keys"`
+}
+
+// KeyStore defines operations on stored signing keys.
+type KeyStore interface {
+	ListSigningKeys() ([]SigningKey, error)
+	CreateSigningKey(k *SigningKey) error
+	PurgeExpiredSigningKeys() error
+}
+
+// newSigningKey generates a new private key for alg.
+func newSigningKey(alg string, validFor time.Duration) (*SigningKey, error) {
+	var raw crypto.PrivateKey
+	var err error
+	switch jwa.SignatureAlgorithm(alg) {
+	case jwa.RS256:
+		raw, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwa.ES256:
+		raw, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.EdDSA:
+		_, raw, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &SigningKey{
+		KID:        randStringBytes(16),
+		Alg:        alg,
+		PrivateKey: der,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(validFor),
+	}, nil
+}
+
+// JWK returns the signing key as private JSON Web Key.
+func (k *SigningKey) JWK() (jwk.Key, error) {
+	raw, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.KeyIDKey, k.KID); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.AlgorithmKey, jwa.SignatureAlgorithm(k.Alg)); err != nil {
+		return nil, err
+	}
+	if err := key.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// MemoryKeyStore keeps signing keys in memory of a single instance,
+// meant for tests and development.
+type MemoryKeyStore struct {
+	keys []SigningKey
+	mux  sync.Mutex
+}
+
+// NewMemoryKeyStore returns an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{}
+}
+
+// ListSigningKeys returns all stored keys.
+func (s *MemoryKeyStore) ListSigningKeys() ([]SigningKey, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return append([]SigningKey(nil), s.keys...), nil
+}
+
+// CreateSigningKey stores a new key.
+func (s *MemoryKeyStore) CreateSigningKey(k *SigningKey) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.keys = append(s.keys, *k)
+	return nil
+}
+
+// PurgeExpiredSigningKeys deletes keys past their expiry.
+func (s *MemoryKeyStore) PurgeExpiredSigningKeys() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	keys := s.keys[:0]
+	for _, k := range s.keys {
+		if time.Now().Before(k.ExpiresAt) {
+			keys = append(keys, k)
+		}
+	}
+	s.keys = keys
+	return nil
+}
@@ -6,7 +6,8 @@ import (
 	"github.com/uptrace/bun"
 )
 
-// Token holds refresh jwt information.
+// Token holds refresh jwt information. Each refresh rotates the token into a
+// new Token of the same Family and marks the previous one as rotated.
 type Token struct {
 	ID        int       `bun:"id,pk,autoincrement" json:"id,omitempty"`
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at,omitempty"`
@@ -17,6 +18,15 @@ type Token struct {
 	Expiry     time.Time `bun:"expiry,notnull" json:"-"`
 	Mobile     bool      `bun:"mobile,notnull" json:"mobile"`
 	Identifier string    `bun:"identifier" json:"identifier,omitempty"`
+	UserAgent  string    `bun:"user_agent" json:"-"`
+	IP         string    `bun:"ip" json:"-"`
+	Family     string    `bun:"family,notnull" json:"-"`
+	RotatedAt  time.Time `bun:"rotated_at,nullzero" json:"-"`
+}
+
+// Rotated returns true if the token was already exchanged for a new one.
+func (t *Token) Rotated() bool {
+	return !t.RotatedAt.IsZero()
 }
 
 // BeforeInsert hook executed before database insert operation.
@@ -42,3 +52,31 @@ func (t *Token) Claims() RefreshClaims {
 		Token: t.Token,
 	}
 }
+
+// Session represents an active login session of an account, based on the
+// current refresh token of a token family.
+type Session struct {
+	ID         int       `json:"id"`
+	Device     string    `json:"device"`
+	Mobile     bool      `json:"mobile"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Session returns the login session of the token. CreatedAt is kept on rotation
+// and UpdatedAt is set on every refresh.
+func (t *Token) Session() Session {
+	return Session{
+		ID:         t.ID,
+		Device:     t.Identifier,
+		Mobile:     t.Mobile,
+		UserAgent:  t.UserAgent,
+		IP:         t.IP,
+		CreatedAt:  t.CreatedAt,
+		LastUsedAt: t.UpdatedAt,
+		ExpiresAt:  t.Expiry,
+	}
+}
@@ -4,38 +4,259 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/spf13/viper"
+
+	"github.com/dhax/go-base/logging"
 )
 
+// keyReloadInterval limits reloading of keys when verifying tokens signed with an unknown key,
+// e.g. a key just created by another instance.
+const keyReloadInterval = 10 * time.Second
+
 // TokenAuth implements JWT authentication flow.
+//
+// With HS256 tokens are signed with the shared secret auth_jwt_secret. With the asymmetric
+// algorithms RS256, ES256 and EdDSA keys are kept in a KeyStore and rotated every
+// auth_jwt_key_rotation. A new key is published a tenth of the rotation period before it is
+// used for signing, and old keys remain valid for verification until all tokens signed with
+// them have expired. Public keys are served as JSON Web Key Set by JWKSHandler.
 type TokenAuth struct {
-	JwtAuth          *jwtauth.JWTAuth
 	JwtExpiry        time.Duration
 	JwtRefreshExpiry time.Duration
+
+	alg      jwa.SignatureAlgorithm
+	rotation time.Duration
+	store    KeyStore
+
+	mux        sync.RWMutex
+	signKey    jwk.Key
+	verifyKeys jwk.Set
+	publicKeys jwk.Set
+	loadedAt   time.Time
 }
 
 // NewTokenAuth configures and returns a JWT authentication instance.
-func NewTokenAuth() (*TokenAuth, error) {
-	secret := viper.GetString("auth_jwt_secret")
-	if secret == "random" {
-		secret = randStringBytes(32)
-	}
-
+func NewTokenAuth(store KeyStore) (*TokenAuth, error) {
 	a := &TokenAuth{
-		JwtAuth:          jwtauth.New("HS256", []byte(secret), nil),
 		JwtExpiry:        viper.GetDuration("auth_jwt_expiry"),
 		JwtRefreshExpiry: viper.GetDuration("auth_jwt_refresh_expiry"),
+		alg:              jwa.SignatureAlgorithm(viper.GetString("auth_jwt_alg")),
+		rotation:         viper.GetDuration("auth_jwt_key_rotation"),
+		store:            store,
+	}
+	if a.alg == "" {
+		a.alg = jwa.HS256
+	}
+	if a.rotation <= 0 {
+		a.rotation = 30 * 24 * time.Hour
 	}
 
+	if a.alg == jwa.HS256 {
+		secret := viper.GetString("auth_jwt_secret")
+		if secret == "random" {
+			secret = randStringBytes(32)
+		}
+		key, err := jwk.FromRaw([]byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		if err := key.Set(jwk.AlgorithmKey, jwa.HS256); err != nil {
+			return nil, err
+		}
+		verify := jwk.NewSet()
+		verify.AddKey(key)
+
+		a.signKey = key
+		a.verifyKeys = verify
+		a.publicKeys = jwk.NewSet()
+		return a, nil
+	}
+
+	if err := a.RotateKeys(); err != nil {
+		return nil, err
+	}
+	a.rotationTicker()
+
 	return a, nil
 }
 
+// RotateKeys loads the keys from the store, creates a new key if the current one is due
+// for rotation and purges expired keys.
+func (a *TokenAuth) RotateKeys() error {
+	keys, err := a.store.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	prepublish := a.rotation / 10
+
+	// newest key of the configured algorithm
+	newest := -1
+	for i, k := range keys {
+		if k.Alg != string(a.alg) || now.After(k.ExpiresAt) {
+			continue
+		}
+		if newest < 0 || k.CreatedAt.After(keys[newest].CreatedAt) {
+			newest = i
+		}
+	}
+
+	if newest < 0 || now.Sub(keys[newest].CreatedAt) >= a.rotation-prepublish {
+		k, err := newSigningKey(string(a.alg), a.rotation+prepublish+a.JwtRefreshExpiry)
+		if err != nil {
+			return err
+		}
+		if err := a.store.CreateSigningKey(k); err != nil {
+			return err
+		}
+		keys = append(keys, *k)
+		newest = len(keys) - 1
+		logging.Logger.WithField("kid", k.KID).Info("created jwt signing key")
+	}
+
+	// sign with the newest key already published long enough, or the newest at all on first start
+	sign := -1
+	verify := jwk.NewSet()
+	public := jwk.NewSet()
+	for i, k := range keys {
+		if k.Alg != string(a.alg) || now.After(k.ExpiresAt) {
+			continue
+		}
+		if now.Sub(k.CreatedAt) >= prepublish && (sign < 0 || k.CreatedAt.After(keys[sign].CreatedAt)) {
+			sign = i
+		}
+
+		key, err := k.JWK()
+		if err != nil {
+			return err
+		}
+		pub, err := key.PublicKey()
+		if err != nil {
+			return err
+		}
+		verify.AddKey(key)
+		public.AddKey(pub)
+	}
+	if sign < 0 {
+		sign = newest
+	}
+
+	signKey, err := keys[sign].JWK()
+	if err != nil {
+		return err
+	}
+
+	a.mux.Lock()
+	a.signKey = signKey
+	a.verifyKeys = verify
+	a.publicKeys = public
+	a.loadedAt = now
+	a.mux.Unlock()
+
+	return a.store.PurgeExpiredSigningKeys()
+}
+
+func (a *TokenAuth) rotationTicker() {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		for range ticker.C {
+			if err := a.RotateKeys(); err != nil {
+				logging.Logger.WithField("chore", "rotateJwtKeys").Error(err)
+			}
+		}
+	}()
+}
+
 // Verifier http middleware will verify a jwt string from a http request.
 func (a *TokenAuth) Verifier() func(http.Handler) http.Handler {
-	return jwtauth.Verifier(a.JwtAuth)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := jwtauth.TokenFromHeader(r)
+			if tokenString == "" {
+				tokenString = jwtauth.TokenFromCookie(r)
+			}
+
+			var token jwt.Token
+			err := jwtauth.ErrNoTokenFound
+			if tokenString != "" {
+				token, err = a.Decode(tokenString)
+			}
+
+			ctx := jwtauth.NewContext(r.Context(), token, err)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Decode verifies the signature of a jwt string and returns the parsed token.
+// Claims are validated separately by the Authenticator middlewares.
+func (a *TokenAuth) Decode(tokenString string) (jwt.Token, error) {
+	token, err := a.parse(tokenString)
+	if err != nil && a.alg != jwa.HS256 {
+		a.mux.RLock()
+		reload := time.Since(a.loadedAt) > keyReloadInterval
+		a.mux.RUnlock()
+
+		if reload && a.RotateKeys() == nil {
+			token, err = a.parse(tokenString)
+		}
+	}
+	if err != nil {
+		return nil, jwtauth.ErrorReason(err)
+	}
+	return token, nil
+}
+
+func (a *TokenAuth) parse(tokenString string) (jwt.Token, error) {
+	a.mux.RLock()
+	keys := a.verifyKeys
+	a.mux.RUnlock()
+
+	// tokens without kid issued before key rotation was enabled are checked against all keys
+	return jwt.Parse([]byte(tokenString),
+		jwt.WithKeySet(keys, jws.WithRequireKid(false)),
+		jwt.WithValidate(false),
+	)
+}
+
+// Encode signs the claims with the current signing key and returns the jwt string.
+func (a *TokenAuth) Encode(claims map[string]any) (string, error) {
+	t := jwt.New()
+	for k, v := range claims {
+		if err := t.Set(k, v); err != nil {
+			return "", err
+		}
+	}
+
+	a.mux.RLock()
+	key := a.signKey
+	a.mux.RUnlock()
+
+	signed, err := jwt.Sign(t, jwt.WithKey(a.alg, key))
+	if err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+// JWKSHandler serves the public keys to verify access tokens as JSON Web Key Set.
+func (a *TokenAuth) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	a.mux.RLock()
+	keys := a.publicKeys
+	a.mux.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keys)
 }
 
 // GenTokenPair returns both an access token and a refresh token.
@@ -61,8 +282,7 @@ func (a *TokenAuth) CreateJWT(c AppClaims) (string, error) {
 		return "", err
 	}
 
-	_, tokenString, err := a.JwtAuth.Encode(claims)
-	return tokenString, err
+	return a.Encode(claims)
 }
 
 func ParseStructToMap(c any) (map[string]any, error) {
@@ -86,8 +306,7 @@ func (a *TokenAuth) CreateRefreshJWT(c RefreshClaims) (string, error) {
 		return "", err
 	}
 
-	_, tokenString, err := a.JwtAuth.Encode(claims)
-	return tokenString, err
+	return a.Encode(claims)
 }
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -0,0 +1,161 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/spf13/viper"
+
+	"github.com/dhax/go-base/logging"
+)
+
+func TestMain(m *testing.M) {
+	viper.SetDefault("log_level", "error")
+	logging.NewLogger()
+
+	os.Exit(m.Run())
+}
+
+func newTestTokenAuth(t *testing.T, alg string, store KeyStore) *TokenAuth {
+	viper.Set("auth_jwt_alg", alg)
+	viper.Set("auth_jwt_key_rotation", "720h")
+	viper.Set("auth_jwt_expiry", "15m")
+	viper.Set("auth_jwt_refresh_expiry", "1h")
+
+	a, err := NewTokenAuth(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// authenticate runs token through Verifier and Authenticator and returns the response code.
+func authenticate(a *TokenAuth, token string) int {
+	h := a.Verifier()(Authenticator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestTokenAuth_asymmetric(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			a := newTestTokenAuth(t, alg, NewMemoryKeyStore())
+
+			token, err := a.CreateJWT(AppClaims{ID: 1, Sub: "test", Roles: []string{"user"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := authenticate(a, token); code != http.StatusOK {
+				t.Errorf("expected %d, got %d", http.StatusOK, code)
+			}
+
+			// the kid of the token must be published in the JWKS without private parts
+			msg, err := jws.Parse([]byte(token))
+			if err != nil {
+				t.Fatal(err)
+			}
+			kid := msg.Signatures()[0].ProtectedHeaders().KeyID()
+
+			w := httptest.NewRecorder()
+			a.JWKSHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+			set, err := jwk.Parse(w.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, ok := set.LookupKeyID(kid)
+			if !ok {
+				t.Fatalf("kid %q not found in jwks", kid)
+			}
+			if private, _ := jwk.IsPrivateKey(key); private {
+				t.Error("jwks contains private key")
+			}
+			if _, err := jws.Verify([]byte(token), jws.WithKeySet(set)); err != nil {
+				t.Errorf("token not verifiable with jwks: %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenAuth_rotation(t *testing.T) {
+	store := NewMemoryKeyStore()
+	a := newTestTokenAuth(t, "ES256", store)
+
+	old, err := a.CreateJWT(AppClaims{ID: 1, Sub: "test", Roles: []string{"user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// age the current key until it is due for rotation
+	store.keys[0].CreatedAt = time.Now().Add(-700 * time.Hour)
+	if err := a.RotateKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.keys) != 2 {
+		t.Fatalf("expected new key to be published, got %d keys", len(store.keys))
+	}
+
+	// the new key is published but not used for signing yet
+	token, _ := a.CreateJWT(AppClaims{ID: 1, Sub: "test", Roles: []string{"user"}})
+	if kid := tokenKID(t, token); kid != store.keys[0].KID {
+		t.Errorf("expected signing with previous key %q, got %q", store.keys[0].KID, kid)
+	}
+
+	// after the publishing period the new key signs, tokens of the previous key stay valid
+	store.keys[0].CreatedAt = time.Now().Add(-720 * time.Hour)
+	store.keys[1].CreatedAt = time.Now().Add(-80 * time.Hour)
+	if err := a.RotateKeys(); err != nil {
+		t.Fatal(err)
+	}
+	token, _ = a.CreateJWT(AppClaims{ID: 1, Sub: "test", Roles: []string{"user"}})
+	if kid := tokenKID(t, token); kid != store.keys[1].KID {
+		t.Errorf("expected signing with new key %q, got %q", store.keys[1].KID, kid)
+	}
+	if code := authenticate(a, old); code != http.StatusOK {
+		t.Errorf("expected token of previous key to be valid, got %d", code)
+	}
+
+	// expired keys are purged and no longer verify
+	store.keys[0].ExpiresAt = time.Now().Add(-time.Minute)
+	if err := a.RotateKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if code := authenticate(a, old); code != http.StatusUnauthorized {
+		t.Errorf("expected token of expired key to be rejected, got %d", code)
+	}
+}
+
+func TestTokenAuth_sharedStore(t *testing.T) {
+	store := NewMemoryKeyStore()
+	a := newTestTokenAuth(t, "EdDSA", store)
+	b := newTestTokenAuth(t, "EdDSA", store)
+
+	token, _ := a.CreateJWT(AppClaims{ID: 1, Sub: "test", Roles: []string{"user"}})
+	if code := authenticate(b, token); code != http.StatusOK {
+		t.Errorf("expected token to be valid on other instance, got %d", code)
+	}
+
+	var body map[string][]any
+	w := httptest.NewRecorder()
+	b.JWKSHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	json.NewDecoder(w.Body).Decode(&body)
+	if len(body["keys"]) != 1 {
+		t.Errorf("expected one shared key, got %d", len(body["keys"]))
+	}
+}
+
+func tokenKID(t *testing.T, token string) string {
+	msg, err := jws.Parse([]byte(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg.Signatures()[0].ProtectedHeaders().KeyID()
+}
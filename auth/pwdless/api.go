@@ -3,9 +3,13 @@
 package pwdless
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +23,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/mssola/user_agent"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 // AuthStorer defines database operations on accounts and tokens.
@@ -30,6 +35,8 @@ type AuthStorer interface {
 	GetToken(token string) (*jwt.Token, error)
 	CreateOrUpdateToken(t *jwt.Token) error
 	DeleteToken(t *jwt.Token) error
+	MarkTokenRotated(t *jwt.Token) error
+	DeleteTokenFamily(accountID int, family string) error
 	PurgeExpiredToken() error
 }
 
@@ -39,25 +46,36 @@ type Resource struct {
 	TokenAuth *jwt.TokenAuth
 	Store     AuthStorer
 	Mailer    email.Mailer
+	Limiter   *RateLimiter
+	Audit     AuditStore
+	// TrustedProxies may set X-Forwarded-For to the address of the client.
+	TrustedProxies []*net.IPNet
 }
 
 // NewResource returns a configured authentication resource.
-func NewResource(authStore AuthStorer, mailer email.Mailer) (*Resource, error) {
-	loginAuth, err := NewLoginTokenAuth()
+func NewResource(authStore AuthStorer, loginTokenStore LoginTokenStore, keyStore jwt.KeyStore, mailer email.Mailer) (*Resource, error) {
+	loginAuth, err := NewLoginTokenAuth(loginTokenStore)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenAuth, err := jwt.NewTokenAuth()
+	tokenAuth, err := jwt.NewTokenAuth(keyStore)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies(viper.GetString("auth_trusted_proxies"))
 	if err != nil {
 		return nil, err
 	}
 
 	resource := &Resource{
-		LoginAuth: loginAuth,
-		TokenAuth: tokenAuth,
-		Store:     authStore,
-		Mailer:    mailer,
+		LoginAuth:      loginAuth,
+		TokenAuth:      tokenAuth,
+		Store:          authStore,
+		Mailer:         mailer,
+		Limiter:        NewRateLimiter(NewMemoryRateLimitStore()),
+		TrustedProxies: trustedProxies,
 	}
 
 	resource.choresTicker()
@@ -98,16 +116,35 @@ func (body *loginRequest) Bind(r *http.Request) error {
 }
 
 func (rs *Resource) login(w http.ResponseWriter, r *http.Request) {
+	ipKey := limitLoginIP + rs.clientIP(r)
+	if rs.throttled(w, r, ipKey) {
+		return
+	}
+
 	body := &loginRequest{}
 	if err := render.Bind(r, body); err != nil {
 		log(r).WithField("email", body.Email).Warn(err)
+		rs.failed(r, ipKey, rs.Limiter.ipAttempts)
 		render.Render(w, r, ErrUnauthorized(ErrInvalidLogin))
 		return
 	}
 
+	emailKey := limitLoginEmail + body.Email
+	if rs.throttled(w, r, emailKey) {
+		return
+	}
+
+	// login mails are limited separately to prevent flooding an inbox, without
+	// locking the account. Checked for any email so as not to reveal accounts.
+	if rs.mailThrottled(w, r, limitLoginMail+body.Email) {
+		return
+	}
+
 	acc, err := rs.Store.GetAccountByEmail(body.Email)
 	if err != nil {
 		log(r).WithField("email", body.Email).Warn(err)
+		rs.failed(r, emailKey, rs.Limiter.emailAttempts)
+		rs.failed(r, ipKey, rs.Limiter.ipAttempts)
 		render.Render(w, r, ErrUnauthorized(ErrUnknownLogin))
 		return
 	}
@@ -117,7 +154,12 @@ func (rs *Resource) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lt := rs.LoginAuth.CreateToken(acc.ID)
+	lt, err := rs.LoginAuth.CreateToken(acc.ID)
+	if err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
 	tokenURL, _ := url.JoinPath(rs.LoginAuth.loginURL, lt.Token)
 
 	go func() {
@@ -157,15 +199,28 @@ func (body *tokenRequest) Bind(r *http.Request) error {
 }
 
 func (rs *Resource) token(w http.ResponseWriter, r *http.Request) {
+	ipKey := limitTokenIP + rs.clientIP(r)
+	if rs.throttled(w, r, ipKey) {
+		return
+	}
+
 	body := &tokenRequest{}
 	if err := render.Bind(r, body); err != nil {
 		log(r).Warn(err)
+		rs.failed(r, ipKey, rs.Limiter.ipAttempts)
 		render.Render(w, r, ErrUnauthorized(ErrLoginToken))
 		return
 	}
 
 	id, err := rs.LoginAuth.GetAccountID(body.Token)
 	if err != nil {
+		rs.failed(r, ipKey, rs.Limiter.ipAttempts)
+		// an expired or reused token counts against the email of its account
+		if id != 0 {
+			if acc, err := rs.Store.GetAccount(id); err == nil {
+				rs.failed(r, limitLoginEmail+strings.ToLower(acc.Email), rs.Limiter.emailAttempts)
+			}
+		}
 		render.Render(w, r, ErrUnauthorized(ErrLoginToken))
 		return
 	}
@@ -192,6 +247,9 @@ func (rs *Resource) token(w http.ResponseWriter, r *http.Request) {
 		AccountID:  acc.ID,
 		Mobile:     ua.Mobile(),
 		Identifier: fmt.Sprintf("%s on %s", browser, ua.OS()),
+		UserAgent:  r.UserAgent(),
+		IP:         rs.clientIP(r),
+		Family:     uuid.Must(uuid.NewV4()).String(),
 	}
 
 	if err := rs.Store.CreateOrUpdateToken(token); err != nil {
@@ -220,6 +278,57 @@ func (rs *Resource) token(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// throttled renders 429 Too Many Requests with a Retry-After header if key is locked out.
+func (rs *Resource) throttled(w http.ResponseWriter, r *http.Request, key string) bool {
+	d, err := rs.Limiter.LockedFor(key)
+	if err != nil {
+		log(r).WithField("module", "ratelimit").Error(err)
+		return false
+	}
+	if d <= 0 {
+		return false
+	}
+	tooManyRequests(w, r, d)
+	return true
+}
+
+// mailThrottled renders 429 Too Many Requests with a Retry-After header if the login
+// mails for key reached their limit within the window. It neither locks out nor audits.
+func (rs *Resource) mailThrottled(w http.ResponseWriter, r *http.Request, key string) bool {
+	d, err := rs.Limiter.Take(key, rs.Limiter.mailAttempts)
+	if err != nil {
+		log(r).WithField("module", "ratelimit").Error(err)
+		return false
+	}
+	if d <= 0 {
+		return false
+	}
+	tooManyRequests(w, r, d)
+	return true
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	render.Render(w, r, ErrTooManyRequests)
+}
+
+// failed records a failed attempt for key and audits a resulting lockout.
+func (rs *Resource) failed(r *http.Request, key string, maxAttempts int) {
+	lockout, err := rs.Limiter.Fail(key, maxAttempts)
+	if err != nil {
+		log(r).WithField("module", "ratelimit").Error(err)
+		return
+	}
+	if lockout > 0 {
+		rs.audit(r, &AuditEvent{
+			Event:   AuditLockout,
+			Key:     key,
+			IP:      rs.clientIP(r),
+			Details: fmt.Sprintf("locked out for %s", lockout),
+		})
+	}
+}
+
 func (rs *Resource) refresh(w http.ResponseWriter, r *http.Request) {
 	rt := jwt.RefreshTokenFromCtx(r.Context())
 
@@ -229,6 +338,13 @@ func (rs *Resource) refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a rotated token presented again has been stolen from either the client or the attacker
+	if token.Rotated() {
+		rs.revokeTokenFamily(r, token)
+		render.Render(w, r, ErrUnauthorized(jwt.ErrTokenReused))
+		return
+	}
+
 	if time.Now().After(token.Expiry) {
 		rs.Store.DeleteToken(token)
 		render.Render(w, r, ErrUnauthorized(jwt.ErrTokenExpired))
@@ -246,18 +362,39 @@ func (rs *Resource) refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token.Token = uuid.Must(uuid.NewV4()).String()
-	token.Expiry = time.Now().Add(rs.TokenAuth.JwtRefreshExpiry)
-	token.UpdatedAt = time.Now()
+	// concurrent refresh with the same token loses the race and counts as reuse
+	if err := rs.Store.MarkTokenRotated(token); err != nil {
+		if errors.Is(err, jwt.ErrTokenReused) {
+			rs.revokeTokenFamily(r, token)
+			render.Render(w, r, ErrUnauthorized(jwt.ErrTokenReused))
+			return
+		}
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
 
-	access, refresh, err := rs.TokenAuth.GenTokenPair(acc.Claims(), token.Claims())
-	if err != nil {
+	next := &jwt.Token{
+		CreatedAt:  token.CreatedAt,
+		UpdatedAt:  time.Now(),
+		AccountID:  token.AccountID,
+		Token:      uuid.Must(uuid.NewV4()).String(),
+		Expiry:     time.Now().Add(rs.TokenAuth.JwtRefreshExpiry),
+		Mobile:     token.Mobile,
+		Identifier: token.Identifier,
+		UserAgent:  r.UserAgent(),
+		IP:         rs.clientIP(r),
+		Family:     token.Family,
+	}
+
+	if err := rs.Store.CreateOrUpdateToken(next); err != nil {
 		log(r).Error(err)
 		render.Render(w, r, ErrInternalServerError)
 		return
 	}
 
-	if err := rs.Store.CreateOrUpdateToken(token); err != nil {
+	access, refresh, err := rs.TokenAuth.GenTokenPair(acc.Claims(), next.Claims())
+	if err != nil {
 		log(r).Error(err)
 		render.Render(w, r, ErrInternalServerError)
 		return
@@ -276,6 +413,43 @@ func (rs *Resource) refresh(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// revokeTokenFamily deletes all refresh tokens of the family of a reused token
+// and notifies the account owner.
+func (rs *Resource) revokeTokenFamily(r *http.Request, token *jwt.Token) {
+	if err := rs.Store.DeleteTokenFamily(token.AccountID, token.Family); err != nil {
+		log(r).Error(err)
+	}
+
+	rs.audit(r, &AuditEvent{
+		Event:   AuditTokenReuse,
+		Key:     auditAccount + strconv.Itoa(token.AccountID),
+		IP:      rs.clientIP(r),
+		Details: fmt.Sprintf("refresh token of %q reused, session revoked", token.Identifier),
+	})
+
+	acc, err := rs.Store.GetAccount(token.AccountID)
+	if err != nil {
+		log(r).Error(err)
+		return
+	}
+
+	go func() {
+		content := ContentTokenReuse{
+			Email:      acc.Email,
+			Name:       acc.Name,
+			Identifier: token.Identifier,
+			IP:         rs.clientIP(r),
+			Time:       time.Now(),
+		}
+
+		msg := TokenReuseEmail(acc.Name, acc.Email, content)
+
+		if err := rs.Mailer.Send(msg); err != nil {
+			log(r).WithField("module", "email").Error(err)
+		}
+	}()
+}
+
 func (rs *Resource) logout(w http.ResponseWriter, r *http.Request) {
 	rt := jwt.RefreshTokenFromCtx(r.Context())
 	token, err := rs.Store.GetToken(rt)
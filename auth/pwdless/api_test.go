@@ -32,12 +32,19 @@ func TestMain(m *testing.M) {
 	viper.SetDefault("auth_login_token_length", 8)
 	viper.SetDefault("auth_login_token_expiry", "11m")
 	viper.SetDefault("auth_jwt_secret", "random")
+	viper.SetDefault("auth_rate_limit_email_attempts", 5)
+	viper.SetDefault("auth_rate_limit_mail_attempts", 5)
+	viper.SetDefault("auth_rate_limit_ip_attempts", 20)
+	viper.SetDefault("auth_rate_limit_window", "15m")
+	viper.SetDefault("auth_rate_limit_lockout", "1m")
+	viper.SetDefault("auth_rate_limit_backoff", 2)
+	viper.SetDefault("auth_rate_limit_max_lockout", "24h")
 	viper.SetDefault("log_level", "error")
 
 	var err error
 
 	mailer = email.NewMockMailer()
-	auth, err = NewResource(&authStore, mailer)
+	auth, err = NewResource(&authStore, NewMemoryLoginTokenStore(), jwt.NewMemoryKeyStore(), mailer)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -154,7 +161,10 @@ func TestAuthResource_token(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			token := auth.LoginAuth.CreateToken(tc.id)
+			token, err := auth.LoginAuth.CreateToken(tc.id)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if tc.token != "" {
 				token.Token = tc.token
 			}
@@ -220,6 +230,9 @@ func TestAuthResource_refresh(t *testing.T) {
 	authStore.DeleteTokenFn = func(t *jwt.Token) error {
 		return nil
 	}
+	authStore.MarkTokenRotatedFn = func(t *jwt.Token) error {
+		return nil
+	}
 
 	tests := []struct {
 		name   string
@@ -275,6 +288,99 @@ func TestAuthResource_refresh(t *testing.T) {
 	}
 }
 
+func TestAuthResource_refreshReuse(t *testing.T) {
+	authStore.GetAccountFn = func(id int) (*Account, error) {
+		return &Account{ID: id, Active: true, Name: "Test", Email: "test@example.com"}, nil
+	}
+	authStore.UpdateAccountFn = func(a *Account) error {
+		return nil
+	}
+	authStore.GetTokenFn = func(token string) (*jwt.Token, error) {
+		t := jwt.Token{
+			AccountID: 1,
+			Token:     token,
+			Expiry:    time.Now().Add(time.Minute),
+			Family:    "family",
+		}
+		if token == "rotated" {
+			t.RotatedAt = time.Now().Add(-time.Second)
+		}
+		return &t, nil
+	}
+	authStore.CreateOrUpdateTokenFn = func(t *jwt.Token) error {
+		return nil
+	}
+	authStore.MarkTokenRotatedFn = func(t *jwt.Token) error {
+		if t.Token == "race" {
+			return jwt.ErrTokenReused
+		}
+		return nil
+	}
+
+	var family string
+	authStore.DeleteTokenFamilyFn = func(accountID int, f string) error {
+		family = f
+		return nil
+	}
+
+	sent := make(chan email.Message, 1)
+	sendFn := mailer.SendFn
+	mailer.SendFn = func(m email.Message) error {
+		sent <- m
+		return nil
+	}
+	defer func() { mailer.SendFn = sendFn }()
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		revoke bool
+	}{
+		{"valid", "valid", http.StatusOK, false},
+		{"rotated", "rotated", http.StatusUnauthorized, true},
+		{"race", "race", http.StatusUnauthorized, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			refreshJWT := genRefreshJWT(jwt.RefreshClaims{
+				Token: tc.token,
+				CommonClaims: jwt.CommonClaims{
+					ExpiresAt: time.Now().Add(time.Minute).Unix(),
+				},
+			})
+
+			res, body := testRequest(t, ts, "POST", "/refresh", nil, refreshJWT)
+			if res.StatusCode != tc.status {
+				t.Errorf("got http status %d, want: %d", res.StatusCode, tc.status)
+			}
+			if authStore.DeleteTokenFamilyInvoked != tc.revoke {
+				t.Errorf("DeleteTokenFamily invoked: %v, want: %v", authStore.DeleteTokenFamilyInvoked, tc.revoke)
+			}
+
+			if tc.revoke {
+				if !strings.Contains(body, jwt.ErrTokenReused.Error()) {
+					t.Errorf("got: %s, expected error to contain: %s", body, jwt.ErrTokenReused.Error())
+				}
+				if family != "family" {
+					t.Errorf("got revoked family %q, want: %q", family, "family")
+				}
+				select {
+				case m := <-sent:
+					if m.Template != "tokenReuse" || m.To.Address != "test@example.com" {
+						t.Errorf("unexpected notification %s to %s", m.Template, m.To.Address)
+					}
+				case <-time.After(time.Second):
+					t.Error("reuse notification not sent")
+				}
+			}
+			authStore.DeleteTokenFamilyInvoked = false
+			family = ""
+		})
+	}
+}
+
 func TestAuthResource_logout(t *testing.T) {
 	authStore.GetTokenFn = func(token string) (*jwt.Token, error) {
 		var err error
@@ -365,7 +471,7 @@ func testRequest(t *testing.T, ts *httptest.Server, method, path string, body io
 func genRefreshJWT(c jwt.RefreshClaims) string {
 	claims, _ := jwt.ParseStructToMap(c)
 
-	_, tokenString, _ := auth.TokenAuth.JwtAuth.Encode(claims)
+	tokenString, _ := auth.TokenAuth.Encode(claims)
 	return tokenString
 }
 
@@ -374,3 +480,208 @@ func encode(v any) (*bytes.Buffer, error) {
 	err := json.NewEncoder(data).Encode(v)
 	return data, err
 }
+
+func TestLoginTokenAuth_singleUse(t *testing.T) {
+	store := NewMemoryLoginTokenStore()
+	la, err := NewLoginTokenAuth(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lt, err := la.CreateToken(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.token[lt.Token]; ok {
+		t.Error("login token stored in plain text")
+	}
+
+	id, err := la.GetAccountID(lt.Token)
+	if err != nil || id != 42 {
+		t.Errorf("got id %d, err %v, want: 42", id, err)
+	}
+
+	if _, err := la.GetAccountID(lt.Token); err != errTokenNotFound {
+		t.Errorf("got err %v on second attempt, want: %v", err, errTokenNotFound)
+	}
+}
+
+func TestAuthResource_loginLockout(t *testing.T) {
+	authStore.GetAccountByEmailFn = func(email string) (*Account, error) {
+		return nil, errors.New("sql no row")
+	}
+	defer func() {
+		auth.Limiter = NewRateLimiter(NewMemoryRateLimitStore())
+	}()
+
+	login := func() (*http.Response, string) {
+		req, err := encode(&loginRequest{Email: "unknown@account.io"})
+		if err != nil {
+			t.Fatal("failed to encode request body")
+		}
+		return testRequest(t, ts, "POST", "/login", req, "")
+	}
+
+	// the attempt reaching the limit still passes, further attempts are locked out
+	for i := 0; i < auth.Limiter.emailAttempts; i++ {
+		if res, _ := login(); res.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: got http status %d, want: %d", i+1, res.StatusCode, http.StatusUnauthorized)
+		}
+	}
+
+	res, body := login()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got http status %d, want: %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After header not set")
+	}
+	if !strings.Contains(body, ErrLockedOut.Error()) {
+		t.Errorf("got: %s, expected to contain: %s", body, ErrLockedOut.Error())
+	}
+}
+
+func TestAuthResource_loginMailLimit(t *testing.T) {
+	authStore.GetAccountByEmailFn = func(email string) (*Account, error) {
+		return &Account{ID: 1, Email: email, Name: "test", Active: true}, nil
+	}
+	mailer.SendFn = func(m email.Message) error {
+		return nil
+	}
+	defer func() {
+		auth.Limiter = NewRateLimiter(NewMemoryRateLimitStore())
+	}()
+
+	login := func() *http.Response {
+		req, err := encode(&loginRequest{Email: "flood@account.io"})
+		if err != nil {
+			t.Fatal("failed to encode request body")
+		}
+		res, _ := testRequest(t, ts, "POST", "/login", req, "")
+		return res
+	}
+
+	for i := 0; i < auth.Limiter.mailAttempts; i++ {
+		if res := login(); res.StatusCode != http.StatusOK {
+			t.Fatalf("attempt %d: got http status %d, want: %d", i+1, res.StatusCode, http.StatusOK)
+		}
+	}
+
+	res := login()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got http status %d, want: %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("Retry-After header not set")
+	}
+
+	// valid logins do not lock out the account, a mailed token can still be used
+	if d, _ := auth.Limiter.LockedFor(limitLoginEmail + "flood@account.io"); d > 0 {
+		t.Errorf("email locked out for %s by valid logins", d)
+	}
+}
+
+func TestAuthResource_tokenReuseCountsAgainstEmail(t *testing.T) {
+	authStore.GetAccountFn = func(id int) (*Account, error) {
+		return &Account{ID: id, Email: "reuse@account.io", Name: "test", Active: true}, nil
+	}
+	authStore.UpdateAccountFn = func(a *Account) error {
+		return nil
+	}
+	authStore.CreateOrUpdateTokenFn = func(a *jwt.Token) error {
+		return nil
+	}
+	defer func() {
+		auth.Limiter = NewRateLimiter(NewMemoryRateLimitStore())
+	}()
+
+	lt, err := auth.LoginAuth.CreateToken(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := []int{http.StatusOK, http.StatusUnauthorized}
+	for i, status := range statuses {
+		req, err := encode(tokenRequest{Token: lt.Token})
+		if err != nil {
+			t.Fatal("failed to encode request body")
+		}
+		if res, _ := testRequest(t, ts, "POST", "/token", req, ""); res.StatusCode != status {
+			t.Errorf("attempt %d: got http status %d, want: %d", i+1, res.StatusCode, status)
+		}
+	}
+
+	s, err := auth.Limiter.store.GetRateLimit(limitLoginEmail + "reuse@account.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Failures != 1 {
+		t.Errorf("got %d failures of email, want: 1", s.Failures)
+	}
+}
+
+func TestAuthResource_clientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &Resource{TrustedProxies: proxies}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor string
+		want          string
+	}{
+		{"direct", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"untrusted proxy", "203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1234", "198.51.100.7", "198.51.100.7"},
+		{"proxy chain", "10.1.2.3:1234", "198.51.100.7, 192.168.1.1", "198.51.100.7"},
+		{"forged", "10.1.2.3:1234", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"malformed", "10.1.2.3:1234", "foo", "10.1.2.3"},
+		{"missing header", "10.1.2.3:1234", "", "10.1.2.3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/login", nil)
+			r.RemoteAddr = tc.remoteAddr
+			if tc.xForwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tc.xForwardedFor)
+			}
+			if got := rs.clientIP(r); got != tc.want {
+				t.Errorf("got %s, want: %s", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := parseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("invalid trusted proxy accepted")
+	}
+}
+
+func TestRateLimiter_backoff(t *testing.T) {
+	l := NewRateLimiter(NewMemoryRateLimitStore())
+
+	var lockouts []time.Duration
+	for i := 0; i < 3*l.emailAttempts; i++ {
+		d, err := l.Fail("key", l.emailAttempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d > 0 {
+			lockouts = append(lockouts, d)
+		}
+	}
+
+	if len(lockouts) != 3 {
+		t.Fatalf("got %d lockouts, want: 3", len(lockouts))
+	}
+	if lockouts[1] != 2*lockouts[0] || lockouts[2] != 2*lockouts[1] {
+		t.Errorf("lockouts not backing off: %v", lockouts)
+	}
+	if d, _ := l.LockedFor("key"); d <= 0 {
+		t.Error("key not locked")
+	}
+}
@@ -0,0 +1,52 @@
+package pwdless
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+)
+
+// Audit event types of the authentication flow.
+const (
+	AuditLockout    = "lockout"
+	AuditTokenReuse = "token_reuse"
+)
+
+// auditAccount prefixes the key of audit events of an account.
+const auditAccount = "account:"
+
+// AuditEvent is a security relevant event of the authentication flow.
+type AuditEvent struct {
+	ID        int       `bun:"id,pk,autoincrement" json:"id"`
+	Event     string    `bun:"event,notnull" json:"event"`
+	Key       string    `bun:"key" json:"key,omitempty"`
+	IP        string    `bun:"ip" json:"ip,omitempty"`
+	Details   string    `bun:"details" json:"details,omitempty"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+
+	bun.BaseModel `bun:"table:auth_audit_log"`
+}
+
+// AuditStore persists audit events.
+type AuditStore interface {
+	CreateAuditEvent(e *AuditEvent) error
+}
+
+// audit writes the event to the log and, if configured, to the audit store.
+func (rs *Resource) audit(r *http.Request, e *AuditEvent) {
+	log(r).WithFields(logrus.Fields{
+		"audit":   e.Event,
+		"key":     e.Key,
+		"ip":      e.IP,
+		"details": e.Details,
+	}).Warn("authentication audit event")
+
+	if rs.Audit == nil {
+		return
+	}
+	if err := rs.Audit.CreateAuditEvent(e); err != nil {
+		log(r).WithField("module", "audit").Error(err)
+	}
+}
@@ -13,6 +13,12 @@ func (rs *Resource) choresTicker() {
 			if err := rs.Store.PurgeExpiredToken(); err != nil {
 				logging.Logger.WithField("chore", "purgeExpiredToken").Error(err)
 			}
+			if err := rs.LoginAuth.PurgeExpired(); err != nil {
+				logging.Logger.WithField("chore", "purgeExpiredLoginToken").Error(err)
+			}
+			if err := rs.Limiter.Purge(); err != nil {
+				logging.Logger.WithField("chore", "purgeRateLimits").Error(err)
+			}
 		}
 	}()
 }
@@ -26,3 +26,23 @@ func LoginTokenEmail(name, address string, content ContentLoginToken) email.Mess
 		Content:  content,
 	}
 }
+
+// ContentTokenReuse defines content for refresh token reuse email template.
+type ContentTokenReuse struct {
+	Email      string
+	Name       string
+	Identifier string
+	IP         string
+	Time       time.Time
+}
+
+// TokenReuseEmail creates an email notifying about a revoked session after refresh token reuse.
+func TokenReuseEmail(name, address string, content ContentTokenReuse) email.Message {
+	return email.Message{
+		From:     email.NewEmail(os.Getenv("EMAIL_FROM_NAME"), os.Getenv("EMAIL_FROM_ADDRESS")),
+		To:       email.NewEmail(name, address),
+		Subject:  "Session Revoked",
+		Template: "tokenReuse",
+		Content:  content,
+	}
+}
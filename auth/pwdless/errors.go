@@ -13,6 +13,7 @@ var (
 	ErrUnknownLogin  = errors.New("email not registered")
 	ErrLoginDisabled = errors.New("login for account disabled")
 	ErrLoginToken    = errors.New("invalid or expired login token")
+	ErrLockedOut     = errors.New("too many attempts, try again later")
 )
 
 // ErrResponse renderer type for handling all sorts of errors.
@@ -47,4 +48,17 @@ var (
 		HTTPStatusCode: http.StatusInternalServerError,
 		StatusText:     http.StatusText(http.StatusInternalServerError),
 	}
+	ErrBadRequest = &ErrResponse{
+		HTTPStatusCode: http.StatusBadRequest,
+		StatusText:     http.StatusText(http.StatusBadRequest),
+	}
+	ErrNotFound = &ErrResponse{
+		HTTPStatusCode: http.StatusNotFound,
+		StatusText:     http.StatusText(http.StatusNotFound),
+	}
+	ErrTooManyRequests = &ErrResponse{
+		HTTPStatusCode: http.StatusTooManyRequests,
+		StatusText:     http.StatusText(http.StatusTooManyRequests),
+		ErrorText:      ErrLockedOut.Error(),
+	}
 )
@@ -2,35 +2,54 @@ package pwdless
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"sync"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/uptrace/bun"
 )
 
 var errTokenNotFound = errors.New("login token not found")
 
-// LoginToken is an in-memory saved token referencing an account ID and an expiry date.
+// LoginToken is a temporary token referencing an account ID and an expiry date.
+// Only the hash of the token is persisted, the plain token is sent to the user.
 type LoginToken struct {
-	Token     string
-	AccountID int
-	Expiry    time.Time
+	Token     string    `bun:"-"`
+	TokenHash string    `bun:"token_hash,pk"`
+	AccountID int       `bun:"account_id,notnull"`
+	Expiry    time.Time `bun:"expiry,notnull"`
+	Attempts  int       `bun:"attempts,notnull"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+
+	bun.BaseModel `bun:"table:login_tokens"`
+}
+
+// LoginTokenStore defines operations on stored login tokens.
+type LoginTokenStore interface {
+	// CreateLoginToken stores a new login token.
+	CreateLoginToken(lt *LoginToken) error
+	// RedeemLoginToken increments the attempt counter of the token with the given hash
+	// and returns the token with the updated counter.
+	RedeemLoginToken(hash string) (*LoginToken, error)
+	// PurgeExpiredLoginTokens deletes expired login tokens.
+	PurgeExpiredLoginTokens() error
 }
 
-// LoginTokenAuth implements passwordless login authentication flow using temporary in-memory stored tokens.
+// LoginTokenAuth implements passwordless login authentication flow using temporary login tokens.
 type LoginTokenAuth struct {
-	token            map[string]LoginToken
-	mux              sync.RWMutex
+	store            LoginTokenStore
 	loginURL         string
 	loginTokenLength int
 	loginTokenExpiry time.Duration
 }
 
 // NewLoginTokenAuth configures and returns a LoginToken authentication instance.
-func NewLoginTokenAuth() (*LoginTokenAuth, error) {
+func NewLoginTokenAuth(store LoginTokenStore) (*LoginTokenAuth, error) {
 	a := &LoginTokenAuth{
-		token:            make(map[string]LoginToken),
+		store:            store,
 		loginURL:         viper.GetString("auth_login_url"),
 		loginTokenLength: viper.GetInt("auth_login_token_length"),
 		loginTokenExpiry: viper.GetDuration("auth_login_token_expiry"),
@@ -38,53 +57,96 @@ func NewLoginTokenAuth() (*LoginTokenAuth, error) {
 	return a, nil
 }
 
-// CreateToken creates an in-memory login token referencing account ID. It returns a token containing a random tokenstring and expiry date.
-func (a *LoginTokenAuth) CreateToken(id int) LoginToken {
+// CreateToken creates a login token referencing account ID. It returns a token containing a random tokenstring and expiry date.
+func (a *LoginTokenAuth) CreateToken(id int) (LoginToken, error) {
+	token := randStringBytes(a.loginTokenLength)
 	lt := LoginToken{
-		Token:     randStringBytes(a.loginTokenLength),
+		Token:     token,
+		TokenHash: hashLoginToken(token),
 		AccountID: id,
 		Expiry:    time.Now().Add(a.loginTokenExpiry),
 	}
-	a.add(lt)
-	a.purgeExpired()
-	return lt
+	if err := a.store.CreateLoginToken(&lt); err != nil {
+		return LoginToken{}, err
+	}
+	return lt, nil
 }
 
 // GetAccountID looks up the token by tokenstring and returns the account ID or error if token not found or expired.
+// A token can only be redeemed once, any further attempt is rejected. For an expired or reused token the
+// account ID is returned along with the error.
 func (a *LoginTokenAuth) GetAccountID(token string) (int, error) {
-	lt, exists := a.get(token)
-	if !exists || time.Now().After(lt.Expiry) {
+	lt, err := a.store.RedeemLoginToken(hashLoginToken(token))
+	if err != nil {
 		return 0, errTokenNotFound
 	}
-	a.delete(lt.Token)
+	if lt.Attempts > 1 || time.Now().After(lt.Expiry) {
+		return lt.AccountID, errTokenNotFound
+	}
 	return lt.AccountID, nil
 }
 
-func (a *LoginTokenAuth) get(token string) (LoginToken, bool) {
-	a.mux.RLock()
-	lt, ok := a.token[token]
-	a.mux.RUnlock()
-	return lt, ok
+// PurgeExpired deletes expired login tokens from the store.
+func (a *LoginTokenAuth) PurgeExpired() error {
+	return a.store.PurgeExpiredLoginTokens()
+}
+
+func hashLoginToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryLoginTokenStore keeps login tokens in memory. Tokens are lost on restart
+// and not shared between instances, so it is meant for tests and development.
+type MemoryLoginTokenStore struct {
+	token map[string]LoginToken
+	mux   sync.Mutex
+}
+
+// NewMemoryLoginTokenStore returns an empty MemoryLoginTokenStore.
+func NewMemoryLoginTokenStore() *MemoryLoginTokenStore {
+	return &MemoryLoginTokenStore{
+		token: make(map[string]LoginToken),
+	}
 }
 
-func (a *LoginTokenAuth) add(lt LoginToken) {
-	a.mux.Lock()
-	a.token[lt.Token] = lt
-	a.mux.Unlock()
+// CreateLoginToken stores a new login token.
+func (s *MemoryLoginTokenStore) CreateLoginToken(lt *LoginToken) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stored := *lt
+	stored.Token = ""
+	stored.CreatedAt = time.Now()
+	s.token[lt.TokenHash] = stored
+	return nil
 }
 
-func (a *LoginTokenAuth) delete(token string) {
-	a.mux.Lock()
-	delete(a.token, token)
-	a.mux.Unlock()
+// RedeemLoginToken increments the attempt counter of the token and returns it.
+func (s *MemoryLoginTokenStore) RedeemLoginToken(hash string) (*LoginToken, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	lt, ok := s.token[hash]
+	if !ok {
+		return nil, errTokenNotFound
+	}
+	lt.Attempts++
+	s.token[hash] = lt
+	return &lt, nil
 }
 
-func (a *LoginTokenAuth) purgeExpired() {
-	for t, v := range a.token {
-		if time.Now().After(v.Expiry) {
-			a.delete(t)
+// PurgeExpiredLoginTokens deletes expired login tokens.
+func (s *MemoryLoginTokenStore) PurgeExpiredLoginTokens() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for hash, lt := range s.token {
+		if time.Now().After(lt.Expiry) {
+			delete(s.token, hash)
 		}
 	}
+	return nil
 }
 
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
@@ -22,6 +22,12 @@ type MockAuthStore struct {
 	DeleteTokenFn      func(t *jwt.Token) error
 	DeleteTokenInvoked bool
 
+	MarkTokenRotatedFn      func(t *jwt.Token) error
+	MarkTokenRotatedInvoked bool
+
+	DeleteTokenFamilyFn      func(accountID int, family string) error
+	DeleteTokenFamilyInvoked bool
+
 	PurgeExpiredTokenFn      func() error
 	PurgeExpiredTokenInvoked bool
 }
@@ -62,6 +68,18 @@ func (s *MockAuthStore) DeleteToken(t *jwt.Token) error {
 	return s.DeleteTokenFn(t)
 }
 
+// MarkTokenRotated mock marks a refresh token as rotated.
+func (s *MockAuthStore) MarkTokenRotated(t *jwt.Token) error {
+	s.MarkTokenRotatedInvoked = true
+	return s.MarkTokenRotatedFn(t)
+}
+
+// DeleteTokenFamily mock deletes all refresh tokens of a family.
+func (s *MockAuthStore) DeleteTokenFamily(accountID int, family string) error {
+	s.DeleteTokenFamilyInvoked = true
+	return s.DeleteTokenFamilyFn(accountID, family)
+}
+
 // PurgeExpiredToken mock deletes expired refresh token.
 func (s *MockAuthStore) PurgeExpiredToken() error {
 	s.PurgeExpiredTokenInvoked = true
@@ -0,0 +1,213 @@
+package pwdless
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/uptrace/bun"
+)
+
+// Rate limit key prefixes for the throttled authentication steps.
+const (
+	limitLoginEmail = "login:email:"
+	limitLoginMail  = "login:mail:"
+	limitLoginIP    = "login:ip:"
+	limitTokenIP    = "token:ip:"
+)
+
+// AccountKeys returns the rate limit and audit log keys referring to an account,
+// e.g. to export or erase its personal data. Keys of client IPs are not included.
+func AccountKeys(accountID int, email string) []string {
+	return []string{
+		limitLoginEmail + email,
+		limitLoginMail + email,
+		auditAccount + strconv.Itoa(accountID),
+	}
+}
+
+// RateLimitState holds the failed attempts and lockout state of a rate limit key.
+type RateLimitState struct {
+	Key         string    `bun:"key,pk"`
+	Failures    int       `bun:"failures,notnull"`
+	WindowStart time.Time `bun:"window_start,notnull"`
+	Lockouts    int       `bun:"lockouts,notnull"`
+	LockedUntil time.Time `bun:"locked_until,nullzero"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull"`
+
+	bun.BaseModel `bun:"table:auth_rate_limits"`
+}
+
+// RateLimitStore defines operations on rate limit states. Implementations must apply
+// UpdateRateLimit atomically to support multiple instances.
+type RateLimitStore interface {
+	// GetRateLimit returns the state of key, or a zero state if none exists.
+	GetRateLimit(key string) (*RateLimitState, error)
+	// UpdateRateLimit applies fn to the current state of key and stores the result.
+	UpdateRateLimit(key string, fn func(s *RateLimitState)) (*RateLimitState, error)
+	// PurgeRateLimits deletes states not updated since before that are not locked out.
+	PurgeRateLimits(before time.Time) error
+}
+
+// RateLimiter throttles authentication attempts per key. After maxAttempts failures
+// within window a key is locked out. Each further lockout lasts backoff times longer
+// than the previous one, up to maxLockout. Login mails are limited to mailAttempts
+// per email within window, without lockout.
+type RateLimiter struct {
+	store         RateLimitStore
+	emailAttempts int
+	mailAttempts  int
+	ipAttempts    int
+	window        time.Duration
+	lockout       time.Duration
+	backoff       float64
+	maxLockout    time.Duration
+}
+
+// NewRateLimiter configures and returns a RateLimiter using store as backend.
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	return &RateLimiter{
+		store:         store,
+		emailAttempts: viper.GetInt("auth_rate_limit_email_attempts"),
+		mailAttempts:  viper.GetInt("auth_rate_limit_mail_attempts"),
+		ipAttempts:    viper.GetInt("auth_rate_limit_ip_attempts"),
+		window:        viper.GetDuration("auth_rate_limit_window"),
+		lockout:       viper.GetDuration("auth_rate_limit_lockout"),
+		backoff:       viper.GetFloat64("auth_rate_limit_backoff"),
+		maxLockout:    viper.GetDuration("auth_rate_limit_max_lockout"),
+	}
+}
+
+// LockedFor returns the remaining lockout duration of key, zero if not locked.
+func (l *RateLimiter) LockedFor(key string) (time.Duration, error) {
+	s, err := l.store.GetRateLimit(key)
+	if err != nil {
+		return 0, err
+	}
+	if d := time.Until(s.LockedUntil); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt for key allowing maxAttempts failures within the window.
+// It returns the lockout duration if the attempt triggered a lockout, zero otherwise.
+// A maxAttempts of zero disables rate limiting.
+func (l *RateLimiter) Fail(key string, maxAttempts int) (time.Duration, error) {
+	if maxAttempts <= 0 {
+		return 0, nil
+	}
+
+	var lockout time.Duration
+	_, err := l.store.UpdateRateLimit(key, func(s *RateLimitState) {
+		now := time.Now()
+		if now.Sub(s.WindowStart) > l.window {
+			s.Failures = 0
+			s.WindowStart = now
+		}
+		s.Failures++
+		s.UpdatedAt = now
+
+		if s.Failures >= maxAttempts {
+			lockout = l.lockoutDuration(s.Lockouts)
+			s.Lockouts++
+			s.LockedUntil = now.Add(lockout)
+			s.Failures = 0
+			s.WindowStart = now
+		}
+	})
+	return lockout, err
+}
+
+// Take counts a request for key allowing maxRequests requests within the window.
+// It returns the time until the window ends if the limit is reached, zero otherwise.
+// Unlike Fail it never locks out key. A maxRequests of zero disables the limit.
+func (l *RateLimiter) Take(key string, maxRequests int) (time.Duration, error) {
+	if maxRequests <= 0 {
+		return 0, nil
+	}
+
+	var wait time.Duration
+	_, err := l.store.UpdateRateLimit(key, func(s *RateLimitState) {
+		now := time.Now()
+		if now.Sub(s.WindowStart) > l.window {
+			s.Failures = 0
+			s.WindowStart = now
+		}
+		s.UpdatedAt = now
+
+		if s.Failures >= maxRequests {
+			wait = s.WindowStart.Add(l.window).Sub(now)
+			return
+		}
+		s.Failures++
+	})
+	return wait, err
+}
+
+// Purge deletes rate limit states that are unused for longer than the maximum lockout.
+func (l *RateLimiter) Purge() error {
+	return l.store.PurgeRateLimits(time.Now().Add(-l.maxLockout))
+}
+
+func (l *RateLimiter) lockoutDuration(previous int) time.Duration {
+	d := time.Duration(float64(l.lockout) * math.Pow(l.backoff, float64(previous)))
+	if d <= 0 || d > l.maxLockout {
+		return l.maxLockout
+	}
+	return d
+}
+
+// MemoryRateLimitStore keeps rate limit states in memory of a single instance.
+type MemoryRateLimitStore struct {
+	state map[string]RateLimitState
+	mux   sync.Mutex
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		state: make(map[string]RateLimitState),
+	}
+}
+
+// GetRateLimit returns the state of key.
+func (s *MemoryRateLimitStore) GetRateLimit(key string) (*RateLimitState, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	st, ok := s.state[key]
+	if !ok {
+		st = RateLimitState{Key: key}
+	}
+	return &st, nil
+}
+
+// UpdateRateLimit applies fn to the state of key.
+func (s *MemoryRateLimitStore) UpdateRateLimit(key string, fn func(s *RateLimitState)) (*RateLimitState, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	st, ok := s.state[key]
+	if !ok {
+		st = RateLimitState{Key: key}
+	}
+	fn(&st)
+	s.state[key] = st
+	return &st, nil
+}
+
+// PurgeRateLimits deletes states not updated since before that are not locked out.
+func (s *MemoryRateLimitStore) PurgeRateLimits(before time.Time) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for key, st := range s.state {
+		if st.UpdatedAt.Before(before) && time.Now().After(st.LockedUntil) {
+			delete(s.state, key)
+		}
+	}
+	return nil
+}
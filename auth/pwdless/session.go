@@ -0,0 +1,100 @@
+package pwdless
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+// SessionStore defines database operations on the login sessions of accounts.
+// DeleteSession returns sql.ErrNoRows for unknown sessions.
+type SessionStore interface {
+	ListSessions(accountID int) ([]jwt.Token, error)
+	DeleteSession(accountID, id int) error
+	DeleteSessions(accountID int) error
+}
+
+// SessionResource lists and revokes the login sessions of an account. The
+// account is looked up per request by AccountID, e.g. the logged in account or
+// the account managed by an administrator.
+type SessionResource struct {
+	Store     SessionStore
+	AccountID func(r *http.Request) int
+}
+
+// NewSessionResource creates and returns a session resource.
+func NewSessionResource(store SessionStore, accountID func(r *http.Request) int) *SessionResource {
+	return &SessionResource{
+		Store:     store,
+		AccountID: accountID,
+	}
+}
+
+// Router provides the session routes, to be mounted below the account.
+func (rs *SessionResource) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", rs.list)
+	r.Delete("/", rs.deleteAll)
+	r.Delete("/{sessionID}", rs.delete)
+	return r
+}
+
+type sessionListResponse struct {
+	Sessions []jwt.Session `json:"sessions"`
+}
+
+func newSessionListResponse(tokens []jwt.Token) *sessionListResponse {
+	resp := &sessionListResponse{Sessions: make([]jwt.Session, 0, len(tokens))}
+	for _, t := range tokens {
+		resp.Sessions = append(resp.Sessions, t.Session())
+	}
+	return resp
+}
+
+func (rs *SessionResource) list(w http.ResponseWriter, r *http.Request) {
+	tokens, err := rs.Store.ListSessions(rs.AccountID(r))
+	if err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
+	render.Respond(w, r, newSessionListResponse(tokens))
+}
+
+// deleteAll logs out the account everywhere, e.g. when offboarding staff.
+// Access tokens already issued stay valid until they expire.
+func (rs *SessionResource) deleteAll(w http.ResponseWriter, r *http.Request) {
+	accountID := rs.AccountID(r)
+	if err := rs.Store.DeleteSessions(accountID); err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
+	log(r).WithField("account_id", accountID).Info("revoked all sessions")
+	render.Respond(w, r, http.NoBody)
+}
+
+func (rs *SessionResource) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		render.Render(w, r, ErrBadRequest)
+		return
+	}
+	err = rs.Store.DeleteSession(rs.AccountID(r), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	if err != nil {
+		log(r).Error(err)
+		render.Render(w, r, ErrInternalServerError)
+		return
+	}
+	render.Respond(w, r, http.NoBody)
+}
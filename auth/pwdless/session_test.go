@@ -0,0 +1,122 @@
+package pwdless
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhax/go-base/auth/jwt"
+)
+
+// sessionStore keeps the tokens of a single account in memory.
+type sessionStore struct {
+	accountID int
+	tokens    []jwt.Token
+	err       error // returned by all operations if set
+}
+
+func (s *sessionStore) ListSessions(accountID int) ([]jwt.Token, error) {
+	if s.err != nil || accountID != s.accountID {
+		return nil, s.err
+	}
+	return s.tokens, nil
+}
+
+func (s *sessionStore) DeleteSession(accountID, id int) error {
+	if s.err != nil {
+		return s.err
+	}
+	var kept []jwt.Token
+	for _, t := range s.tokens {
+		if accountID != s.accountID || t.ID != id {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(s.tokens) {
+		return sql.ErrNoRows
+	}
+	s.tokens = kept
+	return nil
+}
+
+func (s *sessionStore) DeleteSessions(accountID int) error {
+	if s.err != nil {
+		return s.err
+	}
+	if accountID == s.accountID {
+		s.tokens = nil
+	}
+	return nil
+}
+
+func TestSessionResource(t *testing.T) {
+	store := &sessionStore{accountID: 7, tokens: []jwt.Token{
+		{ID: 1, AccountID: 7, Identifier: "laptop"},
+		{ID: 2, AccountID: 7, Identifier: "phone"},
+	}}
+	router := NewSessionResource(store, func(r *http.Request) int { return 7 }).Router()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+	sessions := func() []jwt.Session {
+		var resp sessionListResponse
+		w := serve("GET", "/")
+		if w.Code != http.StatusOK {
+			t.Fatalf("list sessions: got status %d", w.Code)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Sessions
+	}
+
+	if s := sessions(); len(s) != 2 || s[0].Device != "laptop" {
+		t.Fatalf("expected both sessions, got %+v", s)
+	}
+
+	if w := serve("DELETE", "/abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("delete invalid session: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if w := serve("DELETE", "/9"); w.Code != http.StatusNotFound {
+		t.Errorf("delete unknown session: expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := serve("DELETE", "/1"); w.Code != http.StatusOK {
+		t.Errorf("delete session: got status %d", w.Code)
+	}
+	if s := sessions(); len(s) != 1 || s[0].ID != 2 {
+		t.Fatalf("expected the phone session only, got %+v", s)
+	}
+
+	if w := serve("DELETE", "/"); w.Code != http.StatusOK {
+		t.Errorf("delete sessions: got status %d", w.Code)
+	}
+	if s := sessions(); len(s) != 0 {
+		t.Fatalf("expected no sessions, got %+v", s)
+	}
+}
+
+func TestSessionResourceStoreError(t *testing.T) {
+	store := &sessionStore{accountID: 7, err: errors.New("connection refused")}
+	router := NewSessionResource(store, func(r *http.Request) int { return 7 }).Router()
+
+	for _, method := range []string{"GET", "DELETE"} {
+		for _, target := range []string{"/", "/1"} {
+			if method == "GET" && target == "/1" {
+				continue
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("%s %s: expected status %d, got %d", method, target, http.StatusInternalServerError, w.Code)
+			}
+		}
+	}
+}
@@ -53,7 +53,7 @@ func init() {
 }
 
 func genRoutesDoc() {
-	api, err := api.New(false)
+	api, _, err := api.New(false)
 	if err != nil {
 		log.Fatalf("Failed to initialize API: %v", err)
 	}
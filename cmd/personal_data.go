@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dhax/go-base/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	subjectUserID    int64
+	subjectStudentID int64
+	eraseDryRun      bool
+)
+
+// personalDataCmd represents the personal-data command
+var personalDataCmd = &cobra.Command{
+	Use:   "personal-data",
+	Short: "Export or erase the personal data of a user or student",
+	Long: `Handle data subject requests for a user, given by --user, or for a
+student, given by --student.`,
+}
+
+// exportCmd represents the personal-data export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all data stored about a user as JSON",
+	Long: `Write the user, their student data, tag assignments, tag reads, visits,
+feedback, activity group enrolments and login data to stdout as JSON.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, userID := subjectStore()
+
+		data, err := store.Export(context.Background(), userID)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// eraseCmd represents the personal-data erase command
+var eraseCmd = &cobra.Command{
+	Use:   "erase",
+	Short: "Erase all data stored about a user",
+	Long: `Delete the user with their student data, tag assignments, the tag reads
+attributed to them and their login account, including its login tokens, rate
+limits and audit log entries.
+
+Tag reads and visits are aggregated into the anonymised daily statistics
+first. Use --dry-run to count the rows to delete only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, userID := subjectStore()
+
+		results, err := store.Erase(context.Background(), userID, eraseDryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, res := range results {
+			action := "deleted"
+			if eraseDryRun {
+				action = "to delete"
+			}
+			fmt.Printf("%-30s %8d rows %s\n", res.Table, res.Rows, action)
+		}
+	},
+}
+
+// subjectStore connects to the database and returns the user ID of the data subject.
+func subjectStore() (*database.PersonalDataStore, int64) {
+	if (subjectUserID == 0) == (subjectStudentID == 0) {
+		log.Fatal(errors.New("either --user or --student is required"))
+	}
+
+	db, err := database.DBConn()
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := database.NewPersonalDataStore(db)
+
+	if subjectStudentID == 0 {
+		return store, subjectUserID
+	}
+	userID, err := store.GetStudentUserID(context.Background(), subjectStudentID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store, userID
+}
+
+func init() {
+	RootCmd.AddCommand(personalDataCmd)
+	personalDataCmd.AddCommand(exportCmd, eraseCmd)
+
+	personalDataCmd.PersistentFlags().Int64Var(&subjectUserID, "user", 0, "ID of the user")
+	personalDataCmd.PersistentFlags().Int64Var(&subjectStudentID, "student", 0, "ID of the student")
+	eraseCmd.Flags().BoolVar(&eraseDryRun, "dry-run", false, "Count the rows to delete without deleting them")
+}
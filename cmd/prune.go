@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dhax/go-base/database"
+	"github.com/spf13/cobra"
+)
+
+var dryRun bool
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Prune expired movement data",
+	Long: `Delete RFID reads, visits, timespans, device sync history, the login
+audit log and unknown tags older than their configured retention period.
+
+Expired data is aggregated into anonymised daily statistics first, unless
+RETENTION_AGGREGATE is false. Use --dry-run to count the expired rows only.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := database.DBConn()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		store := database.NewRetentionStore(db)
+		results, err := store.Prune(context.Background(), database.NewRetentionPolicy(), time.Now(), dryRun)
+		for _, res := range results {
+			action := "deleted"
+			if dryRun {
+				action = "to delete"
+			}
+			fmt.Printf("%-20s %8d rows %s before %s\n", res.Table, res.Rows, action, res.Cutoff.Format("2006-01-02"))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(results) == 0 {
+			fmt.Println("No retention periods configured")
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Count expired rows without deleting them")
+}
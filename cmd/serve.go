@@ -32,10 +32,47 @@ func init() {
 	viper.SetDefault("auth_login_url", "http://localhost:3000/login")
 	viper.SetDefault("auth_login_token_length", 8)
 	viper.SetDefault("auth_login_token_expiry", "11m")
+	viper.SetDefault("auth_login_token_store", "postgres")
+	viper.SetDefault("auth_jwt_alg", "ES256")
+	viper.SetDefault("auth_jwt_key_store", "postgres")
+	viper.SetDefault("auth_jwt_key_rotation", "720h")
 	viper.SetDefault("auth_jwt_secret", "random")
 	viper.SetDefault("auth_jwt_expiry", "15m")
 	viper.SetDefault("auth_jwt_refresh_expiry", "1h")
 
+	viper.SetDefault("auth_rate_limit_store", "postgres")
+	viper.SetDefault("auth_rate_limit_email_attempts", 5)
+	viper.SetDefault("auth_rate_limit_mail_attempts", 5)
+	viper.SetDefault("auth_rate_limit_ip_attempts", 20)
+	viper.SetDefault("auth_rate_limit_window", "15m")
+	viper.SetDefault("auth_rate_limit_lockout", "1m")
+	viper.SetDefault("auth_rate_limit_backoff", 2)
+	viper.SetDefault("auth_rate_limit_max_lockout", "24h")
+	viper.SetDefault("auth_trusted_proxies", "")
+
+	viper.SetDefault("rfid_max_clock_skew", "1m")
+	viper.SetDefault("rfid_max_read_age", "168h")
+	viper.SetDefault("rfid_debounce_window", "5s")
+	viper.SetDefault("rfid_tag_debounce_window", "0s")
+	viper.SetDefault("rfid_anti_passback", "ignore")
+	viper.SetDefault("rfid_sync_interval", "1m")
+	viper.SetDefault("rfid_api_key_grace_period", "24h")
+	viper.SetDefault("rfid_api_key_query", false)
+	viper.SetDefault("rfid_health_check_interval", "1m")
+	viper.SetDefault("rfid_offline_after", "5m")
+	viper.SetDefault("rfid_offline_alert_after", "15m")
+	viper.SetDefault("rfid_alert_email", "")
+
+	viper.SetDefault("retention_prune_interval", "24h")
+	viper.SetDefault("retention_tag_reads", "0")
+	viper.SetDefault("retention_room_visits", "0")
+	viper.SetDefault("retention_visits", "0")
+	viper.SetDefault("retention_timespans", "0")
+	viper.SetDefault("retention_sync_history", "0")
+	viper.SetDefault("retention_auth_audit_log", "0")
+	viper.SetDefault("retention_unknown_tags", "0")
+	viper.SetDefault("retention_aggregate", true)
+
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	// serveCmd.PersistentFlags().String("foo", "", "A help for foo")
@@ -28,7 +28,9 @@ func (s *AccountStore) Get(id int) (*pwdless.Account, error) {
 	err := s.db.NewSelect().
 		Model(a).
 		Where("id = ?", id).
-		Relation("Token").
+		Relation("Token", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("rotated_at IS NULL")
+		}).
 		Scan(context.Background())
 	return a, err
 }
@@ -92,3 +94,18 @@ func (s *AccountStore) DeleteToken(t *jwt.Token) error {
 		Exec(context.Background())
 	return err
 }
+
+// ListSessions returns the active login sessions of an account.
+func (s *AccountStore) ListSessions(accountID int) ([]jwt.Token, error) {
+	return listSessions(s.db, accountID)
+}
+
+// DeleteSession revokes a login session of an account.
+func (s *AccountStore) DeleteSession(accountID, id int) error {
+	return deleteSession(s.db, accountID, id)
+}
+
+// DeleteSessions revokes all login sessions of an account.
+func (s *AccountStore) DeleteSessions(accountID int) error {
+	return deleteSessions(s.db, accountID)
+}
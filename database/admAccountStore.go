@@ -181,3 +181,18 @@ func (s *AdmAccountStore) Delete(a *pwdless.Account) error {
 	tx.Commit()
 	return nil
 }
+
+// ListSessions returns the active login sessions of an account.
+func (s *AdmAccountStore) ListSessions(accountID int) ([]jwt.Token, error) {
+	return listSessions(s.db, accountID)
+}
+
+// DeleteSession revokes a login session of an account.
+func (s *AdmAccountStore) DeleteSession(accountID, id int) error {
+	return deleteSession(s.db, accountID, id)
+}
+
+// DeleteSessions revokes all login sessions of an account.
+func (s *AdmAccountStore) DeleteSessions(accountID int) error {
+	return deleteSessions(s.db, accountID)
+}
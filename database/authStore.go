@@ -86,6 +86,35 @@ func (s *AuthStore) DeleteToken(t *jwt.Token) error {
 	return err
 }
 
+// MarkTokenRotated marks a refresh token as rotated. It returns jwt.ErrTokenReused
+// if the token was rotated already, e.g. by a concurrent refresh.
+func (s *AuthStore) MarkTokenRotated(t *jwt.Token) error {
+	t.RotatedAt = time.Now()
+	res, err := s.db.NewUpdate().
+		Model(t).
+		Column("rotated_at").
+		WherePK().
+		Where("rotated_at IS NULL").
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return jwt.ErrTokenReused
+	}
+	return nil
+}
+
+// DeleteTokenFamily deletes all refresh tokens of a token family.
+func (s *AuthStore) DeleteTokenFamily(accountID int, family string) error {
+	_, err := s.db.NewDelete().
+		Model((*jwt.Token)(nil)).
+		Where("account_id = ?", accountID).
+		Where("family = ?", family).
+		Exec(context.Background())
+	return err
+}
+
 // PurgeExpiredToken deletes expired refresh token.
 func (s *AuthStore) PurgeExpiredToken() error {
 	_, err := s.db.NewDelete().
@@ -94,3 +123,11 @@ func (s *AuthStore) PurgeExpiredToken() error {
 		Exec(context.Background())
 	return err
 }
+
+// CreateAuditEvent stores an authentication audit event.
+func (s *AuthStore) CreateAuditEvent(e *pwdless.AuditEvent) error {
+	_, err := s.db.NewInsert().
+		Model(e).
+		Exec(context.Background())
+	return err
+}
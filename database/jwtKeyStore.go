@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/uptrace/bun"
+)
+
+// JWTKeyStore implements database operations for JWT signing keys.
+type JWTKeyStore struct {
+	db *bun.DB
+}
+
+// NewJWTKeyStore returns a JWTKeyStore.
+func NewJWTKeyStore(db *bun.DB) *JWTKeyStore {
+	return &JWTKeyStore{
+		db: db,
+	}
+}
+
+// ListSigningKeys returns all stored signing keys, newest first.
+func (s *JWTKeyStore) ListSigningKeys() ([]jwt.SigningKey, error) {
+	var keys []jwt.SigningKey
+	err := s.db.NewSelect().
+		Model(&keys).
+		Order("created_at DESC").
+		Scan(context.Background())
+	return keys, err
+}
+
+// CreateSigningKey stores a new signing key.
+func (s *JWTKeyStore) CreateSigningKey(k *jwt.SigningKey) error {
+	_, err := s.db.NewInsert().
+		Model(k).
+		Exec(context.Background())
+	return err
+}
+
+// PurgeExpiredSigningKeys deletes signing keys past their expiry.
+func (s *JWTKeyStore) PurgeExpiredSigningKeys() error {
+	_, err := s.db.NewDelete().
+		Model((*jwt.SigningKey)(nil)).
+		Where("expires_at < ?", time.Now()).
+		Exec(context.Background())
+	return err
+}
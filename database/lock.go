@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/uptrace/bun"
+)
+
+// TryLock takes the postgres advisory lock of the given name, so that periodic
+// jobs run on a single instance when several instances share the database. ok
+// is false if another instance holds the lock. Otherwise the lock is held by a
+// transaction until unlock is called.
+func TryLock(ctx context.Context, db bun.IDB, name string) (unlock func(), ok bool, err error) {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	key := int64(h.Sum64())
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock(?)", key).Scan(&ok); err != nil || !ok {
+		tx.Rollback()
+		return nil, false, err
+	}
+
+	return func() { tx.Rollback() }, true, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int64 // 1 if the lock is free
+		ok      bool
+		queries []string
+	}{
+		{"free", 1, true, []string{"BEGIN", "SELECT pg_try_advisory_xact_lock(5770561535780977271)", "ROLLBACK"}},
+		{"held by another instance", 0, false, []string{"BEGIN", "SELECT pg_try_advisory_xact_lock(5770561535780977271)", "ROLLBACK"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, rec := newRecorderDB(tt.rows)
+
+			unlock, ok, err := NewRetentionStore(db).TryLock(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				// The lock is held until unlocked
+				assert.Len(t, rec.queries, 2)
+				unlock()
+			}
+			assert.Equal(t, tt.queries, rec.queries)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhax/go-base/auth/pwdless"
+	"github.com/uptrace/bun"
+)
+
+// LoginTokenStore implements database operations for passwordless login tokens.
+type LoginTokenStore struct {
+	db *bun.DB
+}
+
+// NewLoginTokenStore returns a LoginTokenStore.
+func NewLoginTokenStore(db *bun.DB) *LoginTokenStore {
+	return &LoginTokenStore{
+		db: db,
+	}
+}
+
+// CreateLoginToken stores a new login token.
+func (s *LoginTokenStore) CreateLoginToken(lt *pwdless.LoginToken) error {
+	_, err := s.db.NewInsert().
+		Model(lt).
+		Exec(context.Background())
+	return err
+}
+
+// RedeemLoginToken atomically increments the attempt counter of the token and returns it.
+func (s *LoginTokenStore) RedeemLoginToken(hash string) (*pwdless.LoginToken, error) {
+	lt := new(pwdless.LoginToken)
+	err := s.db.NewUpdate().
+		Model(lt).
+		Set("attempts = attempts + 1").
+		Where("token_hash = ?", hash).
+		Returning("*").
+		Scan(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return lt, nil
+}
+
+// PurgeExpiredLoginTokens deletes expired login tokens.
+func (s *LoginTokenStore) PurgeExpiredLoginTokens() error {
+	_, err := s.db.NewDelete().
+		Model((*pwdless.LoginToken)(nil)).
+		Where("expiry < ?", time.Now()).
+		Exec(context.Background())
+	return err
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] creating login_tokens table...")
+
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS login_tokens (
+				token_hash TEXT PRIMARY KEY,
+				account_id INT NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+				expiry TIMESTAMP WITH TIME ZONE NOT NULL,
+				attempts INT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_login_tokens_expiry ON login_tokens(expiry);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] dropping login_tokens table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS login_tokens;`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] creating auth_rate_limits and auth_audit_log tables...")
+
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS auth_rate_limits (
+				key TEXT PRIMARY KEY,
+				failures INT NOT NULL DEFAULT 0,
+				window_start TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+				lockouts INT NOT NULL DEFAULT 0,
+				locked_until TIMESTAMP WITH TIME ZONE,
+				updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+			);
+
+			CREATE TABLE IF NOT EXISTS auth_audit_log (
+				id SERIAL PRIMARY KEY,
+				event TEXT NOT NULL,
+				key TEXT,
+				ip TEXT,
+				details TEXT,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_auth_audit_log_created_at ON auth_audit_log(created_at);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] dropping auth_rate_limits and auth_audit_log tables...")
+		_, err := db.ExecContext(ctx, `
+			DROP TABLE IF EXISTS auth_audit_log;
+			DROP TABLE IF EXISTS auth_rate_limits;
+		`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
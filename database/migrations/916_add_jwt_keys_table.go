@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] creating jwt_keys table...")
+
+		_, err := db.ExecContext(ctx, `
+			CREATE TABLE IF NOT EXISTS jwt_keys (
+				kid TEXT PRIMARY KEY,
+				alg TEXT NOT NULL,
+				private_key BYTEA NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+				expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_jwt_keys_expires_at ON jwt_keys(expires_at);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] dropping jwt_keys table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS jwt_keys;`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] adding refresh token family columns...")
+
+		// existing tokens each start their own family
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tokens ADD COLUMN IF NOT EXISTS family TEXT;
+			ALTER TABLE tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMP WITH TIME ZONE;
+			UPDATE tokens SET family = 'token-' || id WHERE family IS NULL;
+			ALTER TABLE tokens ALTER COLUMN family SET NOT NULL;
+
+			CREATE INDEX IF NOT EXISTS idx_tokens_account_family ON tokens(account_id, family);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] removing refresh token family columns...")
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS idx_tokens_account_family;
+			DELETE FROM tokens WHERE rotated_at IS NOT NULL;
+			ALTER TABLE tokens DROP COLUMN IF EXISTS rotated_at;
+			ALTER TABLE tokens DROP COLUMN IF EXISTS family;
+		`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] adding session columns to tokens table...")
+
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+			ALTER TABLE tokens ADD COLUMN IF NOT EXISTS ip TEXT;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] removing session columns from tokens table...")
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tokens DROP COLUMN IF EXISTS ip;
+			ALTER TABLE tokens DROP COLUMN IF EXISTS user_agent;
+		`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] replacing student location flags with location state...")
+
+		// students created by migration 8 from an older model still have the location flags
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT 'absent';
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS location_room_id BIGINT REFERENCES rooms(id) ON DELETE SET NULL;
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS location_since TIMESTAMP WITH TIME ZONE;
+
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'students' AND column_name = 'in_house') THEN
+					UPDATE students SET
+						location = CASE
+							WHEN wc THEN 'wc'
+							WHEN school_yard THEN 'school_yard'
+							WHEN in_house THEN 'in_house'
+							ELSE 'absent'
+						END,
+						location_since = modified_at;
+					ALTER TABLE students DROP COLUMN in_house, DROP COLUMN wc, DROP COLUMN school_yard;
+				END IF;
+			END $$;
+
+			CREATE TABLE IF NOT EXISTS student_location_transitions (
+				id BIGSERIAL PRIMARY KEY,
+				student_id BIGINT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+				from_location TEXT NOT NULL,
+				to_location TEXT NOT NULL,
+				room_id BIGINT REFERENCES rooms(id) ON DELETE SET NULL,
+				source TEXT NOT NULL,
+				actor_id BIGINT,
+				at TIMESTAMP WITH TIME ZONE NOT NULL,
+				created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_student_location_transitions_student_at ON student_location_transitions(student_id, at);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] restoring student location flags...")
+		_, err := db.ExecContext(ctx, `
+			DROP TABLE IF EXISTS student_location_transitions;
+
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS in_house BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS wc BOOLEAN NOT NULL DEFAULT FALSE;
+			ALTER TABLE students ADD COLUMN IF NOT EXISTS school_yard BOOLEAN NOT NULL DEFAULT FALSE;
+			UPDATE students SET
+				in_house = location IN ('in_house', 'room', 'wc'),
+				wc = location = 'wc',
+				school_yard = location = 'school_yard';
+
+			ALTER TABLE students DROP COLUMN IF EXISTS location_since;
+			ALTER TABLE students DROP COLUMN IF EXISTS location_room_id;
+			ALTER TABLE students DROP COLUMN IF EXISTS location;
+		`)
+		if err != nil {
+			return err
+		}
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add rfid_readers table...")
+
+		_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS rfid_readers (
+			id BIGSERIAL PRIMARY KEY,
+			reader_id VARCHAR(255) NOT NULL UNIQUE,
+			name VARCHAR(255) NOT NULL,
+			location_type VARCHAR(50) NOT NULL,
+			room_id BIGINT REFERENCES rooms(id) ON DELETE CASCADE,
+			direction VARCHAR(50) NOT NULL DEFAULT 'entry',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			CHECK ((location_type = 'room') = (room_id IS NOT NULL))
+		);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop rfid_readers table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS rfid_readers;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add event_id to rfid tags...")
+
+		// Reads without event ID are stored as NULL and never conflict
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tags ADD COLUMN IF NOT EXISTS event_id VARCHAR(255);
+
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_reader_event ON tags(reader_id, event_id);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] remove event_id from rfid tags...")
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS idx_tags_reader_event;
+			ALTER TABLE tags DROP COLUMN IF EXISTS event_id;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add debounce and anti-passback settings to rfid_readers...")
+
+		// NULL values fall back to the configured defaults
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE rfid_readers ADD COLUMN IF NOT EXISTS debounce_seconds INTEGER CHECK (debounce_seconds BETWEEN 0 AND 3600);
+			ALTER TABLE rfid_readers ADD COLUMN IF NOT EXISTS anti_passback VARCHAR(50) CHECK (anti_passback IN ('ignore', 'toggle', 'off'));
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] remove debounce and anti-passback settings from rfid_readers...")
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE rfid_readers DROP COLUMN IF EXISTS anti_passback;
+			ALTER TABLE rfid_readers DROP COLUMN IF EXISTS debounce_seconds;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
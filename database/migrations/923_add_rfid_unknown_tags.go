@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add rfid_unknown_tags table...")
+
+		_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS rfid_unknown_tags (
+			id BIGSERIAL PRIMARY KEY,
+			tag_id VARCHAR(255) NOT NULL UNIQUE,
+			reader_id VARCHAR(255) NOT NULL,
+			first_seen_at TIMESTAMP NOT NULL,
+			last_seen_at TIMESTAMP NOT NULL,
+			read_count INTEGER NOT NULL DEFAULT 1,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop rfid_unknown_tags table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS rfid_unknown_tags;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
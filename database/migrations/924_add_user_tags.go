@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add user_tags table...")
+
+		_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS user_tags (
+			id BIGSERIAL PRIMARY KEY,
+			tag_id VARCHAR(255) NOT NULL,
+			custom_user_id BIGINT NOT NULL REFERENCES custom_users(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL DEFAULT 'card'
+				CHECK (kind IN ('card', 'wristband', 'other')),
+			status VARCHAR(20) NOT NULL DEFAULT 'active'
+				CHECK (status IN ('active', 'lost', 'blocked')),
+			valid_from TIMESTAMP NOT NULL,
+			valid_until TIMESTAMP,
+			note TEXT,
+			assigned_at TIMESTAMP NOT NULL,
+			unassigned_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+
+		-- A tag is assigned to one user at a time, ended assignments are history
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_user_tags_assigned
+			ON user_tags(tag_id) WHERE unassigned_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_user_tags_custom_user_id ON user_tags(custom_user_id);
+
+		-- Existing tags become the active card of their user
+		INSERT INTO user_tags (tag_id, custom_user_id, kind, status, valid_from, assigned_at, created_at, updated_at)
+		SELECT tag_id, id, 'card', 'active', created_at, created_at, NOW(), NOW()
+		FROM custom_users
+		WHERE tag_id IS NOT NULL AND tag_id <> '';
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop user_tags table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS user_tags;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] hash tauri_devices api keys...")
+
+		// Keys are looked up by their first 8 characters and compared by SHA-256 hash
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			ADD COLUMN IF NOT EXISTS api_key_prefix VARCHAR(8),
+			ADD COLUMN IF NOT EXISTS api_key_hash VARCHAR(64),
+			ADD COLUMN IF NOT EXISTS previous_key_prefix VARCHAR(8),
+			ADD COLUMN IF NOT EXISTS previous_key_hash VARCHAR(64),
+			ADD COLUMN IF NOT EXISTS previous_key_expires_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS key_rotated_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP;
+
+		UPDATE tauri_devices
+		SET api_key_prefix = LEFT(api_key, 8),
+			api_key_hash = encode(sha256(convert_to(api_key, 'UTF8')), 'hex');
+
+		ALTER TABLE tauri_devices
+			ALTER COLUMN api_key_prefix SET NOT NULL,
+			ALTER COLUMN api_key_hash SET NOT NULL,
+			ADD CONSTRAINT tauri_devices_api_key_hash_key UNIQUE (api_key_hash),
+			DROP COLUMN api_key;
+
+		CREATE INDEX IF NOT EXISTS idx_tauri_devices_api_key_prefix ON tauri_devices(api_key_prefix);
+		CREATE INDEX IF NOT EXISTS idx_tauri_devices_previous_key_prefix ON tauri_devices(previous_key_prefix);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] restore tauri_devices api_key column...")
+
+		// Plaintext keys can't be restored, devices need a new key after the rollback
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices ADD COLUMN IF NOT EXISTS api_key VARCHAR(255);
+		UPDATE tauri_devices SET api_key = api_key_hash;
+		ALTER TABLE tauri_devices
+			ALTER COLUMN api_key SET NOT NULL,
+			ADD CONSTRAINT tauri_devices_api_key_key UNIQUE (api_key),
+			DROP COLUMN IF EXISTS api_key_prefix,
+			DROP COLUMN IF EXISTS api_key_hash,
+			DROP COLUMN IF EXISTS previous_key_prefix,
+			DROP COLUMN IF EXISTS previous_key_hash,
+			DROP COLUMN IF EXISTS previous_key_expires_at,
+			DROP COLUMN IF EXISTS key_rotated_at,
+			DROP COLUMN IF EXISTS revoked_at,
+			DROP COLUMN IF EXISTS last_used_at;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add tauri_devices review columns...")
+
+		// Registered devices are active already, new registrations start pending
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			ADD COLUMN IF NOT EXISTS room_id BIGINT REFERENCES rooms(id) ON DELETE SET NULL,
+			ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS reviewed_by BIGINT,
+			ALTER COLUMN status SET DEFAULT 'pending';
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop tauri_devices review columns...")
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			DROP COLUMN IF EXISTS room_id,
+			DROP COLUMN IF EXISTS reviewed_at,
+			DROP COLUMN IF EXISTS reviewed_by,
+			ALTER COLUMN status SET DEFAULT 'active';
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
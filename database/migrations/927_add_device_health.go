@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add tauri_devices health columns...")
+
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			ADD COLUMN IF NOT EXISTS last_heartbeat_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS app_version VARCHAR(50),
+			ADD COLUMN IF NOT EXISTS buffered_reads INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS clock_offset_ms BIGINT NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS reader_health JSONB,
+			ADD COLUMN IF NOT EXISTS online BOOLEAN NOT NULL DEFAULT FALSE,
+			ADD COLUMN IF NOT EXISTS offline_since TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS offline_alerted_at TIMESTAMP;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop tauri_devices health columns...")
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			DROP COLUMN IF EXISTS last_heartbeat_at,
+			DROP COLUMN IF EXISTS app_version,
+			DROP COLUMN IF EXISTS buffered_reads,
+			DROP COLUMN IF EXISTS clock_offset_ms,
+			DROP COLUMN IF EXISTS reader_health,
+			DROP COLUMN IF EXISTS online,
+			DROP COLUMN IF EXISTS offline_since,
+			DROP COLUMN IF EXISTS offline_alerted_at;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add rfid_device_configs table...")
+
+		_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS rfid_device_configs (
+			id BIGSERIAL PRIMARY KEY,
+			device_id VARCHAR(255) NOT NULL UNIQUE REFERENCES tauri_devices(device_id) ON DELETE CASCADE,
+			reader_mode VARCHAR(20) NOT NULL,
+			debounce_seconds INTEGER NOT NULL DEFAULT 0,
+			sync_interval_seconds INTEGER NOT NULL,
+			min_app_version VARCHAR(50),
+			version INTEGER NOT NULL DEFAULT 1,
+			updated_by BIGINT,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(" [up migration] add rfid_device_commands table...")
+		_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS rfid_device_commands (
+			id BIGSERIAL PRIMARY KEY,
+			device_id VARCHAR(255) NOT NULL REFERENCES tauri_devices(device_id) ON DELETE CASCADE,
+			command VARCHAR(50) NOT NULL,
+			reader_id VARCHAR(255),
+			message TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			result TEXT,
+			created_by BIGINT,
+			delivered_at TIMESTAMP,
+			acknowledged_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_rfid_device_commands_device_status ON rfid_device_commands(device_id, status);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(" [up migration] add tauri_device_syncs config_version column...")
+		_, err = db.ExecContext(ctx, `
+		ALTER TABLE tauri_device_syncs ADD COLUMN IF NOT EXISTS config_version INTEGER NOT NULL DEFAULT 0;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop tauri_device_syncs config_version column...")
+		_, err := db.ExecContext(ctx, `ALTER TABLE tauri_device_syncs DROP COLUMN IF EXISTS config_version;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(" [down migration] drop rfid_device_commands table...")
+		_, err = db.ExecContext(ctx, `DROP TABLE IF EXISTS rfid_device_commands;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(" [down migration] drop rfid_device_configs table...")
+		_, err = db.ExecContext(ctx, `DROP TABLE IF EXISTS rfid_device_configs;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
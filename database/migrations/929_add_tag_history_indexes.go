@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add device_id and history indexes to rfid tags...")
+
+		// Reads stored before are kept without device
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tags ADD COLUMN IF NOT EXISTS device_id VARCHAR(255);
+
+			CREATE INDEX IF NOT EXISTS idx_tags_read_at ON tags(read_at DESC, id DESC);
+			CREATE INDEX IF NOT EXISTS idx_tags_tag_read_at ON tags(tag_id, read_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_tags_reader_read_at ON tags(reader_id, read_at DESC);
+			CREATE INDEX IF NOT EXISTS idx_tags_device_read_at ON tags(device_id, read_at DESC);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] remove device_id and history indexes from rfid tags...")
+		_, err := db.ExecContext(ctx, `
+			DROP INDEX IF EXISTS idx_tags_device_read_at;
+			DROP INDEX IF EXISTS idx_tags_reader_read_at;
+			DROP INDEX IF EXISTS idx_tags_tag_read_at;
+			DROP INDEX IF EXISTS idx_tags_read_at;
+			ALTER TABLE tags DROP COLUMN IF EXISTS device_id;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
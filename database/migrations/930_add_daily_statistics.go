@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add daily_statistics table...")
+
+		// Anonymised daily counts of pruned movement data, per reader, room or device
+		_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS daily_statistics (
+			id BIGSERIAL PRIMARY KEY,
+			day DATE NOT NULL,
+			source VARCHAR(50) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			records BIGINT NOT NULL DEFAULT 0,
+			individuals BIGINT NOT NULL DEFAULT 0,
+			minutes BIGINT NOT NULL DEFAULT 0,
+			UNIQUE (day, source, subject)
+		);
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop daily_statistics table...")
+		_, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS daily_statistics;`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add processed_at to rfid tags...")
+
+		// Reads stored before are taken as processed
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tags ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP WITH TIME ZONE;
+
+			UPDATE tags SET processed_at = created_at WHERE processed_at IS NULL;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] remove processed_at from rfid tags...")
+		_, err := db.ExecContext(ctx, `
+			ALTER TABLE tags DROP COLUMN IF EXISTS processed_at;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
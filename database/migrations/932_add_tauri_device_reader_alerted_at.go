@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [up migration] add tauri_devices reader_alerted_at column...")
+
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			ADD COLUMN IF NOT EXISTS reader_alerted_at TIMESTAMP;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		fmt.Print(" [down migration] drop tauri_devices reader_alerted_at column...")
+		_, err := db.ExecContext(ctx, `
+		ALTER TABLE tauri_devices
+			DROP COLUMN IF EXISTS reader_alerted_at;
+		`)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(" done")
+		return nil
+	})
+}
@@ -13,6 +13,8 @@ import (
 //go:embed *.sql
 var sqlMigrations embed.FS
 
+// Migrations run in the order of their names compared as strings, so 10 to 13
+// run before 2. Migrations following 13 are numbered from 914 on to run after 9.
 var Migrations = migrate.NewMigrations()
 
 func init() {
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/dhax/go-base/auth/pwdless"
+	"github.com/dhax/go-base/models"
+)
+
+// ErrSpecialistErasure is returned on erasing a pedagogical specialist, whose
+// activity groups and supervisions would be deleted with them.
+var ErrSpecialistErasure = errors.New("user is a pedagogical specialist, remove the specialist first")
+
+// userTagReads matches the reads of tags while they were assigned to a user.
+const userTagReads = `EXISTS (SELECT 1 FROM user_tags AS ut
+	WHERE ut.custom_user_id = ? AND ut.tag_id = tag.tag_id
+	AND tag.read_at >= ut.assigned_at AND (ut.unassigned_at IS NULL OR tag.read_at < ut.unassigned_at))`
+
+// The daily statistics of a user's data, kept on erasure like on pruning.
+const (
+	aggregateUserTagReads = `
+		INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+		SELECT read_at::date, 'tag_reads', reader_id, count(*), count(DISTINCT tag_id), 0
+		FROM tags AS tag WHERE ` + userTagReads + `
+		GROUP BY 1, 3` + addDailyStatistics
+	aggregateStudentRoomVisits = `
+		INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+		SELECT entry_time::date, 'room_visits', room_id::text, count(*), 1,
+			COALESCE(sum(EXTRACT(EPOCH FROM exit_time - entry_time))::bigint / 60, 0)
+		FROM student_room_visits WHERE student_id = ?
+		GROUP BY 1, 3` + addDailyStatistics
+	aggregateStudentVisits = `
+		INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+		SELECT day::date, 'visits', room_id::text, count(*), 1, 0
+		FROM visits WHERE student_id = ?
+		GROUP BY 1, 3` + addDailyStatistics
+)
+
+// ErasureResult is the number of rows erased from a table.
+type ErasureResult struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+// PersonalDataStore implements database operations for data subject requests.
+type PersonalDataStore struct {
+	db *bun.DB
+}
+
+// NewPersonalDataStore returns a PersonalDataStore.
+func NewPersonalDataStore(db *bun.DB) *PersonalDataStore {
+	return &PersonalDataStore{
+		db: db,
+	}
+}
+
+// GetStudentUserID returns the ID of the CustomUser of a Student.
+func (s *PersonalDataStore) GetStudentUserID(ctx context.Context, studentID int64) (int64, error) {
+	var userID int64
+	err := s.db.NewSelect().
+		Model((*models.Student)(nil)).
+		Column("custom_user_id").
+		Where("id = ?", studentID).
+		Scan(ctx, &userID)
+
+	return userID, err
+}
+
+// Export returns all data stored about a CustomUser, including the tag reads
+// attributed to them and their student data.
+func (s *PersonalDataStore) Export(ctx context.Context, userID int64) (*models.PersonalData, error) {
+	data := &models.PersonalData{
+		ExportedAt:          time.Now(),
+		User:                new(models.CustomUser),
+		Tags:                []models.UserTag{},
+		TagReads:            []models.TagRead{},
+		Visits:              []models.Visit{},
+		RoomVisits:          []models.RoomVisit{},
+		LocationTransitions: []models.LocationTransition{},
+		Feedback:            []models.Feedback{},
+		Ags:                 []models.Ag{},
+		LoginTokens:         []models.LoginToken{},
+		RateLimits:          []models.RateLimit{},
+		AuthEvents:          []models.AuthEvent{},
+	}
+
+	err := s.db.NewSelect().
+		Model(data.User).
+		Where("id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		Model(&data.Tags).
+		Where("custom_user_id = ?", userID).
+		Order("assigned_at").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		TableExpr("tags AS tag").
+		Column("tag.id", "tag.tag_id", "tag.reader_id", "tag.device_id", "tag.read_at").
+		Where(userTagReads, userID).
+		Order("tag.read_at").
+		Scan(ctx, &data.TagReads)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.User.AccountID != nil {
+		if err := s.exportLogins(ctx, data, *data.User.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	student := new(models.Student)
+	err = s.db.NewSelect().
+		Model(student).
+		Relation("Group").
+		Where("student.custom_user_id = ?", userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data.Student = student
+
+	err = s.db.NewSelect().
+		Model(&data.Visits).
+		Relation("Timespan").
+		Where("visit.student_id = ?", student.ID).
+		Order("visit.day", "visit.id").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		TableExpr("student_room_visits").
+		Column("id", "room_id", "student_id", "entry_time", "exit_time").
+		Where("student_id = ?", student.ID).
+		Order("entry_time").
+		Scan(ctx, &data.RoomVisits)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		Model(&data.LocationTransitions).
+		Where("student_id = ?", student.ID).
+		Order("at", "id").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		Model(&data.Feedback).
+		Where("student_id = ?", student.ID).
+		Order("day", "time").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.NewSelect().
+		Model(&data.Ags).
+		Where("ag.id IN (SELECT ag_id FROM student_ags WHERE student_id = ?)", student.ID).
+		Order("ag.name").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// exportLogins adds the login tokens, rate limits and audited login events of
+// the account of a user to data.
+func (s *PersonalDataStore) exportLogins(ctx context.Context, data *models.PersonalData, accountID int64) error {
+	keys, err := accountKeys(ctx, s.db, accountID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.NewSelect().
+		TableExpr("login_tokens").
+		Column("expiry", "attempts", "created_at").
+		Where("account_id = ?", accountID).
+		Order("created_at").
+		Scan(ctx, &data.LoginTokens)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.NewSelect().
+		TableExpr("auth_rate_limits").
+		Column("key", "failures", "window_start", "lockouts", "locked_until", "updated_at").
+		Where("key IN (?)", bun.In(keys)).
+		Order("key").
+		Scan(ctx, &data.RateLimits)
+	if err != nil {
+		return err
+	}
+
+	return s.db.NewSelect().
+		TableExpr("auth_audit_log").
+		Column("id", "event", "key", "ip", "details", "created_at").
+		Where("key IN (?)", bun.In(keys)).
+		Order("created_at", "id").
+		Scan(ctx, &data.AuthEvents)
+}
+
+// accountKeys returns the rate limit and audit log keys of an account, which
+// contain its email.
+func accountKeys(ctx context.Context, db bun.IDB, accountID int64) ([]string, error) {
+	acc := new(pwdless.Account)
+	err := db.NewSelect().
+		Model(acc).
+		Column("id", "email").
+		Where("id = ?", accountID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pwdless.AccountKeys(acc.ID, acc.Email), nil
+}
+
+// Erase deletes all data stored about a CustomUser in one transaction: the tag
+// reads attributed to them, their student data, tag assignments and login
+// account with its rate limits and audit log entries. Tag reads and visits are
+// aggregated into the daily statistics first, so the statistics stay intact. In
+// a dry run the transaction is rolled back.
+func (s *PersonalDataStore) Erase(ctx context.Context, userID int64, dryRun bool) ([]ErasureResult, error) {
+	user := new(models.CustomUser)
+	err := s.db.NewSelect().
+		Model(user).
+		Where("id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	specialist, err := s.db.NewSelect().
+		Model((*models.PedagogicalSpecialist)(nil)).
+		Where("custom_user_id = ?", userID).
+		Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if specialist {
+		return nil, ErrSpecialistErasure
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var studentID int64
+	err = tx.NewSelect().
+		Model((*models.Student)(nil)).
+		Column("id").
+		Where("custom_user_id = ?", userID).
+		Scan(ctx, &studentID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, aggregateUserTagReads, userID); err != nil {
+		return nil, err
+	}
+	if studentID != 0 {
+		for _, query := range []string{aggregateStudentRoomVisits, aggregateStudentVisits} {
+			if _, err := tx.ExecContext(ctx, query, studentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Rows are deleted explicitly rather than by cascade, to count them
+	deletes := []erasure{{"tags", "tags AS tag", userTagReads, userID}}
+	if studentID != 0 {
+		for _, table := range []string{"student_room_visits", "student_location_transitions", "visits", "feedbacks", "student_ags"} {
+			deletes = append(deletes, erasure{table, table, "student_id = ?", studentID})
+		}
+		deletes = append(deletes, erasure{"students", "students", "id = ?", studentID})
+	}
+	deletes = append(deletes,
+		erasure{"user_tags", "user_tags", "custom_user_id = ?", userID},
+		erasure{"custom_users", "custom_users", "id = ?", userID},
+	)
+	if user.AccountID != nil {
+		keys, err := accountKeys(ctx, tx, *user.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range []string{"tokens", "profiles", "login_tokens"} {
+			deletes = append(deletes, erasure{table, table, "account_id = ?", *user.AccountID})
+		}
+		for _, table := range []string{"auth_rate_limits", "auth_audit_log"} {
+			deletes = append(deletes, erasure{table, table, "key IN (?)", bun.In(keys)})
+		}
+		deletes = append(deletes, erasure{"accounts", "accounts", "id = ?", *user.AccountID})
+	}
+
+	results := make([]ErasureResult, 0, len(deletes))
+	for _, d := range deletes {
+		res, err := tx.NewDelete().
+			TableExpr(d.from).
+			Where(d.where, d.arg).
+			Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, ErasureResult{Table: d.table, Rows: int(rows)})
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	return results, tx.Commit()
+}
+
+// erasure describes the rows of a table erased with a user.
+type erasure struct {
+	table string
+	from  string
+	where string
+	arg   interface{}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/extra/bundebug"
+
+	"github.com/dhax/go-base/models"
 )
 
 // DBConn returns a postgres connection pool.
@@ -22,6 +24,14 @@ func DBConn() (*bun.DB, error) {
 
 	db := bun.NewDB(sqldb, pgdialect.New())
 
+	// m2m junction models, required before querying their relations
+	db.RegisterModel(
+		(*models.GroupSupervisor)(nil),
+		(*models.CombinedGroupGroup)(nil),
+		(*models.CombinedGroupSpecialist)(nil),
+		(*models.StudentAg)(nil),
+	)
+
 	if err := checkConn(db); err != nil {
 		return nil, err
 	}
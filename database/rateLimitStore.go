@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dhax/go-base/auth/pwdless"
+	"github.com/uptrace/bun"
+)
+
+// RateLimitStore implements database operations for authentication rate limiting.
+type RateLimitStore struct {
+	db *bun.DB
+}
+
+// NewRateLimitStore returns a RateLimitStore.
+func NewRateLimitStore(db *bun.DB) *RateLimitStore {
+	return &RateLimitStore{
+		db: db,
+	}
+}
+
+// GetRateLimit returns the rate limit state of key, or a zero state if none exists.
+func (s *RateLimitStore) GetRateLimit(key string) (*pwdless.RateLimitState, error) {
+	st := &pwdless.RateLimitState{Key: key}
+	err := s.db.NewSelect().
+		Model(st).
+		WherePK().
+		Scan(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		return &pwdless.RateLimitState{Key: key}, nil
+	}
+	return st, err
+}
+
+// UpdateRateLimit applies fn to the rate limit state of key within a transaction
+// locking the row, so concurrent attempts of multiple instances are counted correctly.
+func (s *RateLimitStore) UpdateRateLimit(key string, fn func(st *pwdless.RateLimitState)) (*pwdless.RateLimitState, error) {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// make sure the row exists to be locked
+	_, err = tx.NewInsert().
+		Model(&pwdless.RateLimitState{Key: key}).
+		On("CONFLICT (key) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &pwdless.RateLimitState{Key: key}
+	err = tx.NewSelect().
+		Model(st).
+		WherePK().
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fn(st)
+
+	_, err = tx.NewUpdate().
+		Model(st).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// PurgeRateLimits deletes rate limit states not updated since before that are not locked out.
+func (s *RateLimitStore) PurgeRateLimits(before time.Time) error {
+	_, err := s.db.NewDelete().
+		Model((*pwdless.RateLimitState)(nil)).
+		Where("updated_at < ?", before).
+		Where("locked_until IS NULL OR locked_until < ?", time.Now()).
+		Exec(context.Background())
+	return err
+}
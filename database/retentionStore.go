@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/uptrace/bun"
+)
+
+// RetentionPolicy provides the retention periods of the movement data and of the
+// logs referring to persons, a zero period keeps the data forever.
+type RetentionPolicy struct {
+	TagReads     time.Duration
+	RoomVisits   time.Duration
+	Visits       time.Duration
+	Timespans    time.Duration
+	SyncHistory  time.Duration
+	AuthAuditLog time.Duration
+	UnknownTags  time.Duration
+	// Aggregate keeps anonymised daily statistics of the pruned data
+	Aggregate bool
+}
+
+// NewRetentionPolicy returns the configured RetentionPolicy.
+func NewRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		TagReads:     viper.GetDuration("retention_tag_reads"),
+		RoomVisits:   viper.GetDuration("retention_room_visits"),
+		Visits:       viper.GetDuration("retention_visits"),
+		Timespans:    viper.GetDuration("retention_timespans"),
+		SyncHistory:  viper.GetDuration("retention_sync_history"),
+		AuthAuditLog: viper.GetDuration("retention_auth_audit_log"),
+		UnknownTags:  viper.GetDuration("retention_unknown_tags"),
+		Aggregate:    viper.GetBool("retention_aggregate"),
+	}
+}
+
+// PruneResult is the number of expired rows of a table.
+type PruneResult struct {
+	Table  string    `json:"table"`
+	Cutoff time.Time `json:"cutoff"` // rows before are expired
+	Rows   int       `json:"rows"`   // deleted, or to delete in a dry run
+}
+
+// retentionTable describes how expired rows of a table are found and aggregated.
+type retentionTable struct {
+	name  string
+	keep  time.Duration
+	where string // condition for rows expired before the cutoff
+	// aggregate inserts the daily statistics of the expired rows, none if empty
+	aggregate string
+}
+
+// cutoff returns the start of the UTC day the retention period before now
+// begins on, rows before are expired.
+func (t retentionTable) cutoff(now time.Time) time.Time {
+	return startOfDay(now.UTC().Add(-t.keep))
+}
+
+// addDailyStatistics adds the statistics of a pruning run to those of former runs.
+const addDailyStatistics = `
+	ON CONFLICT (day, source, subject) DO UPDATE SET
+		records = daily_statistics.records + EXCLUDED.records,
+		individuals = daily_statistics.individuals + EXCLUDED.individuals,
+		minutes = daily_statistics.minutes + EXCLUDED.minutes`
+
+// retentionTables returns the tables of the policy in pruning order. Visits go
+// before timespans, as timespans are deleted only once nothing refers to them.
+func (p RetentionPolicy) retentionTables() []retentionTable {
+	return []retentionTable{
+		{
+			name:  "tags",
+			keep:  p.TagReads,
+			where: "read_at < ?",
+			aggregate: `
+				INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+				SELECT read_at::date, 'tag_reads', reader_id, count(*), count(DISTINCT tag_id), 0
+				FROM tags WHERE read_at < ?
+				GROUP BY 1, 3` + addDailyStatistics,
+		},
+		{
+			name:  "student_room_visits",
+			keep:  p.RoomVisits,
+			where: "exit_time < ?",
+			aggregate: `
+				INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+				SELECT entry_time::date, 'room_visits', room_id::text, count(*), count(DISTINCT student_id),
+					COALESCE(sum(EXTRACT(EPOCH FROM exit_time - entry_time))::bigint / 60, 0)
+				FROM student_room_visits WHERE exit_time < ?
+				GROUP BY 1, 3` + addDailyStatistics,
+		},
+		{
+			name:  "visits",
+			keep:  p.Visits,
+			where: "day < ?",
+			aggregate: `
+				INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+				SELECT day::date, 'visits', room_id::text, count(*), count(DISTINCT student_id), 0
+				FROM visits WHERE day < ?
+				GROUP BY 1, 3` + addDailyStatistics,
+		},
+		{
+			name: "timespans",
+			keep: p.Timespans,
+			where: `endtime < ?
+				AND NOT EXISTS (SELECT 1 FROM visits WHERE visits.timespan_id = timespans.id)
+				AND NOT EXISTS (SELECT 1 FROM ag_times WHERE ag_times.timespan_id = timespans.id)
+				AND NOT EXISTS (SELECT 1 FROM ags WHERE ags.datespan_id = timespans.id)
+				AND NOT EXISTS (SELECT 1 FROM room_occupancies WHERE room_occupancies.timespan_id = timespans.id)`,
+		},
+		{
+			name:  "tauri_device_syncs",
+			keep:  p.SyncHistory,
+			where: "sync_at < ?",
+			aggregate: `
+				INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes)
+				SELECT sync_at::date, 'device_syncs', device_id, count(*), 0, 0
+				FROM tauri_device_syncs WHERE sync_at < ?
+				GROUP BY 1, 3` + addDailyStatistics,
+		},
+		{
+			// logins and lockouts carry emails and client IPs
+			name:  "auth_audit_log",
+			keep:  p.AuthAuditLog,
+			where: "created_at < ?",
+		},
+		{
+			// tags not assigned yet, last read by children not registered
+			name:  "rfid_unknown_tags",
+			keep:  p.UnknownTags,
+			where: "last_seen_at < ?",
+		},
+	}
+}
+
+// RetentionStore implements database operations for the retention of movement data.
+type RetentionStore struct {
+	db *bun.DB
+}
+
+// NewRetentionStore returns a RetentionStore.
+func NewRetentionStore(db *bun.DB) *RetentionStore {
+	return &RetentionStore{
+		db: db,
+	}
+}
+
+// TryLock takes the lock of the prune job, so expired data is pruned by one
+// instance at a time.
+func (s *RetentionStore) TryLock(ctx context.Context) (unlock func(), ok bool, err error) {
+	return TryLock(ctx, s.db, "prune")
+}
+
+// Prune deletes the rows expired at now under the policy, aggregating them into
+// daily statistics first if enabled. Whole days are pruned, the cutoff is the
+// start of the UTC day the retention period ends. In a dry run the expired rows
+// are counted only.
+func (s *RetentionStore) Prune(ctx context.Context, p RetentionPolicy, now time.Time, dryRun bool) ([]PruneResult, error) {
+	var results []PruneResult
+	for _, t := range p.retentionTables() {
+		if t.keep <= 0 {
+			continue
+		}
+
+		res := PruneResult{Table: t.name, Cutoff: t.cutoff(now)}
+		var err error
+		if dryRun {
+			res.Rows, err = s.db.NewSelect().
+				TableExpr(t.name).
+				Where(t.where, res.Cutoff).
+				Count(ctx)
+		} else {
+			res.Rows, err = s.prune(ctx, t, res.Cutoff, p.Aggregate)
+		}
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// prune aggregates and deletes the expired rows of a table in one transaction.
+func (s *RetentionStore) prune(ctx context.Context, t retentionTable, cutoff time.Time, aggregate bool) (int, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if aggregate && t.aggregate != "" {
+		if _, err := tx.ExecContext(ctx, t.aggregate, cutoff); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := tx.NewDelete().
+		TableExpr(t.name).
+		Where(t.where, cutoff).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), tx.Commit()
+}
+
+// startOfDay returns midnight of the day of t.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
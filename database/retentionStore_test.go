@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// recorder is a database/sql driver recording the queries built by bun. Every
+// statement affects, and every count returns, rows rows.
+type recorder struct {
+	mu      sync.Mutex
+	queries []string
+	rows    int64
+}
+
+func newRecorderDB(rows int64) (*bun.DB, *recorder) {
+	rec := &recorder{rows: rows}
+	return bun.NewDB(sql.OpenDB(rec), pgdialect.New()), rec
+}
+
+func (rec *recorder) record(query string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.queries = append(rec.queries, strings.Join(strings.Fields(query), " "))
+}
+
+func (rec *recorder) Connect(context.Context) (driver.Conn, error) { return rec, nil }
+func (rec *recorder) Driver() driver.Driver                        { return nil }
+
+func (rec *recorder) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (rec *recorder) Close() error                        { return nil }
+func (rec *recorder) Begin() (driver.Tx, error) {
+	rec.record("BEGIN")
+	return rec, nil
+}
+func (rec *recorder) Commit() error {
+	rec.record("COMMIT")
+	return nil
+}
+func (rec *recorder) Rollback() error {
+	rec.record("ROLLBACK")
+	return nil
+}
+
+func (rec *recorder) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	rec.record(query)
+	return driver.RowsAffected(rec.rows), nil
+}
+
+func (rec *recorder) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	rec.record(query)
+	return &recorderRows{count: rec.rows}, nil
+}
+
+// recorderRows is a single row with a single count column.
+type recorderRows struct {
+	count int64
+	done  bool
+}
+
+func (r *recorderRows) Columns() []string { return []string{"count"} }
+func (r *recorderRows) Close() error      { return nil }
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.count
+	return nil
+}
+
+func TestRetentionTablesCutoff(t *testing.T) {
+	day := 24 * time.Hour
+	p := RetentionPolicy{
+		TagReads:     90 * day,
+		RoomVisits:   365 * day,
+		Visits:       365 * day,
+		Timespans:    365 * day,
+		SyncHistory:  30 * day,
+		AuthAuditLog: 180 * day,
+		UnknownTags:  7 * day,
+	}
+	// shortly after midnight in Berlin, still the day before in UTC
+	now := time.Date(2024, 6, 15, 1, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	cutoffs := map[string]time.Time{}
+	for _, table := range p.retentionTables() {
+		cutoffs[table.name] = table.cutoff(now)
+	}
+
+	assert.Equal(t, map[string]time.Time{
+		"tags":                time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+		"student_room_visits": time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC),
+		"visits":              time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC),
+		"timespans":           time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC),
+		"tauri_device_syncs":  time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+		"auth_audit_log":      time.Date(2023, 12, 17, 0, 0, 0, 0, time.UTC),
+		"rfid_unknown_tags":   time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC),
+	}, cutoffs)
+}
+
+func TestPruneDryRun(t *testing.T) {
+	db, rec := newRecorderDB(4)
+	p := RetentionPolicy{TagReads: 90 * 24 * time.Hour, AuthAuditLog: 180 * 24 * time.Hour, Aggregate: true}
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	results, err := NewRetentionStore(db).Prune(context.Background(), p, now, true)
+
+	assert.NoError(t, err)
+	// Tables without a retention period are kept
+	assert.Equal(t, []PruneResult{
+		{Table: "tags", Cutoff: time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), Rows: 4},
+		{Table: "auth_audit_log", Cutoff: time.Date(2023, 12, 18, 0, 0, 0, 0, time.UTC), Rows: 4},
+	}, results)
+	// Expired rows are counted only, neither aggregated nor deleted
+	assert.Equal(t, []string{
+		`SELECT count(*) FROM tags WHERE (read_at < '2024-03-17 00:00:00+00:00')`,
+		`SELECT count(*) FROM auth_audit_log WHERE (created_at < '2023-12-18 00:00:00+00:00')`,
+	}, rec.queries)
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name      string
+		aggregate bool
+		queries   []string
+	}{
+		{"aggregate", true, []string{
+			"BEGIN",
+			"INSERT INTO daily_statistics (day, source, subject, records, individuals, minutes) SELECT read_at::date, 'tag_reads', reader_id, count(*), count(DISTINCT tag_id), 0 FROM tags WHERE read_at < '2024-03-17 00:00:00+00:00' GROUP BY 1, 3 ON CONFLICT (day, source, subject) DO UPDATE SET records = daily_statistics.records + EXCLUDED.records, individuals = daily_statistics.individuals + EXCLUDED.individuals, minutes = daily_statistics.minutes + EXCLUDED.minutes",
+			"DELETE FROM tags WHERE (read_at < '2024-03-17 00:00:00+00:00')",
+			"COMMIT",
+			"BEGIN",
+			"DELETE FROM rfid_unknown_tags WHERE (last_seen_at < '2024-06-08 00:00:00+00:00')",
+			"COMMIT",
+		}},
+		{"without aggregation", false, []string{
+			"BEGIN",
+			"DELETE FROM tags WHERE (read_at < '2024-03-17 00:00:00+00:00')",
+			"COMMIT",
+			"BEGIN",
+			"DELETE FROM rfid_unknown_tags WHERE (last_seen_at < '2024-06-08 00:00:00+00:00')",
+			"COMMIT",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, rec := newRecorderDB(3)
+			p := RetentionPolicy{TagReads: 90 * 24 * time.Hour, UnknownTags: 7 * 24 * time.Hour, Aggregate: tt.aggregate}
+			now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+			results, err := NewRetentionStore(db).Prune(context.Background(), p, now, false)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []PruneResult{
+				{Table: "tags", Cutoff: time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), Rows: 3},
+				{Table: "rfid_unknown_tags", Cutoff: time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC), Rows: 3},
+			}, results)
+			assert.Equal(t, tt.queries, rec.queries)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/dhax/go-base/auth/jwt"
+	"github.com/uptrace/bun"
+)
+
+// listSessions returns the current refresh tokens of all login sessions of an account.
+func listSessions(db bun.IDB, accountID int) ([]jwt.Token, error) {
+	var tokens []jwt.Token
+	err := db.NewSelect().
+		Model(&tokens).
+		Where("account_id = ?", accountID).
+		Where("rotated_at IS NULL").
+		Where("expiry > now()").
+		Order("updated_at DESC").
+		Scan(context.Background())
+	return tokens, err
+}
+
+// deleteSession deletes all refresh tokens of the login session given by its current token ID.
+// It returns sql.ErrNoRows if the account has no such session.
+func deleteSession(db bun.IDB, accountID, id int) error {
+	res, err := db.NewDelete().
+		Model((*jwt.Token)(nil)).
+		Where("account_id = ?", accountID).
+		Where("family = (?)", db.NewSelect().
+			Model((*jwt.Token)(nil)).
+			Column("family").
+			Where("id = ?", id).
+			Where("account_id = ?", accountID)).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// deleteSessions deletes all refresh tokens of an account.
+func deleteSessions(db bun.IDB, accountID int) error {
+	_, err := db.NewDelete().
+		Model((*jwt.Token)(nil)).
+		Where("account_id = ?", accountID).
+		Exec(context.Background())
+	return err
+}
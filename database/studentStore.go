@@ -78,6 +78,10 @@ func (s *StudentStore) CreateStudent(ctx context.Context, student *models.Studen
 		return errors.New("a student already exists for this custom user")
 	}
 
+	if student.Location == "" {
+		student.Location = models.LocationAbsent
+	}
+
 	// Create the student
 	_, err = tx.NewInsert().
 		Model(student).
@@ -94,10 +98,12 @@ func (s *StudentStore) CreateStudent(ctx context.Context, student *models.Studen
 	return nil
 }
 
-// UpdateStudent updates an existing Student
+// UpdateStudent updates an existing Student. The location is left untouched,
+// use TransitionStudentLocation to change it.
 func (s *StudentStore) UpdateStudent(ctx context.Context, student *models.Student) error {
 	_, err := s.db.NewUpdate().
 		Model(student).
+		ExcludeColumn("location", "location_room_id", "location_since").
 		WherePK().
 		Exec(ctx)
 
@@ -128,13 +134,29 @@ func (s *StudentStore) ListStudents(ctx context.Context, filters map[string]inte
 		query = query.Where("student.group_id = ?", groupID)
 	}
 
+	// Restrict to the groups accessible by the requesting specialist
+	if groupIDs, ok := filters["group_ids"].([]int64); ok {
+		if len(groupIDs) == 0 {
+			return []models.Student{}, nil
+		}
+		query = query.Where("student.group_id IN (?)", bun.In(groupIDs))
+	}
+
 	if searchTerm, ok := filters["search"].(string); ok && searchTerm != "" {
 		query = query.Where("custom_user.first_name ILIKE ? OR custom_user.second_name ILIKE ?",
 			"%"+searchTerm+"%", "%"+searchTerm+"%")
 	}
 
 	if inHouse, ok := filters["in_house"].(bool); ok {
-		query = query.Where("student.in_house = ?", inHouse)
+		if inHouse {
+			query = query.Where("student.location IN (?)", bun.In(models.InHouseLocations()))
+		} else {
+			query = query.Where("student.location NOT IN (?)", bun.In(models.InHouseLocations()))
+		}
+	}
+
+	if location, ok := filters["location"].(models.LocationState); ok && location != "" {
+		query = query.Where("student.location = ?", location)
 	}
 
 	err := query.OrderExpr("custom_user.first_name ASC").
@@ -147,45 +169,104 @@ func (s *StudentStore) ListStudents(ctx context.Context, filters map[string]inte
 	return students, nil
 }
 
-// UpdateStudentLocation updates a student's location flags (in_house, wc, school_yard)
-func (s *StudentStore) UpdateStudentLocation(ctx context.Context, id int64, locations map[string]bool) error {
+// TransitionStudentLocation moves a student to the location of t and records the
+// transition. It returns models.ErrInvalidTransition if the state machine does not
+// allow the change. If the student already is at the location nothing is recorded
+// and t.ID remains zero.
+func (s *StudentStore) TransitionStudentLocation(ctx context.Context, t *models.LocationTransition) error {
 	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	student, err := s.GetStudentByID(ctx, id)
+	student := new(models.Student)
+	err = tx.NewSelect().
+		Model(student).
+		Where("id = ?", t.StudentID).
+		For("UPDATE").
+		Scan(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Update student location flags
-	if inHouse, ok := locations["in_house"]; ok {
-		student.InHouse = inHouse
+	if t.ToLocation != models.LocationRoom {
+		t.RoomID = nil
 	}
-	if wc, ok := locations["wc"]; ok {
-		student.WC = wc
+	if student.Location == t.ToLocation && equalInt64Ptr(student.LocationRoomID, t.RoomID) {
+		return nil
 	}
-	if schoolYard, ok := locations["school_yard"]; ok {
-		student.SchoolYard = schoolYard
+
+	t.FromLocation = student.Location
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t.CreatedAt = now
+	if t.At.IsZero() {
+		t.At = now
 	}
 
+	student.Location = t.ToLocation
+	student.LocationRoomID = t.RoomID
+	student.LocationSince = t.At
+	student.ModifiedAt = now
+
 	_, err = tx.NewUpdate().
 		Model(student).
-		Column("in_house", "wc", "school_yard", "modified_at").
+		Column("location", "location_room_id", "location_since", "modified_at").
 		WherePK().
 		Exec(ctx)
-
 	if err != nil {
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	if _, err := tx.NewInsert().Model(t).Exec(ctx); err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// GetStudentLocationAt returns the last location transition of a student at or before at,
+// or sql.ErrNoRows if the location was never recorded before.
+func (s *StudentStore) GetStudentLocationAt(ctx context.Context, studentID int64, at time.Time) (*models.LocationTransition, error) {
+	t := new(models.LocationTransition)
+	err := s.db.NewSelect().
+		Model(t).
+		Where("student_id = ?", studentID).
+		Where("at <= ?", at).
+		Order("at DESC", "id DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetStudentLocationHistory returns the location transitions of a student between from and to.
+func (s *StudentStore) GetStudentLocationHistory(ctx context.Context, studentID int64, from, to time.Time) ([]models.LocationTransition, error) {
+	var transitions []models.LocationTransition
+	err := s.db.NewSelect().
+		Model(&transitions).
+		Where("student_id = ?", studentID).
+		Where("at >= ?", from).
+		Where("at < ?", to).
+		Order("at ASC", "id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func equalInt64Ptr(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
 // CreateStudentVisit creates a new Visit record for a student
@@ -323,7 +404,8 @@ func (s *StudentStore) GetStudentAsList(ctx context.Context, id int64) (*models.
 		Name:        student.CustomUser.FirstName + " " + student.CustomUser.SecondName,
 		SchoolClass: student.SchoolClass,
 		GroupName:   student.Group.Name,
-		InHouse:     student.InHouse,
+		InHouse:     student.Location.InHouse(),
+		Location:    student.Location,
 	}
 
 	return studentList, nil
@@ -407,3 +489,58 @@ func (s *StudentStore) GetRoomOccupancyByDeviceID(ctx context.Context, deviceID
 		TimespanID: roomOccupancy.TimespanID,
 	}, nil
 }
+
+// GetAccessibleGroupIDs returns the IDs of all groups the pedagogical specialist with the given
+// account ID may access: groups they supervise and groups of active combined groups granting
+// them access, either explicitly as access specialist or through the combined group's access policy.
+func (s *StudentStore) GetAccessibleGroupIDs(ctx context.Context, accountID int64) ([]int64, error) {
+	var groupIDs []int64
+
+	// Groups supervised by the specialist
+	supervised := s.db.NewSelect().
+		TableExpr("group_supervisors AS gs").
+		Column("gs.group_id").
+		Join("JOIN pedagogical_specialists AS ps ON ps.id = gs.specialist_id").
+		Where("ps.user_id = ?", accountID)
+
+	// Supervisors of a given group expression, used for the access policies
+	supervises := func(groupExpr string) string {
+		return "EXISTS (SELECT 1 FROM group_supervisors AS sgs " +
+			"JOIN pedagogical_specialists AS sps ON sps.id = sgs.specialist_id " +
+			"WHERE sgs.group_id = " + groupExpr + " AND sps.user_id = ?0)"
+	}
+
+	// Groups of active combined groups the specialist has access to
+	combined := s.db.NewSelect().
+		TableExpr("combined_group_groups AS cgg").
+		Column("cgg.group_id").
+		Join("JOIN combined_groups AS cg ON cg.id = cgg.combinedgroup_id").
+		Where("cg.is_active = TRUE").
+		Where("cg.valid_until IS NULL OR cg.valid_until > ?", time.Now()).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				// Specialists granted access explicitly, the only way for the "manual" policy
+				Where("EXISTS (SELECT 1 FROM combined_group_specialists AS cgs "+
+					"JOIN pedagogical_specialists AS aps ON aps.id = cgs.specialist_id "+
+					"WHERE cgs.combinedgroup_id = cg.id AND aps.user_id = ?0)", accountID).
+				// "all": supervisors of any of the combined groups
+				WhereOr("cg.access_policy = 'all' AND "+supervises("ANY (SELECT m.group_id FROM combined_group_groups AS m WHERE m.combinedgroup_id = cg.id)"), accountID).
+				// "first": supervisors of the first group added to the combined group
+				WhereOr("cg.access_policy = 'first' AND "+supervises("(SELECT f.group_id FROM combined_group_groups AS f WHERE f.combinedgroup_id = cg.id ORDER BY f.id LIMIT 1)"), accountID).
+				// "specific": supervisors of the combined group's specific group
+				WhereOr("cg.access_policy = 'specific' AND "+supervises("cg.specific_group_id"), accountID)
+		})
+
+	err := s.db.NewSelect().
+		With("supervised", supervised).
+		With("combined", combined).
+		TableExpr("(SELECT group_id FROM supervised UNION SELECT group_id FROM combined) AS accessible").
+		Column("group_id").
+		Scan(ctx, &groupIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return groupIDs, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/dhax/go-base/auth/pwdless"
 	"github.com/dhax/go-base/models"
@@ -37,19 +39,42 @@ func (s *UserStore) GetCustomUserByID(ctx context.Context, id int64) (*models.Cu
 	return user, nil
 }
 
-// GetCustomUserByTagID retrieves a CustomUser by RFID tag ID
+// GetCustomUserByTagID retrieves the CustomUser currently assigned the RFID tag.
+// For lost or blocked tags the user is returned with models.ErrTagBlocked, for
+// tags outside their validity period with models.ErrTagNotValid.
 func (s *UserStore) GetCustomUserByTagID(ctx context.Context, tagID string) (*models.CustomUser, error) {
-	user := new(models.CustomUser)
+	tag := new(models.UserTag)
 	err := s.db.NewSelect().
-		Model(user).
-		Where("tag_id = ?", tagID).
+		Model(tag).
+		Relation("CustomUser").
+		Where("user_tag.tag_id = ?", tagID).
+		Where("user_tag.unassigned_at IS NULL").
 		Scan(ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return tag.CustomUser, tag.Check(time.Now())
+}
+
+// GetTagOwnerAt retrieves the CustomUser the RFID tag was assigned to at the
+// given time, whatever the status of the tag, e.g. to attribute old reads
+func (s *UserStore) GetTagOwnerAt(ctx context.Context, tagID string, at time.Time) (*models.CustomUser, error) {
+	tag := new(models.UserTag)
+	err := s.db.NewSelect().
+		Model(tag).
+		Relation("CustomUser").
+		Where("user_tag.tag_id = ?", tagID).
+		Where("user_tag.assigned_at <= ?", at).
+		Where("user_tag.unassigned_at IS NULL OR user_tag.unassigned_at > ?", at).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tag.CustomUser, nil
 }
 
 // GetCustomUserByAccountID retrieves a CustomUser by linked Account ID
@@ -67,13 +92,26 @@ func (s *UserStore) GetCustomUserByAccountID(ctx context.Context, accountID int6
 	return user, nil
 }
 
-// CreateCustomUser creates a new CustomUser
+// CreateCustomUser creates a new CustomUser, a tag ID given is assigned as card
 func (s *UserStore) CreateCustomUser(ctx context.Context, user *models.CustomUser) error {
-	_, err := s.db.NewInsert().
-		Model(user).
-		Exec(ctx)
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	return err
+	if _, err := tx.NewInsert().Model(user).Exec(ctx); err != nil {
+		return err
+	}
+
+	if user.TagID != nil && *user.TagID != "" {
+		err = assignTag(ctx, tx, &models.UserTag{TagID: *user.TagID, CustomUserID: user.ID})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // UpdateCustomUser updates an existing CustomUser
@@ -96,17 +134,217 @@ func (s *UserStore) DeleteCustomUser(ctx context.Context, id int64) error {
 	return err
 }
 
-// UpdateTagID updates the RFID tag ID for a user
+// UpdateTagID replaces the RFID card of a user. The assignment of the previous
+// card ends and is kept in the tag history, other tags of the user remain assigned.
 func (s *UserStore) UpdateTagID(ctx context.Context, userID int64, tagID string) error {
-	_, err := s.db.NewUpdate().
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	assigned, err := tx.NewSelect().
+		Model((*models.UserTag)(nil)).
+		Where("tag_id = ?", tagID).
+		Where("custom_user_id = ?", userID).
+		Where("unassigned_at IS NULL").
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !assigned {
+		_, err = tx.NewUpdate().
+			Model((*models.UserTag)(nil)).
+			Set("unassigned_at = ?", time.Now()).
+			Set("updated_at = ?", time.Now()).
+			Where("custom_user_id = ?", userID).
+			Where("kind = ?", models.TagKindCard).
+			Where("unassigned_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = assignTag(ctx, tx, &models.UserTag{TagID: tagID, CustomUserID: userID})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.NewUpdate().
 		Model((*models.CustomUser)(nil)).
 		Set("tag_id = ?", tagID).
 		Where("id = ?", userID).
 		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListUserTags returns the tags of a user, current and past assignments, latest first
+func (s *UserStore) ListUserTags(ctx context.Context, userID int64) ([]models.UserTag, error) {
+	var tags []models.UserTag
+	err := s.db.NewSelect().
+		Model(&tags).
+		Where("custom_user_id = ?", userID).
+		Order("assigned_at DESC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
 
+// GetTagHistory returns all assignments of a tag with their users, latest first
+func (s *UserStore) GetTagHistory(ctx context.Context, tagID string) ([]models.UserTag, error) {
+	var tags []models.UserTag
+	err := s.db.NewSelect().
+		Model(&tags).
+		Relation("CustomUser").
+		Where("user_tag.tag_id = ?", tagID).
+		Order("user_tag.assigned_at DESC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// AssignTag assigns a tag to a user in addition to the tags the user holds. It
+// returns models.ErrTagAssigned if the tag is assigned to a user already.
+func (s *UserStore) AssignTag(ctx context.Context, tag *models.UserTag) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := assignTag(ctx, tx, tag); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// assignTag inserts the assignment of a tag within a transaction, defaulting to
+// an active card valid from now
+func assignTag(ctx context.Context, tx bun.Tx, tag *models.UserTag) error {
+	now := time.Now()
+	if tag.Kind == "" {
+		tag.Kind = models.TagKindCard
+	}
+	if tag.Status == "" {
+		tag.Status = models.TagStatusActive
+	}
+	if tag.ValidFrom.IsZero() {
+		tag.ValidFrom = now
+	}
+	tag.AssignedAt, tag.UnassignedAt = now, nil
+	tag.CreatedAt, tag.UpdatedAt = now, now
+
+	if err := tag.Validate(); err != nil {
+		return err
+	}
+
+	assigned, err := tx.NewSelect().
+		Model((*models.UserTag)(nil)).
+		Where("tag_id = ?", tag.TagID).
+		Where("unassigned_at IS NULL").
+		Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if assigned {
+		return models.ErrTagAssigned
+	}
+
+	_, err = tx.NewInsert().Model(tag).Exec(ctx)
 	return err
 }
 
+// SetTagStatus changes the status of a tag assigned to a user, e.g. to block a
+// lost card. It returns sql.ErrNoRows if the tag is not assigned to the user.
+func (s *UserStore) SetTagStatus(ctx context.Context, userID int64, tagID, status, note string) error {
+	switch status {
+	case models.TagStatusActive, models.TagStatusLost, models.TagStatusBlocked:
+	default:
+		return fmt.Errorf("invalid tag status: %q", status)
+	}
+
+	res, err := s.db.NewUpdate().
+		Model((*models.UserTag)(nil)).
+		Set("status = ?", status).
+		Set("note = ?", note).
+		Set("updated_at = ?", time.Now()).
+		Where("custom_user_id = ?", userID).
+		Where("tag_id = ?", tagID).
+		Where("unassigned_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UnassignTag ends the assignment of a tag to a user, keeping it in the history.
+// It returns sql.ErrNoRows if the tag is not assigned to the user.
+func (s *UserStore) UnassignTag(ctx context.Context, userID int64, tagID string) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	res, err := tx.NewUpdate().
+		Model((*models.UserTag)(nil)).
+		Set("unassigned_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("custom_user_id = ?", userID).
+		Where("tag_id = ?", tagID).
+		Where("unassigned_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.NewUpdate().
+		Model((*models.CustomUser)(nil)).
+		Set("tag_id = NULL").
+		Where("id = ?", userID).
+		Where("tag_id = ?", tagID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // ListCustomUsers returns a list of all CustomUsers
 func (s *UserStore) ListCustomUsers(ctx context.Context) ([]models.CustomUser, error) {
 	var users []models.CustomUser
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+)
+
+// PersonalData is the data stored about a user, exported on a data subject
+// access request. Student data is included if the user is a student.
+type PersonalData struct {
+	ExportedAt          time.Time            `json:"exported_at"`
+	User                *CustomUser          `json:"user"`
+	Student             *Student             `json:"student,omitempty"` // with the group
+	Tags                []UserTag            `json:"tags"`
+	TagReads            []TagRead            `json:"tag_reads"`
+	Visits              []Visit              `json:"visits"`
+	RoomVisits          []RoomVisit          `json:"room_visits"`
+	LocationTransitions []LocationTransition `json:"location_transitions"`
+	Feedback            []Feedback           `json:"feedback"`
+	Ags                 []Ag                 `json:"ags"`
+	// Login data of the user's account
+	LoginTokens []LoginToken `json:"login_tokens"`
+	RateLimits  []RateLimit  `json:"rate_limits"`
+	AuthEvents  []AuthEvent  `json:"auth_events"`
+}
+
+// The types below are exports of rows of tables modelled in other packages, e.g.
+// the tags table of package rfid. They are scanned from the columns selected on
+// export and are no bun models.
+
+// TagRead is a read of an RFID tag while it was assigned to a user.
+type TagRead struct {
+	ID       int64     `json:"id"`
+	TagID    string    `json:"tag_id"`
+	ReaderID string    `json:"reader_id"`
+	DeviceID string    `json:"device_id,omitempty"`
+	ReadAt   time.Time `json:"read_at"`
+}
+
+// RoomVisit is the stay of a student in a room, recorded by the room readers.
+type RoomVisit struct {
+	ID        int64      `json:"id"`
+	RoomID    int64      `json:"room_id"`
+	StudentID int64      `json:"student_id"`
+	EntryTime time.Time  `json:"entry_time"`
+	ExitTime  *time.Time `json:"exit_time,omitempty"`
+}
+
+// LoginToken is a login token sent to a user by email, without the token itself.
+type LoginToken struct {
+	Expiry    time.Time `json:"expiry"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RateLimit is the state of the login rate limit of a user's email.
+type RateLimit struct {
+	Key         string     `json:"key"`
+	Failures    int        `json:"failures"`
+	WindowStart time.Time  `json:"window_start"`
+	Lockouts    int        `json:"lockouts"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// AuthEvent is an audited login event of a user, e.g. a lockout.
+type AuthEvent struct {
+	ID        int64     `json:"id"`
+	Event     string    `json:"event"`
+	Key       string    `json:"key"`
+	IP        string    `json:"ip,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
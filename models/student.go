@@ -10,20 +10,20 @@ import (
 
 // Student represents a student in the system
 type Student struct {
-	ID           int64       `json:"id" bun:"id,pk,autoincrement"`
-	SchoolClass  string      `json:"school_class" bun:"school_class,notnull"`
-	Bus          bool        `json:"bus" bun:"bus,notnull,default:false"`
-	NameLG       string      `json:"name_lg" bun:"name_lg,notnull"`       // Legal Guardian name
-	ContactLG    string      `json:"contact_lg" bun:"contact_lg,notnull"` // Legal Guardian contact
-	InHouse      bool        `json:"in_house" bun:"in_house,notnull,default:false"`
-	WC           bool        `json:"wc" bun:"wc,notnull,default:false"`
-	SchoolYard   bool        `json:"school_yard" bun:"school_yard,notnull,default:false"`
-	CustomUserID int64       `json:"custom_user_id" bun:"custom_user_id,notnull"`
-	CustomUser   *CustomUser `json:"custom_user,omitempty" bun:"rel:belongs-to,join:custom_user_id=id"`
-	GroupID      int64       `json:"group_id" bun:"group_id,notnull"`
-	Group        *Group      `json:"group,omitempty" bun:"rel:belongs-to,join:group_id=id"`
-	CreatedAt    time.Time   `json:"created_at" bun:"created_at,notnull"`
-	ModifiedAt   time.Time   `json:"updated_at" bun:"modified_at,notnull"`
+	ID             int64         `json:"id" bun:"id,pk,autoincrement"`
+	SchoolClass    string        `json:"school_class" bun:"school_class,notnull"`
+	Bus            bool          `json:"bus" bun:"bus,notnull,default:false"`
+	NameLG         string        `json:"name_lg" bun:"name_lg,notnull"`                    // Legal Guardian name
+	ContactLG      string        `json:"contact_lg" bun:"contact_lg,notnull"`              // Legal Guardian contact
+	Location       LocationState `json:"location" bun:"location,notnull,default:'absent'"` // changed by LocationTransition only
+	LocationRoomID *int64        `json:"location_room_id,omitempty" bun:"location_room_id"`
+	LocationSince  time.Time     `json:"location_since" bun:"location_since,nullzero"`
+	CustomUserID   int64         `json:"custom_user_id" bun:"custom_user_id,notnull"`
+	CustomUser     *CustomUser   `json:"custom_user,omitempty" bun:"rel:belongs-to,join:custom_user_id=id"`
+	GroupID        int64         `json:"group_id" bun:"group_id,notnull"`
+	Group          *Group        `json:"group,omitempty" bun:"rel:belongs-to,join:group_id=id"`
+	CreatedAt      time.Time     `json:"created_at" bun:"created_at,notnull"`
+	ModifiedAt     time.Time     `json:"updated_at" bun:"modified_at,notnull"`
 }
 
 // BeforeInsert hook executed before database insert operation.
@@ -31,6 +31,9 @@ func (s *Student) BeforeInsert(db *bun.DB) error {
 	now := time.Now()
 	s.CreatedAt = now
 	s.ModifiedAt = now
+	if s.Location == "" {
+		s.Location = LocationAbsent
+	}
 	return s.Validate()
 }
 
@@ -51,11 +54,12 @@ func (s *Student) Validate() error {
 
 // StudentList represents a simplified view of a student for list displays
 type StudentList struct {
-	ID          int64  `json:"id"`
-	Name        string `json:"name"`
-	SchoolClass string `json:"school_class"`
-	GroupName   string `json:"group_name"`
-	InHouse     bool   `json:"in_house"`
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	SchoolClass string        `json:"school_class"`
+	GroupName   string        `json:"group_name"`
+	InHouse     bool          `json:"in_house"`
+	Location    LocationState `json:"location"`
 }
 
 // Visit represents a record of a student's visit to a room
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrInvalidTransition is returned for location changes not allowed by the state machine.
+var ErrInvalidTransition = errors.New("invalid location transition")
+
+// LocationState is the current whereabouts of a student.
+type LocationState string
+
+// The list of student location states.
+const (
+	LocationAbsent     LocationState = "absent"
+	LocationInHouse    LocationState = "in_house"
+	LocationRoom       LocationState = "room"
+	LocationWC         LocationState = "wc"
+	LocationSchoolYard LocationState = "school_yard"
+	LocationBus        LocationState = "bus"
+	LocationPickedUp   LocationState = "picked_up"
+)
+
+// locationTransitions lists the states reachable from each state. Leaving the
+// premises is possible from every location on the premises, arriving only from
+// outside. Moving from one room to another is allowed without passing the hallway.
+var locationTransitions = map[LocationState][]LocationState{
+	LocationAbsent:     {LocationInHouse, LocationRoom, LocationBus},
+	LocationBus:        {LocationInHouse, LocationRoom, LocationAbsent},
+	LocationInHouse:    {LocationRoom, LocationWC, LocationSchoolYard, LocationBus, LocationPickedUp, LocationAbsent},
+	LocationRoom:       {LocationInHouse, LocationRoom, LocationWC, LocationSchoolYard, LocationBus, LocationPickedUp, LocationAbsent},
+	LocationWC:         {LocationInHouse, LocationRoom, LocationSchoolYard, LocationAbsent},
+	LocationSchoolYard: {LocationInHouse, LocationRoom, LocationWC, LocationBus, LocationPickedUp, LocationAbsent},
+	LocationPickedUp:   {LocationAbsent, LocationInHouse, LocationRoom},
+}
+
+// Valid returns true for known location states.
+func (l LocationState) Valid() bool {
+	_, ok := locationTransitions[l]
+	return ok
+}
+
+// InHouse returns true if the student is inside the building.
+func (l LocationState) InHouse() bool {
+	return l == LocationInHouse || l == LocationRoom || l == LocationWC
+}
+
+// CanTransition returns true if a student may move from l to next.
+func (l LocationState) CanTransition(next LocationState) bool {
+	for _, s := range locationTransitions[l] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// InHouseLocations returns the states counting as inside the building.
+func InHouseLocations() []LocationState {
+	return []LocationState{LocationInHouse, LocationRoom, LocationWC}
+}
+
+// LocationTransition records a change of a student's location.
+type LocationTransition struct {
+	ID           int64         `json:"id" bun:"id,pk,autoincrement"`
+	StudentID    int64         `json:"student_id" bun:"student_id,notnull"`
+	FromLocation LocationState `json:"from_location" bun:"from_location,notnull"`
+	ToLocation   LocationState `json:"to_location" bun:"to_location,notnull"`
+	RoomID       *int64        `json:"room_id,omitempty" bun:"room_id"`
+	Source       string        `json:"source" bun:"source,notnull"`         // reader or device ID, or "api"
+	ActorID      *int64        `json:"actor_id,omitempty" bun:"actor_id"`   // account of staff changing the location manually
+	At           time.Time     `json:"at" bun:"at,notnull"`                 // time the transition happened
+	CreatedAt    time.Time     `json:"created_at" bun:"created_at,notnull"` // time the transition was recorded
+
+	bun.BaseModel `bun:"table:student_location_transitions"`
+}
+
+// BeforeInsert hook executed before database insert operation.
+func (t *LocationTransition) BeforeInsert(db *bun.DB) error {
+	t.CreatedAt = time.Now()
+	if t.At.IsZero() {
+		t.At = t.CreatedAt
+	}
+	return nil
+}
+
+// Validate checks the transition against the state machine.
+func (t *LocationTransition) Validate() error {
+	if !t.ToLocation.Valid() {
+		return fmt.Errorf("%w: unknown location %q", ErrInvalidTransition, t.ToLocation)
+	}
+	if t.ToLocation == LocationRoom && t.RoomID == nil {
+		return fmt.Errorf("%w: room_id required for location %q", ErrInvalidTransition, t.ToLocation)
+	}
+	if !t.FromLocation.CanTransition(t.ToLocation) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, t.FromLocation, t.ToLocation)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationTransitionValidation(t *testing.T) {
+	roomID := int64(1)
+
+	tests := []struct {
+		name        string
+		from        LocationState
+		to          LocationState
+		roomID      *int64
+		expectError bool
+	}{
+		{"arrive in house", LocationAbsent, LocationInHouse, nil, false},
+		{"arrive by bus", LocationBus, LocationInHouse, nil, false},
+		{"enter room", LocationInHouse, LocationRoom, &roomID, false},
+		{"change room", LocationRoom, LocationRoom, &roomID, false},
+		{"room without id", LocationInHouse, LocationRoom, nil, true},
+		{"wc from room", LocationRoom, LocationWC, nil, false},
+		{"picked up from schoolyard", LocationSchoolYard, LocationPickedUp, nil, false},
+		{"wc while absent", LocationAbsent, LocationWC, nil, true},
+		{"picked up while absent", LocationAbsent, LocationPickedUp, nil, true},
+		{"bus from wc", LocationWC, LocationBus, nil, true},
+		{"unknown location", LocationInHouse, LocationState("kitchen"), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &LocationTransition{
+				FromLocation: tt.from,
+				ToLocation:   tt.to,
+				RoomID:       tt.roomID,
+			}
+			err := tr.Validate()
+			if tt.expectError {
+				assert.True(t, errors.Is(err, ErrInvalidTransition))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLocationStateInHouse(t *testing.T) {
+	assert.True(t, LocationRoom.InHouse())
+	assert.True(t, LocationWC.InHouse())
+	assert.False(t, LocationSchoolYard.InHouse())
+	assert.False(t, LocationBus.InHouse())
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/uptrace/bun"
+)
+
+// Errors returned when resolving the owner of a tag.
+var (
+	// ErrTagBlocked is returned for tags reported lost or blocked.
+	ErrTagBlocked = errors.New("tag is blocked")
+	// ErrTagNotValid is returned for tags read outside their validity period.
+	ErrTagNotValid = errors.New("tag is not valid at this time")
+	// ErrTagAssigned is returned when assigning a tag already assigned to a user.
+	ErrTagAssigned = errors.New("tag is already assigned")
+)
+
+// The list of tag statuses.
+const (
+	TagStatusActive  = "active"
+	TagStatusLost    = "lost"
+	TagStatusBlocked = "blocked"
+)
+
+// The list of tag kinds.
+const (
+	TagKindCard      = "card"
+	TagKindWristband = "wristband"
+	TagKindOther     = "other"
+)
+
+// UserTag is the assignment of an RFID tag to a user. A user can hold several
+// tags at once, e.g. a card and a wristband. Ended assignments are kept as
+// history, so reads of a tag can be attributed to the user holding it at the time.
+type UserTag struct {
+	ID           int64       `json:"id" bun:"id,pk,autoincrement"`
+	TagID        string      `json:"tag_id" bun:"tag_id,notnull"`
+	CustomUserID int64       `json:"custom_user_id" bun:"custom_user_id,notnull"`
+	CustomUser   *CustomUser `json:"custom_user,omitempty" bun:"rel:belongs-to,join:custom_user_id=id"`
+	Kind         string      `json:"kind" bun:"kind,notnull"`
+	Status       string      `json:"status" bun:"status,notnull"`
+	ValidFrom    time.Time   `json:"valid_from" bun:"valid_from,notnull"`
+	ValidUntil   *time.Time  `json:"valid_until,omitempty" bun:"valid_until"`
+	Note         string      `json:"note,omitempty" bun:"note"`
+	AssignedAt   time.Time   `json:"assigned_at" bun:"assigned_at,notnull"`
+	UnassignedAt *time.Time  `json:"unassigned_at,omitempty" bun:"unassigned_at"` // end of the assignment
+	CreatedAt    time.Time   `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt    time.Time   `json:"updated_at" bun:"updated_at,notnull"`
+
+	bun.BaseModel `bun:"table:user_tags"`
+}
+
+// Validate validates UserTag struct and returns validation errors.
+func (t *UserTag) Validate() error {
+	return validation.ValidateStruct(t,
+		validation.Field(&t.TagID, validation.Required, is.Alphanumeric),
+		validation.Field(&t.CustomUserID, validation.Required),
+		validation.Field(&t.Kind, validation.Required, validation.In(TagKindCard, TagKindWristband, TagKindOther)),
+		validation.Field(&t.Status, validation.Required, validation.In(TagStatusActive, TagStatusLost, TagStatusBlocked)),
+		validation.Field(&t.ValidUntil, validation.By(func(value interface{}) error {
+			if t.ValidUntil != nil && t.ValidUntil.Before(t.ValidFrom) {
+				return errors.New("must not be before valid_from")
+			}
+			return nil
+		})),
+	)
+}
+
+// Check returns nil if the tag identifies its user at the given time, ErrTagBlocked
+// for lost or blocked tags and ErrTagNotValid outside the validity period.
+func (t *UserTag) Check(at time.Time) error {
+	if t.Status != TagStatusActive {
+		return ErrTagBlocked
+	}
+	if at.Before(t.ValidFrom) || (t.ValidUntil != nil && !at.Before(*t.ValidUntil)) {
+		return ErrTagNotValid
+	}
+	return nil
+}
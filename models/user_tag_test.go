@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserTagCheck(t *testing.T) {
+	now := time.Now()
+	until := now.Add(time.Hour)
+	expired := now.Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		tag  UserTag
+		err  error
+	}{
+		{"active", UserTag{Status: TagStatusActive, ValidFrom: now.Add(-time.Minute)}, nil},
+		{"active until", UserTag{Status: TagStatusActive, ValidFrom: now.Add(-time.Minute), ValidUntil: &until}, nil},
+		{"lost", UserTag{Status: TagStatusLost, ValidFrom: now.Add(-time.Minute)}, ErrTagBlocked},
+		{"blocked", UserTag{Status: TagStatusBlocked, ValidFrom: now.Add(-time.Minute)}, ErrTagBlocked},
+		{"not yet valid", UserTag{Status: TagStatusActive, ValidFrom: now.Add(time.Minute)}, ErrTagNotValid},
+		{"expired", UserTag{Status: TagStatusActive, ValidFrom: now.Add(-2 * time.Hour), ValidUntil: &expired}, ErrTagNotValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, tt.tag.Check(now))
+		})
+	}
+}
+
+func TestUserTagValidate(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+
+	valid := UserTag{TagID: "ABC123", CustomUserID: 1, Kind: TagKindCard, Status: TagStatusActive, ValidFrom: now}
+	assert.NoError(t, valid.Validate())
+
+	invalid := []UserTag{
+		{TagID: "", CustomUserID: 1, Kind: TagKindCard, Status: TagStatusActive, ValidFrom: now},
+		{TagID: "ABC123", CustomUserID: 1, Kind: "implant", Status: TagStatusActive, ValidFrom: now},
+		{TagID: "ABC123", CustomUserID: 1, Kind: TagKindCard, Status: "stolen", ValidFrom: now},
+		{TagID: "ABC123", CustomUserID: 1, Kind: TagKindCard, Status: TagStatusActive, ValidFrom: now, ValidUntil: &before},
+	}
+	for _, tag := range invalid {
+		assert.Error(t, tag.Validate())
+	}
+}